@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service"
+)
+
+func listCache() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	all, err := svc.ListCache(ctx)
+	if err != nil {
+		return err
+	}
+
+	keyStyle := lipgloss.NewStyle().Width(14)
+	timeStyle := lipgloss.NewStyle().Width(22)
+	// here is title
+	fmt.Fprintln(os.Stdout, "\n"+
+		colorGrey.Render(iconSpace.String()+
+			keyStyle.Render("KEY")+
+			funcNameStyle.Render("FUNCTION")+
+			timeStyle.Render("CREATED")+
+			timeStyle.Render("EXPIRES")+
+			"RETURNS"))
+
+	for _, e := range all {
+		icon := pretty.IconMinCircleOk
+		if e.Expired {
+			icon = pretty.IconMinCircleFailed
+		}
+		s := icon.String() +
+			keyStyle.Render(e.Key[:12]) +
+			funcNameStyle.Copy().Foreground(lipgloss.Color("222")).Render(e.Function) +
+			timeStyle.Render(e.Created.Format(time.Stamp)) +
+			timeStyle.Render(e.Expires.Format(time.Stamp)) +
+			fmt.Sprint(e.Returns)
+		fmt.Fprintln(os.Stdout, s)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
+
+func clearCache(function string, expired bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	n, err := svc.ClearCache(ctx, function, expired)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s removed %d cached entries\n", pretty.IconOK.String(), n)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/skoowoo/cofx/config"
 	"github.com/skoowoo/cofx/pkg/nameid"
 
 	"github.com/spf13/cobra"
@@ -17,6 +18,11 @@ var rootCmd = &cobra.Command{
 
 Environment variables:
   COFX_HOME=<path of a directory>           // Default $HOME/.cofx
+  COFX_SHUTDOWN_GRACE=<duration>            // Default 10s, how long to wait for running flows when exiting
+  COFX_SOCKET=<path of a unix socket>       // Default $COFX_HOME/cofx.sock, the control socket of the event flows
+  COFX_DAEMON_CONFIG=<path of a json file>  // Default $COFX_HOME/daemon.json, the config file of the daemon
+  COFX_API_ADDR=<host:port>                 // Serve and access the control api on tcp, e.g. 127.0.0.1:8089
+  COFX_API_TOKEN=<token>                    // The token of the control api on tcp, required by COFX_API_ADDR
 
 Examples:
   cofx
@@ -87,10 +93,51 @@ func initCmd() {
 	}
 
 	{
+		var (
+			envs        []string
+			breakpoints []string
+			script      string
+		)
+		debugCmd := &cobra.Command{
+			Use:   "debug [path to flowl file] or [flow name or id]",
+			Short: "Debug a flowl step by step",
+			Long: `Run a flowl once in debug mode, the flow will be paused before the nodes that hit the breakpoints.
+If no breakpoint is given, the flow will be paused before the first node.
+
+Breakpoints:
+  seq:1001        // pause before the node whose seq is 1001
+  name:print      // pause before the node named 'print'
+  line:12         // pause before the node at line 12 of the flowl source file
+
+Commands:
+  step | skip | continue | where | vars | set <var> <value> | eval <expr> | returns
+  break <breakpoint> | delete <breakpoint> | breakpoints
+`,
+			Example:      "cofx debug ./example.flowl -b name:print",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				for _, env := range envs {
+					kv := strings.Split(env, "=")
+					if len(kv) == 2 {
+						os.Setenv(kv[0], kv[1])
+					}
+				}
+				return debugEntry(nameid.NameOrID(args[0]), breakpoints, script)
+			},
+		}
+		rootCmd.AddCommand(debugCmd)
+		debugCmd.Flags().StringSliceVarP(&envs, "env", "e", nil, "Set environment variables, e.g. -e FOO=bar -e BAZ=qux")
+		debugCmd.Flags().StringSliceVarP(&breakpoints, "break", "b", nil, "Set breakpoints, e.g. -b seq:1001 -b name:print -b line:12")
+		debugCmd.Flags().StringVarP(&script, "script", "s", "", "Read debug commands from a script file instead of the ui, '-' means stdin")
+	}
+
+	{
+		var follow bool
 		logCmd := &cobra.Command{
 			Use:          "log [flow name or id] [function seq]",
 			Short:        "View the execution log of the function",
-			Example:      "cofx run b0804ec967f48520697662a204f5fe72 1000",
+			Example:      "cofx run b0804ec967f48520697662a204f5fe72 1000\ncofx log go-auto-build 1000 --follow",
 			SilenceUsage: true,
 			Args:         cobra.ExactArgs(2),
 			RunE: func(cmd *cobra.Command, args []string) error {
@@ -99,12 +146,64 @@ func initCmd() {
 				if err != nil {
 					return err
 				}
-				return viewLog(nameorid, int(seq))
+				return viewLog(nameorid, int(seq), follow)
 			},
 		}
+		logCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream the new lines from the cofx process running the flow, e.g. the daemon")
 		rootCmd.AddCommand(logCmd)
 	}
 
+	{
+		historyCmd := &cobra.Command{
+			Use:          "history [flow name or id]",
+			Short:        "Show the last runnings of the flow in the cofx process running it, e.g. the daemon",
+			Example:      "cofx history go-auto-build",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return viewHistory(nameid.NameOrID(args[0]))
+			},
+		}
+		rootCmd.AddCommand(historyCmd)
+	}
+
+	{
+		psCmd := &cobra.Command{
+			Use:          "ps",
+			Short:        "List the flows loaded in the serving cofx process, e.g. the daemon",
+			Example:      "cofx ps",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return psFlows()
+			},
+		}
+		stopCmd := &cobra.Command{
+			Use:          "stop [flow name or id]",
+			Short:        "Cancel the running flow and stop its triggers in the serving cofx process",
+			Example:      "cofx stop go-auto-build",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return stopFlow(nameid.NameOrID(args[0]))
+			},
+		}
+
+		var watch bool
+		statusCmd := &cobra.Command{
+			Use:          "status [flow name or id]",
+			Short:        "Show the running state of the flow in the serving cofx process",
+			Example:      "cofx status go-auto-build\ncofx status go-auto-build --watch",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return statusFlow(nameid.NameOrID(args[0]), watch)
+			},
+		}
+		statusCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Refresh the state every second until interrupted")
+		rootCmd.AddCommand(psCmd, stopCmd, statusCmd)
+	}
+
 	{
 		listCmd := &cobra.Command{
 			Use:          "list",
@@ -121,9 +220,9 @@ func initCmd() {
 
 	{
 		stdCmd := &cobra.Command{
-			Use:          "std [function name]",
+			Use:          "std [function name[@version]]",
 			Short:        "List all functions in the standard library or show the manifest of a function",
-			Example:      "cofx std",
+			Example:      "cofx std\ncofx std print@1.0",
 			SilenceUsage: true,
 			Args:         cobra.MaximumNArgs(1),
 			RunE: func(cmd *cobra.Command, args []string) error {
@@ -136,6 +235,200 @@ func initCmd() {
 		}
 		rootCmd.AddCommand(stdCmd)
 	}
+
+	{
+		fnCmd := &cobra.Command{
+			Use:   "fn",
+			Short: "Manage the functions installed in $COFX_HOME",
+		}
+
+		var force bool
+		installCmd := &cobra.Command{
+			Use:          "install [path to a directory, tarball or git repository]",
+			Short:        "Install a function package, a revision of the git repository can be specified after '#'",
+			Example:      "cofx fn install ./deploy\ncofx fn install ./deploy.tar.gz\ncofx fn install ~/src/deploy#v1.2.0",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return installFunction(args[0], force)
+			},
+		}
+		installCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace the installed version")
+
+		listCmd := &cobra.Command{
+			Use:          "list",
+			Short:        "List all functions of the standard library and the installed functions",
+			Example:      "cofx fn list",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listFunctions()
+			},
+		}
+
+		var forceRemove bool
+		removeCmd := &cobra.Command{
+			Use:          "remove [driver:name[@version]]",
+			Short:        "Remove a version or all versions of the installed function",
+			Example:      "cofx fn remove shell:deploy@1.2.0\ncofx fn remove shell:deploy",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return removeFunction(args[0], forceRemove)
+			},
+		}
+		removeCmd.Flags().BoolVarP(&forceRemove, "force", "f", false, "Remove the function even if a flow loads it")
+
+		infoCmd := &cobra.Command{
+			Use:          "info [driver:name[@version]]",
+			Short:        "Show the manifest of the function",
+			Example:      "cofx fn info shell:deploy@^1.2\ncofx fn info go:print",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return inspectFunction(args[0])
+			},
+		}
+
+		fnCmd.AddCommand(installCmd, listCmd, removeCmd, infoCmd)
+		rootCmd.AddCommand(fnCmd)
+	}
+
+	{
+		cacheCmd := &cobra.Command{
+			Use:   "cache",
+			Short: "Manage the cached return values of the functions",
+		}
+
+		lsCmd := &cobra.Command{
+			Use:          "ls",
+			Short:        "List the cached return values, the expired entries are marked red",
+			Example:      "cofx cache ls",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listCache()
+			},
+		}
+
+		var expired bool
+		clearCmd := &cobra.Command{
+			Use:          "clear [driver:name[@version]]",
+			Short:        "Remove the cached return values of a function or all functions",
+			Example:      "cofx cache clear\ncofx cache clear go:http_get\ncofx cache clear --expired",
+			SilenceUsage: true,
+			Args:         cobra.MaximumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				var function string
+				if len(args) > 0 {
+					function = args[0]
+				}
+				return clearCache(function, expired)
+			},
+		}
+		clearCmd.Flags().BoolVar(&expired, "expired", false, "Remove the expired entries only")
+
+		cacheCmd.AddCommand(lsCmd, clearCmd)
+		rootCmd.AddCommand(cacheCmd)
+	}
+
+	{
+		var data []string
+		triggerCmd := &cobra.Command{
+			Use:   "trigger [flow name or id]",
+			Short: "Send an event to an event flow running in another cofx process",
+			Long: `Send an event to an event flow running in another cofx process, the flow runs as if one of its
+triggers fired. The process running the event flow serves the control socket, it's $COFX_HOME/cofx.sock
+by default and can be changed by the environment variable COFX_SOCKET.`,
+			Example:      "cofx trigger go-auto-build\ncofx trigger go-auto-build --data branch=main --data tag=v1.0.0",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return triggerFlow(nameid.NameOrID(args[0]), data)
+			},
+		}
+		triggerCmd.Flags().StringArrayVarP(&data, "data", "d", nil, "The data of the event, e.g. -d branch=main -d tag=v1.0.0")
+		rootCmd.AddCommand(triggerCmd)
+	}
+
+	{
+		var (
+			flows         []string
+			tags          []string
+			cfgPath       string
+			logPath       string
+			dashboardAddr string
+		)
+		daemonCmd := &cobra.Command{
+			Use:   "daemon",
+			Short: "Host the event flows in a long-running process",
+			Long: `Host the event flows in a long-running process, the flows are selected by the names or ids and the tags,
+they are read from the config file and the flags. The config file is $COFX_HOME/daemon.json by default,
+it can be changed by the environment variable COFX_DAEMON_CONFIG, e.g.
+
+  {"flows": ["go-auto-build"], "tags": ["ci"]}
+
+A flow is tagged by the comment before its first statement, e.g. // tags: ci, nightly. The daemon serves
+the control socket, the other cofx commands talk to it, e.g. 'cofx trigger' and 'cofx run' send events
+to the hosted flows. The outputs of the flows are written into the log files, see 'cofx log'.
+
+The web dashboard is served on the address of --dashboard, it requires the token of COFX_API_TOKEN like
+the api served on tcp.`,
+			Example:      "cofx daemon\ncofx daemon --flow go-auto-build --tag ci --log ~/.cofx/logs/daemon.log\nCOFX_API_TOKEN=secret cofx daemon --dashboard 127.0.0.1:8090",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return daemonEntry(flows, tags, cfgPath, logPath, dashboardAddr)
+			},
+		}
+		daemonCmd.Flags().StringArrayVarP(&flows, "flow", "f", nil, "Host the flow by the name or id")
+		daemonCmd.Flags().StringArrayVarP(&tags, "tag", "t", nil, "Host the flows having the tag")
+		daemonCmd.Flags().StringVarP(&cfgPath, "config", "c", config.DaemonConfigFile(), "The config file")
+		daemonCmd.Flags().StringVarP(&logPath, "log", "l", "", "Write the structured logs to the file instead of stderr")
+		daemonCmd.Flags().StringVar(&dashboardAddr, "dashboard", "", "Serve the web dashboard on the address, e.g. 127.0.0.1:8090")
+
+		statusCmd := &cobra.Command{
+			Use:          "status",
+			Short:        "Show the flows hosted by the running daemon",
+			Example:      "cofx daemon status",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return daemonStatus()
+			},
+		}
+
+		var output string
+		installUnitCmd := &cobra.Command{
+			Use:   "install-unit [daemon flags]",
+			Short: "Install the systemd user unit running the daemon",
+			Long: `Install the systemd user unit running the daemon, the arguments are passed to the daemon, and the
+environment variables of cofx, e.g. COFX_HOME, are kept in the unit.`,
+			Example:      "cofx daemon install-unit\ncofx daemon install-unit -o - -- --tag ci",
+			SilenceUsage: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return installUnit(output, args)
+			},
+		}
+		installUnitCmd.Flags().StringVarP(&output, "output", "o", "", "The unit file, default ~/.config/systemd/user/cofx.service, '-' means stdout")
+
+		daemonCmd.AddCommand(statusCmd, installUnitCmd)
+		rootCmd.AddCommand(daemonCmd)
+	}
+
+	{
+		driversCmd := &cobra.Command{
+			Use:          "drivers",
+			Short:        "List all registered function drivers and their capabilities",
+			Example:      "cofx drivers",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listDrivers()
+			},
+		}
+		rootCmd.AddCommand(driversCmd)
+	}
 }
 
 func initCompletionCmd() {
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mitchellh/go-homedir"
+	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/pkg/nameid"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service"
+	"github.com/skoowoo/cofx/service/api"
+	"github.com/skoowoo/cofx/service/daemon"
+	"github.com/skoowoo/cofx/service/dashboard"
+)
+
+// daemonEntry hosts the event flows selected by the config file and the arguments until receiving
+// SIGINT/SIGTERM, the structured logs are written to stderr or the log file. The web dashboard is served
+// on the 'dashboardAddr' if it's not empty.
+func daemonEntry(flows, tags []string, cfgPath, logPath, dashboardAddr string) error {
+	cfg, err := daemon.LoadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+	cfg.Flows = append(cfg.Flows, flows...)
+	cfg.Tags = append(cfg.Tags, tags...)
+
+	var logw io.Writer = os.Stderr
+	if logPath != "" {
+		f, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		logw = f
+	}
+
+	svc := service.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer svc.Shutdown(context.Background())
+	done := svc.WatchSignals(ctx)
+
+	d := daemon.New(svc, logw)
+	ids, err := d.Select(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	l, err := api.Listen(config.ControlSocket())
+	if err != nil {
+		return err
+	}
+	server := api.NewServer(svc, api.WithDaemon(d.Insight))
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+	// The api is served on tcp too if the address is set, the requests on tcp are authenticated by the token.
+	// The dashboard shares the tcp server if it's on the same address.
+	addrs := make(map[string][]api.ServerOption)
+	if addr := config.APIAddr(); addr != "" {
+		addrs[addr] = nil
+	}
+	if dashboardAddr != "" {
+		addrs[dashboardAddr] = append(addrs[dashboardAddr], api.WithDashboard(dashboard.Handler()))
+	}
+	for addr, opts := range addrs {
+		l, err := api.ListenTCP(addr, config.APIToken())
+		if err != nil {
+			return err
+		}
+		opts = append(opts, api.WithDaemon(d.Insight), api.WithToken(config.APIToken()))
+		tcpServer := api.NewServer(svc, opts...)
+		go tcpServer.Serve(l)
+		defer tcpServer.Shutdown(context.Background())
+	}
+
+	if err := d.Start(ctx, ids); err != nil {
+		cancel()
+		d.Wait()
+		return err
+	}
+	<-done
+	cancel()
+	d.Wait()
+	return nil
+}
+
+// daemonStatus prints the flows hosted by the running daemon.
+func daemonStatus() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	di, err := newClient().DaemonInsight(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "\n%s daemon pid %d, up %s\n\n", pretty.IconCycle.String(), di.Pid,
+		time.Since(di.Started).Truncate(time.Second))
+
+	statusStyle := lipgloss.NewStyle().Width(12)
+	countStyle := lipgloss.NewStyle().Width(10)
+	fmt.Fprintln(os.Stdout, colorGrey.Render(iconSpace.String()+
+		flowNameStyle.Render("FLOW NAME")+
+		flowIDStyle.Render("FLOW ID")+
+		statusStyle.Render("STATUS")+
+		countStyle.Render("RUNS")+
+		countStyle.Render("FAILURES")+
+		"LAST ERROR"))
+	for _, f := range di.Flows {
+		icon := pretty.IconMinCircleOk
+		if f.Status != daemon.StatusRunning {
+			icon = pretty.IconMinCircleFailed
+		}
+		fmt.Fprintln(os.Stdout, icon.String()+
+			flowNameStyle.Foreground(lipgloss.Color("222")).Render(f.Name)+
+			flowIDStyle.Render(f.ID)+
+			statusStyle.Render(f.Status)+
+			countStyle.Render(fmt.Sprint(f.Runs))+
+			countStyle.Render(fmt.Sprint(f.Failures))+
+			colorRed.MaxWidth(60).Render(f.LastError))
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
+
+// installUnit writes the systemd user unit running the daemon, '-' means writing to stdout.
+func installUnit(output string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	env := map[string]string{"COFX_HOME": config.HomeDir()}
+	for _, k := range []string{"COFX_SOCKET", "COFX_DAEMON_CONFIG", "COFX_HTTP_ADDR", "COFX_SHUTDOWN_GRACE",
+		"COFX_API_ADDR", "COFX_API_TOKEN"} {
+		if v := os.Getenv(k); v != "" {
+			env[k] = v
+		}
+	}
+	args = append([]string{"daemon"}, args...)
+	if output == "-" {
+		return daemon.WriteUnit(os.Stdout, exe, args, env)
+	}
+	if output == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return err
+		}
+		output = filepath.Join(home, ".config", "systemd", "user", "cofx.service")
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	// The unit can contain the token of the api, so only the user can read it.
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := daemon.WriteUnit(f, exe, args, env); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s installed %s, enable it by:\n  systemctl --user daemon-reload\n  systemctl --user enable --now %s\n",
+		pretty.IconOK.String(), output, filepath.Base(output))
+	return nil
+}
+
+// hostedByDaemon returns true if the flow is hosted by the running daemon.
+func hostedByDaemon(ctx context.Context, fid nameid.ID) bool {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	di, err := newClient().DaemonInsight(ctx)
+	if err != nil {
+		if !errors.Is(err, api.ErrNotServing) && !errors.Is(err, api.ErrNotDaemon) {
+			fmt.Fprintf(os.Stderr, "%s query daemon: %s\n", pretty.IconFailed.String(), err)
+		}
+		return false
+	}
+	for _, f := range di.Flows {
+		if f.ID == fid.ID() && f.Status == daemon.StatusRunning {
+			return true
+		}
+	}
+	return false
+}
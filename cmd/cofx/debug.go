@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/pkg/output"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/runtime"
+	"github.com/skoowoo/cofx/service"
+)
+
+// debugEntry runs the flow once in debug mode, the flow is paused before the nodes that hit the
+// breakpoints. If no breakpoint is given, the flow is paused before the first node. If 'script' isn't
+// empty, the debug commands are read from the script file ('-' means stdin) instead of the ui.
+func debugEntry(nameorid nameid.NameOrID, bps []string, script string) error {
+	svc := service.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Release all drivers and flush logs when exiting or receiving SIGINT/SIGTERM.
+	defer svc.Shutdown(context.Background())
+	done := svc.WatchSignals(ctx)
+
+	var breakpoints []runtime.Breakpoint
+	for _, s := range bps {
+		bp, err := runtime.ParseBreakpoint(s)
+		if err != nil {
+			return err
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+	debugger := runtime.NewDebugger(len(breakpoints) == 0, breakpoints...)
+
+	path, fid, err := svc.LookupFlowl(ctx, nameorid)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := svc.AddFlow(ctx, fid, f); err != nil {
+		return err
+	}
+
+	if script != "" {
+		var rd io.Reader = os.Stdin
+		if script != "-" {
+			f, err := os.Open(script)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			rd = f
+		}
+		if _, err := svc.ReadyFlow(ctx, fid, os.Stdout, runtime.WithDebugger(debugger)); err != nil {
+			return err
+		}
+		wait := svc.StartFlow(ctx, fid)
+		if err := debugger.Serve(ctx, rd, os.Stdout); err != nil {
+			svc.CancelRunningFlow(ctx, fid)
+			select {
+			case <-wait:
+			case <-done:
+			}
+			return err
+		}
+		select {
+		case err := <-wait:
+			return err
+		case <-done:
+			return service.ErrInterrupted
+		}
+	}
+
+	lineC := make(chan string, 100)
+	out := &output.Output{
+		W: nil,
+		HandleFunc: func(line []byte) {
+			line = bytes.TrimSuffix(line, []byte{'\n'})
+			lineC <- string(line)
+		},
+	}
+	if _, err := svc.ReadyFlow(ctx, fid, out, runtime.WithDebugger(debugger)); err != nil {
+		return err
+	}
+
+	// The flow and the ui run in their own goroutines, so each one has its own error.
+	var (
+		flowErr error
+		uiErr   error
+		wg      sync.WaitGroup
+	)
+	wg.Add(2)
+	go func() {
+		defer func() {
+			close(lineC)
+			wg.Done()
+		}()
+		flowErr = svc.StartFlowAndWait(ctx, fid)
+	}()
+
+	go func() {
+		defer func() {
+			// When ui exited, release the debugger and cancel the flow to make sure the flow exit.
+			debugger.Release()
+			svc.CancelRunningFlow(ctx, fid)
+			wg.Done()
+		}()
+		uiErr = tea.NewProgram(newDebugModel(fid, debugger, lineC)).Start()
+	}()
+	if err := waitOrInterrupted(&wg, done); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, err := range []error{flowErr, uiErr} {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+type debugTickMsg struct {
+	where string
+}
+
+type debugLineMsg struct {
+	l    string
+	exit bool
+}
+
+type debugModel struct {
+	width    int
+	fid      nameid.ID
+	debugger *runtime.Debugger
+	lineC    chan string
+	input    textinput.Model
+	spinner  spinner.Model
+	where    string
+}
+
+func newDebugModel(fid nameid.ID, debugger *runtime.Debugger, lineC chan string) debugModel {
+	input := textinput.New()
+	input.Prompt = "(debug) "
+	input.Placeholder = "step, skip, continue, vars, set <var> <value>, eval <expr>, returns, break <bp>"
+	input.Focus()
+
+	s := spinner.New()
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("63"))
+	return debugModel{
+		fid:      fid,
+		debugger: debugger,
+		lineC:    lineC,
+		input:    input,
+		spinner:  s,
+	}
+}
+
+func (m debugModel) tick() tea.Cmd {
+	return tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
+		var where string
+		if frame := m.debugger.Paused(); frame != nil {
+			where = frame.Where()
+		}
+		return debugTickMsg{where: where}
+	})
+}
+
+func (m debugModel) sub() tea.Cmd {
+	return func() tea.Msg {
+		l, ok := <-m.lineC
+		if !ok {
+			return debugLineMsg{exit: true}
+		}
+		return debugLineMsg{l: l}
+	}
+}
+
+func (m debugModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, m.spinner.Tick, m.tick(), m.sub())
+}
+
+func (m debugModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			line := strings.TrimSpace(m.input.Value())
+			m.input.Reset()
+			if line == "" {
+				return m, nil
+			}
+			if line == "quit" || line == "q" {
+				return m, tea.Quit
+			}
+			out, err := m.debugger.Command(line)
+			if err != nil {
+				out = colorRed.Render("error: " + err.Error())
+			}
+			return m, tea.Printf("%s\n%s", colorGrey.Render("(debug) "+line), out)
+		}
+	case debugTickMsg:
+		m.where = msg.where
+		return m, m.tick()
+	case debugLineMsg:
+		if msg.exit {
+			return m, tea.Quit
+		}
+		return m, tea.Batch(m.sub(), tea.Printf("%s", msg.l))
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m debugModel) View() string {
+	var status string
+	if m.where != "" {
+		status = pretty.IconCycle.String() + "Paused at " + executing.Render(m.where)
+	} else {
+		status = m.spinner.View() + " Running " + m.fid.Name()
+	}
+	return status + "\n" + m.input.View()
+}
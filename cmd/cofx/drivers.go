@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service"
+)
+
+func listDrivers() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	all := svc.ListDrivers(ctx)
+
+	column := lipgloss.NewStyle().Width(14)
+	yes := func(b bool) string {
+		if b {
+			return column.Render("yes")
+		}
+		return column.Render("-")
+	}
+
+	// here is title
+	fmt.Fprintln(os.Stdout, "\n"+
+		colorGrey.Render(iconSpace.String()+
+			column.Render("DRIVER")+
+			column.Render("VERSIONS")+
+			column.Render("LONG-LIVED")+
+			column.Render("SANDBOXED")+
+			"INLINE"))
+
+	for _, d := range all {
+		s := pretty.IconMinCircleOk.String() +
+			column.Copy().Foreground(lipgloss.Color("222")).Render(d.Name) +
+			yes(d.Versions) +
+			yes(d.LongLived) +
+			yes(d.Sandboxed) +
+			yes(d.Inline)
+		fmt.Fprintln(os.Stdout, s)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
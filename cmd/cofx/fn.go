@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service"
+)
+
+func listFunctions() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	all, err := svc.ListFunctions(ctx)
+	if err != nil {
+		return err
+	}
+
+	driverStyle := lipgloss.NewStyle().Width(10)
+	// here is title
+	fmt.Fprintln(os.Stdout, "\n"+
+		colorGrey.Render(iconSpace.String()+
+			driverStyle.Render("DRIVER")+
+			funcNameStyle.Render("FUNCTION NAME")+
+			versionStyle.Render("VERSIONS")+
+			"DESC"))
+
+	for _, f := range all {
+		versions := strings.Join(f.Versions, ",")
+		if versions == "" {
+			versions = "-"
+		}
+		s := pretty.IconMinCircleOk.String() +
+			driverStyle.Render(f.Driver) +
+			funcNameStyle.Copy().Foreground(lipgloss.Color("222")).Render(f.Name) +
+			versionStyle.Render(versions) +
+			lipgloss.NewStyle().MaxWidth(100).Render(f.Desc)
+		fmt.Fprintln(os.Stdout, s)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
+
+func installFunction(source string, force bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	info, err := svc.InstallFunction(ctx, source, force)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s installed %s:%s@%s into %s\n", pretty.IconOK.String(), info.Driver,
+		info.Name, info.Manifest.Version, info.Dir)
+	return nil
+}
+
+func removeFunction(ref string, force bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	if err := svc.RemoveFunction(ctx, ref, force); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s removed %s\n", pretty.IconOK.String(), ref)
+	return nil
+}
+
+func inspectFunction(ref string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	info, err := svc.InspectFunction(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return info.JsonWrite(os.Stdout)
+}
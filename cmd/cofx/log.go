@@ -2,16 +2,34 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/skoowoo/cofx/pkg/nameid"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
 	"github.com/skoowoo/cofx/service"
 )
 
-func viewLog(nameorid nameid.NameOrID, seq int) error {
+func viewLog(nameorid nameid.NameOrID, seq int, follow bool) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// The new lines of the log are streamed by the cofx process running the flow, e.g. the daemon.
+	if follow {
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigC)
+		go func() {
+			<-sigC
+			cancel()
+		}()
+		return newClient().ViewLog(ctx, nameorid.String(), seq, true, os.Stdout)
+	}
+
 	svc := service.New()
 	id, err := svc.LookupID(ctx, nameorid)
 	if err != nil {
@@ -23,3 +41,35 @@ func viewLog(nameorid nameid.NameOrID, seq int) error {
 
 	return nil
 }
+
+// viewHistory prints the last runnings of the flow in the cofx process running it, e.g. the daemon.
+func viewHistory(nameorid nameid.NameOrID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	history, err := newClient().History(ctx, nameorid.String())
+	if err != nil {
+		return err
+	}
+	runStyle := lipgloss.NewStyle().Width(45)
+	timeStyle := lipgloss.NewStyle().Width(22)
+	durationStyle := lipgloss.NewStyle().Width(12)
+	fmt.Fprintln(os.Stdout, "\n"+colorGrey.Render(iconSpace.String()+
+		runStyle.Render("RUN ID")+
+		timeStyle.Render("BEGIN TIME")+
+		durationStyle.Render("DURATION")+
+		"ERROR"))
+	for _, r := range history {
+		icon := pretty.IconOK
+		if r.Error != "" {
+			icon = pretty.IconFailed
+		}
+		fmt.Fprintln(os.Stdout, icon.String()+
+			runStyle.Render(r.RunID)+
+			timeStyle.Render(r.Begin.Format("2006-01-02 15:04:05"))+
+			durationStyle.Render((time.Duration(r.Duration)*time.Millisecond).String())+
+			colorRed.MaxWidth(60).Render(r.Error))
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"os"
 	"strconv"
 	"sync"
@@ -15,6 +14,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/skoowoo/cofx/pkg/nameid"
 	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/runtime"
 	"github.com/skoowoo/cofx/service"
 	"github.com/skoowoo/cofx/service/exported"
 )
@@ -23,6 +23,9 @@ func prunEntry(nameorid nameid.NameOrID) error {
 	svc := service.New()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	// Release all drivers and flush logs when exiting or receiving SIGINT/SIGTERM.
+	defer svc.Shutdown(context.Background())
+	done := svc.WatchSignals(ctx)
 
 	var fid nameid.ID
 
@@ -30,6 +33,10 @@ func prunEntry(nameorid nameid.NameOrID) error {
 	if err != nil {
 		return err
 	}
+	// The event flow hosted by the daemon is triggered in the daemon, instead of running another instance.
+	if hostedByDaemon(ctx, fid) {
+		return triggerFlow(nameid.NameOrID(fid.ID()), nil)
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return err
@@ -40,6 +47,11 @@ func prunEntry(nameorid nameid.NameOrID) error {
 	if _, err := svc.ReadyFlow(ctx, fid, nil); err != nil {
 		return err
 	}
+	stopServing := serveAPI(ctx, svc, fid)
+	defer stopServing()
+
+	sub := svc.Subscribe(ctx, fid)
+	defer sub.Close()
 
 	var (
 		lasterr error
@@ -55,7 +67,7 @@ func prunEntry(nameorid nameid.NameOrID) error {
 			svc.CancelRunningFlow(ctx, fid)
 		}()
 
-		if err := startPrunView(func() (*exported.FlowRunningInsight, error) {
+		if err := startPrunView(sub.C(), func() (*exported.FlowRunningInsight, error) {
 			fi, err := svc.InsightFlow(ctx, fid)
 			return &fi, err
 		}); err != nil {
@@ -73,7 +85,9 @@ func prunEntry(nameorid nameid.NameOrID) error {
 		}
 		prunCmdExited = true
 	}()
-	wg.Wait()
+	if err := waitOrInterrupted(&wg, done); err != nil {
+		return err
+	}
 
 	if lasterr != nil {
 		os.Exit(-1)
@@ -84,7 +98,9 @@ func prunEntry(nameorid nameid.NameOrID) error {
 
 var prunCmdExited bool
 
-func startPrunView(get func() (*exported.FlowRunningInsight, error)) error {
+// startPrunView starts the ui of prun, the ui is refreshed when an event of the flow is received
+// from 'events', or one second has passed.
+func startPrunView(events <-chan runtime.BusEvent, get func() (*exported.FlowRunningInsight, error)) error {
 	fi, err := get()
 	if err != nil {
 		return err
@@ -101,16 +117,19 @@ func startPrunView(get func() (*exported.FlowRunningInsight, error)) error {
 			progress.WithoutPercentage(),
 		),
 		fi: fi,
-		getCmd: tea.Tick(time.Millisecond*100, func(t time.Time) tea.Msg {
+		getCmd: func() tea.Msg {
+			select {
+			case <-events:
+			case <-time.After(time.Second):
+			}
 			fi, err := get()
 			if err != nil {
 				return viewErrorMessage(err)
 			}
 			return fi
-		}),
+		},
 	}
 
-	rand.Seed(time.Now().Unix())
 	return tea.NewProgram(model).Start()
 }
 
@@ -168,6 +187,10 @@ func (m prunModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.getCmd,
 		)
 	case viewErrorMessage:
+		// The flow may be deleted after the service shutdown
+		if prunCmdExited {
+			return m, tea.Quit
+		}
 		return m, m.getCmd
 	}
 	return m, nil
@@ -177,48 +200,14 @@ func (m prunModel) View() string {
 	window := pretty.NewWindow(m.height, m.width, false)
 	window.SetTitle(pretty.NewTitleBlock("Pretty Run Flow: "+m.fi.Name, m.fi.ID))
 
-	headers := []string{pretty.IconSpace.String(), "STEP", "SEQ", "NAME", "DRIVER", "RUNS", "DURATION"}
-	var values [][]string
-	for _, n := range m.fi.Nodes {
-		if n.Status == "RUNNING" {
-			values = append(values, []string{
-				m.spinner.View(),
-				strconv.Itoa(n.Step),
-				strconv.Itoa(n.Seq),
-				n.Name + " ➜ " + n.Function,
-				n.Driver,
-				strconv.Itoa(n.Runs),
-				fmt.Sprintf("%dms", n.Duration),
-			})
-		} else if n.Status == "STOPPED" {
-			icon := pretty.IconOK
-			if n.LastError != nil {
-				icon = pretty.IconFailed
-			}
-			values = append(values, []string{
-				icon.String(),
-				strconv.Itoa(n.Step),
-				strconv.Itoa(n.Seq),
-				n.Name + " ➜ " + n.Function,
-				n.Driver,
-				strconv.Itoa(n.Runs),
-				fmt.Sprintf("%dms", n.Duration),
-			})
-		} else {
-			values = append(values, []string{
-				pretty.IconSpace.String(),
-				strconv.Itoa(n.Step),
-				strconv.Itoa(n.Seq),
-				n.Name + " ➜ " + n.Function,
-				n.Driver,
-				strconv.Itoa(n.Runs),
-				fmt.Sprintf("%dms", n.Duration),
-			})
-		}
-	}
-	window.AppendBlock(pretty.NewTableBlock(headers, values))
+	window.AppendBlock(pretty.NewTableBlock(nodeTable(m.fi.Nodes, m.spinner.View())))
 	window.AppendNewRow(1)
 
+	if len(m.fi.Triggers) > 0 {
+		window.AppendBlock(pretty.NewTableBlock(triggerTable(m.fi.Triggers)))
+		window.AppendNewRow(1)
+	}
+
 	if m.done {
 		s := "\n" + pretty.IconOK.String() + fmt.Sprintf("Done! Duration: %dms", m.fi.Duration)
 		window.AppendBlock(pretty.NewTextBlock(s))
@@ -229,3 +218,63 @@ func (m prunModel) View() string {
 
 	return window.Render()
 }
+
+// nodeTable returns the headers and rows of the nodes' state, 'running' is the icon of the running nodes.
+func nodeTable(nodes []exported.NodeRunningInsight, running string) ([]string, [][]string) {
+	headers := []string{pretty.IconSpace.String(), "STEP", "SEQ", "NAME", "DRIVER", "RUNS", "DURATION"}
+	var values [][]string
+	for _, n := range nodes {
+		icon := pretty.IconSpace.String()
+		if n.Status == "RUNNING" {
+			icon = running
+		} else if n.Status == "STOPPED" || n.Status == "CACHED" {
+			icon = pretty.IconOK.String()
+			if n.LastError != nil {
+				icon = pretty.IconFailed.String()
+			} else if n.Status == "CACHED" {
+				icon = pretty.IconCycle.String()
+			}
+		}
+		values = append(values, []string{
+			icon,
+			strconv.Itoa(n.Step),
+			strconv.Itoa(n.Seq),
+			n.Name + " ➜ " + n.Function,
+			n.Driver,
+			strconv.Itoa(n.Runs),
+			fmt.Sprintf("%dms", n.Duration),
+		})
+	}
+	return headers, values
+}
+
+// triggerTable returns the headers and rows of the triggers' state.
+func triggerTable(triggers []exported.TriggerRunningInsight) ([]string, [][]string) {
+	headers := []string{pretty.IconSpace.String(), "SEQ", "TRIGGER", "STATUS", "FIRES", "LAST FIRE", "NEXT FIRE", "ERRORS"}
+	clock := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("15:04:05")
+	}
+	var values [][]string
+	for _, tg := range triggers {
+		icon := pretty.IconCycle
+		errs := strconv.Itoa(tg.Errors)
+		if tg.Errors > 0 {
+			icon = pretty.IconFailed
+			errs = fmt.Sprintf("%d, retry in %dms: %v", tg.Errors, tg.Backoff, tg.LastError)
+		}
+		values = append(values, []string{
+			icon.String(),
+			strconv.Itoa(tg.Seq),
+			tg.Name + " ➜ " + tg.Function,
+			tg.Status,
+			strconv.Itoa(tg.Fires),
+			clock(tg.LastFire),
+			clock(tg.NextFire),
+			errs,
+		})
+	}
+	return headers, values
+}
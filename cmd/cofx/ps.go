@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/skoowoo/cofx/pkg/nameid"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service/exported"
+)
+
+// psFlows prints the flows loaded in the serving cofx process, e.g. the daemon.
+func psFlows() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	flows, err := newClient().ListLoadedFlows(ctx)
+	if err != nil {
+		return err
+	}
+	statusStyle := lipgloss.NewStyle().Width(12)
+	activeStyle := lipgloss.NewStyle().Width(22)
+	timeStyle := lipgloss.NewStyle().Width(12)
+	fmt.Fprintln(os.Stdout, "\n"+colorGrey.Render(iconSpace.String()+
+		flowNameStyle.Render("FLOW NAME")+
+		flowIDStyle.Render("FLOW ID")+
+		statusStyle.Render("STATUS")+
+		activeStyle.Render("ACTIVE RUNS")+
+		timeStyle.Render("NEXT FIRE")+
+		"LAST RESULT"))
+	for _, f := range flows {
+		fi := f.Insight
+		icon := pretty.IconMinCircleOk
+		if f.LastRun != nil && f.LastRun.Error != "" {
+			icon = pretty.IconMinCircleFailed
+		}
+		status := fi.Status
+		if fi.TriggersStarted {
+			status = "SERVING"
+		}
+		active := fmt.Sprint(fi.ActiveRuns)
+		if fi.ActiveRuns > 0 && fi.Total > 0 {
+			active += fmt.Sprintf(" (%d/%d nodes)", fi.Done, fi.Total)
+		}
+		if fi.QueuedEvents > 0 {
+			active += fmt.Sprintf(" +%d queued", fi.QueuedEvents)
+		}
+		next := "-"
+		if !f.NextFire.IsZero() {
+			next = f.NextFire.Format("15:04:05")
+		}
+		result := colorGrey.Render("-")
+		if r := f.LastRun; r != nil {
+			result = fmt.Sprintf("%s at %s", r.Status, r.Begin.Format("15:04:05"))
+			if r.Error != "" {
+				result = colorRed.MaxWidth(60).Render(result + ": " + r.Error)
+			}
+		}
+		fmt.Fprintln(os.Stdout, icon.String()+
+			flowNameStyle.Copy().Foreground(lipgloss.Color("222")).Render(fi.Name)+
+			flowIDStyle.Render(fi.ID)+
+			statusStyle.Render(status)+
+			activeStyle.Render(active)+
+			timeStyle.Render(next)+
+			result)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
+
+// stopFlow cancels the running flow and stops its triggers in the serving cofx process, the flow is kept
+// loaded, so its state can still be viewed.
+func stopFlow(nameorid nameid.NameOrID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := newClient().CancelFlow(ctx, nameorid.String()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s stopped flow %s\n", pretty.IconOK.String(), nameorid)
+	return nil
+}
+
+// statusFlow prints the running insight of the flow in the serving cofx process, if 'watch' is true, it's
+// refreshed every second until receiving SIGINT/SIGTERM.
+func statusFlow(nameorid nameid.NameOrID, watch bool) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	client := newClient()
+	refresh := func() error {
+		qctx, qcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer qcancel()
+		fi, err := client.InsightFlow(qctx, nameorid.String())
+		if err != nil {
+			return err
+		}
+		if watch {
+			// Move the cursor to the top-left and clear the screen
+			fmt.Fprint(os.Stdout, "\033[H\033[2J")
+		}
+		fmt.Fprintln(os.Stdout, renderStatus(fi))
+		return nil
+	}
+	if err := refresh(); err != nil || !watch {
+		return err
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := refresh(); err != nil && ctx.Err() == nil {
+				return err
+			}
+		}
+	}
+}
+
+func renderStatus(fi exported.FlowRunningInsight) string {
+	window := pretty.NewWindow(0, 0, false)
+	window.SetTitle(pretty.NewTitleBlock("Flow: "+fi.Name, fi.ID))
+	kvs := [][]string{
+		{"STATUS", fi.Status},
+		{"PROGRESS", fmt.Sprintf("%d/%d", fi.Done, fi.Total)},
+		{"DURATION", fmt.Sprintf("%dms", fi.Duration)},
+	}
+	if fi.TriggersStarted {
+		kvs = append(kvs,
+			[]string{"ACTIVE RUNS", fmt.Sprint(fi.ActiveRuns)},
+			[]string{"QUEUED EVENTS", fmt.Sprint(fi.QueuedEvents)},
+			[]string{"DROPPED EVENTS", fmt.Sprint(fi.Dropped)})
+	}
+	window.AppendBlock(pretty.NewKvsBlock(kvs...))
+	window.AppendNewRow(1)
+	if len(fi.Nodes) > 0 {
+		window.AppendBlock(pretty.NewTableBlock(nodeTable(fi.Nodes, pretty.IconCycle.String())))
+		window.AppendNewRow(1)
+	}
+	if len(fi.Triggers) > 0 {
+		window.AppendBlock(pretty.NewTableBlock(triggerTable(fi.Triggers)))
+		window.AppendNewRow(1)
+	}
+	if fi.LastError != nil {
+		window.AppendBlock(pretty.NewTextBlock(colorRed.Render("error: " + fi.LastError.Error())))
+	}
+	return window.Render()
+}
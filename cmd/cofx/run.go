@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"math/rand"
 	"os"
 	"strings"
 	"sync"
@@ -37,11 +36,18 @@ func runEntry(nameorid nameid.NameOrID) error {
 	svc := service.New()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	// Release all drivers and flush logs when exiting or receiving SIGINT/SIGTERM.
+	defer svc.Shutdown(context.Background())
+	done := svc.WatchSignals(ctx)
 
 	path, fid, err := svc.LookupFlowl(ctx, nameorid)
 	if err != nil {
 		return err
 	}
+	// The event flow hosted by the daemon is triggered in the daemon, instead of running another instance.
+	if hostedByDaemon(ctx, fid) {
+		return triggerFlow(nameid.NameOrID(fid.ID()), nil)
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return err
@@ -61,9 +67,17 @@ func runEntry(nameorid nameid.NameOrID) error {
 	if _, err := svc.ReadyFlow(ctx, fid, out); err != nil {
 		return err
 	}
+	stopServing := serveAPI(ctx, svc, fid)
+	defer stopServing()
+
+	sub := svc.Subscribe(ctx, fid)
+	defer sub.Close()
 
-	var errs []error
-	var wg sync.WaitGroup
+	var (
+		errs []error
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
 	wg.Add(2)
 	go func() {
 		defer func() {
@@ -73,7 +87,9 @@ func runEntry(nameorid nameid.NameOrID) error {
 		}()
 
 		if err := svc.StartFlowOrEventFlow(ctx, fid); err != nil {
+			mu.Lock()
 			errs = append(errs, err)
+			mu.Unlock()
 			return
 		}
 	}()
@@ -85,20 +101,25 @@ func runEntry(nameorid nameid.NameOrID) error {
 			svc.CancelRunningFlow(ctx, fid)
 			wg.Done()
 		}()
-		rand.Seed(time.Now().UnixNano())
-
 		m := newRunModel()
 		m.getMsgFunc = func() tea.Cmd {
-			return tea.Tick(time.Millisecond*time.Duration(rand.Intn(50)), func(t time.Time) tea.Msg {
+			return func() tea.Msg {
+				// Refresh the status when the flow or its nodes changed, instead of polling.
+				select {
+				case _, ok := <-sub.C():
+					if !ok {
+						return nil
+					}
+				case <-time.After(time.Second):
+				}
 				insight, err := svc.InsightFlow(ctx, fid)
 				if err != nil {
 					return err
 				}
-				// insight.JsonWrite(os.Stdout)
 				return runGetMsg{
 					status: insight,
 				}
-			})
+			}
 		}
 		m.subMsgFunc = func() tea.Cmd {
 			return func() tea.Msg {
@@ -115,11 +136,15 @@ func runEntry(nameorid nameid.NameOrID) error {
 		}
 
 		if err := tea.NewProgram(m).Start(); err != nil {
+			mu.Lock()
 			errs = append(errs, err)
+			mu.Unlock()
 			return
 		}
 	}()
-	wg.Wait()
+	if err := waitOrInterrupted(&wg, done); err != nil {
+		return err
+	}
 
 	if len(errs) > 0 {
 		return fmt.Errorf("%v", errs)
@@ -127,6 +152,22 @@ func runEntry(nameorid nameid.NameOrID) error {
 	return nil
 }
 
+// waitOrInterrupted waits for the goroutines of the command, it returns service.ErrInterrupted if the service
+// has been shutdown by a signal before, the goroutines may be still blocked by the ui at that time.
+func waitOrInterrupted(wg *sync.WaitGroup, done <-chan struct{}) error {
+	exited := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+		return nil
+	case <-done:
+		return service.ErrInterrupted
+	}
+}
+
 type runSubMsg struct {
 	l    string
 	exit bool
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	pretty "github.com/skoowoo/cofx/pkg/pretty"
@@ -27,6 +28,7 @@ func listStd() error {
 	fmt.Fprintln(os.Stdout, "\n"+
 		colorGrey.Render(iconSpace.String()+
 			funcNameStyle.Render("FUNCTION NAME")+
+			versionStyle.Render("VERSIONS")+
 			"DESC"))
 
 	for _, f := range all {
@@ -36,6 +38,7 @@ func listStd() error {
 		}
 		s := pretty.IconMinCircleOk.String() +
 			funcNameStyle.Foreground(lipgloss.Color("222")).Render(name) +
+			versionStyle.Render(strings.Join(f.Versions, ",")) +
 			lipgloss.NewStyle().MaxWidth(100).Render(f.Desc)
 		fmt.Fprintln(os.Stdout, s)
 	}
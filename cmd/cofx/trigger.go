@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/pkg/nameid"
+	pretty "github.com/skoowoo/cofx/pkg/pretty"
+	"github.com/skoowoo/cofx/service"
+	"github.com/skoowoo/cofx/service/api"
+)
+
+func triggerFlow(nameorid nameid.NameOrID, data []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kvs := make(map[string]string)
+	for _, d := range data {
+		k, v, ok := strings.Cut(d, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid data '%s', it should be k=v", d)
+		}
+		kvs[k] = v
+	}
+	if err := newClient().TriggerFlow(ctx, nameorid.String(), kvs); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "%s triggered flow %s\n", pretty.IconOK.String(), nameorid)
+	return nil
+}
+
+// newClient creates the client talking to the serving cofx process, it's on tcp if the environment variable
+// COFX_API_ADDR is set, otherwise on the control socket.
+func newClient() *api.Client {
+	if addr := config.APIAddr(); addr != "" {
+		return api.NewTCPClient(addr, api.WithClientToken(config.APIToken()))
+	}
+	return api.NewClient(config.ControlSocket())
+}
+
+// serveAPI serves the control api while the event flow is running, so the flow can be triggered by the
+// other cofx commands. Nothing is served if the flow has no triggers or another process is serving. The
+// returned function stops serving.
+func serveAPI(ctx context.Context, svc *service.SVC, fid nameid.ID) func() {
+	if has, err := svc.HasTrigger(ctx, fid); err != nil || !has {
+		return func() {}
+	}
+	l, err := api.Listen(config.ControlSocket())
+	if err != nil {
+		return func() {}
+	}
+	server := api.NewServer(svc)
+	go server.Serve(l)
+	return func() {
+		server.Shutdown(context.Background())
+	}
+}
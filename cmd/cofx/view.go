@@ -7,6 +7,7 @@ type viewErrorMessage error
 var (
 	// function
 	funcNameStyle = lipgloss.NewStyle().Width(30)
+	versionStyle  = lipgloss.NewStyle().Width(16)
 
 	// flow
 	flowNameStyle = lipgloss.NewStyle().Width(20)
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -58,6 +59,12 @@ func LogDir() string {
 	return prettyDirPath(v)
 }
 
+// CacheDir store the cached return values of the functions.
+func CacheDir() string {
+	v := filepath.Join(HomeDir(), "cache")
+	return prettyDirPath(v)
+}
+
 // PrivateShellDir store all functions that's based on shell driver.
 func PrivateShellDir() string {
 	v := filepath.Join(HomeDir(), "shell")
@@ -69,6 +76,77 @@ func BaseShellDir() string {
 	return prettyDirPath(v)
 }
 
+// PrivateExecDir store all functions that's based on exec driver, every function is a directory
+// that contains a manifest.json and an executable.
+func PrivateExecDir() string {
+	v := filepath.Join(HomeDir(), "exec")
+	return prettyDirPath(v)
+}
+
+// PrivateWasmDir store all functions that's based on wasm driver, every function is a directory
+// that contains a manifest.json and a .wasm module.
+func PrivateWasmDir() string {
+	v := filepath.Join(HomeDir(), "wasm")
+	return prettyDirPath(v)
+}
+
+// PrivateScriptDir store all functions that's based on script driver, a function is a '.star' file,
+// or a directory that contains a manifest.json and a '.star' file.
+func PrivateScriptDir() string {
+	v := filepath.Join(HomeDir(), "script")
+	return prettyDirPath(v)
+}
+
+// ShutdownGracePeriod returns how long to wait for the running flows to finish when shutting down,
+// it can be set by the environment variable 'COFX_SHUTDOWN_GRACE', e.g. 30s, 1m. Default 10s.
+func ShutdownGracePeriod() time.Duration {
+	if v := os.Getenv("COFX_SHUTDOWN_GRACE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return 10 * time.Second
+}
+
+// HttpTriggerAddr returns the address listened by the http trigger service for the webhooks, it can be set
+// by the environment variable 'COFX_HTTP_ADDR'. Default 127.0.0.1:8088.
+func HttpTriggerAddr() string {
+	if v := os.Getenv("COFX_HTTP_ADDR"); v != "" {
+		return v
+	}
+	return "127.0.0.1:8088"
+}
+
+// ControlSocket returns the path of the unix socket serving the control api, it can be set by the environment
+// variable 'COFX_SOCKET'. Default $COFX_HOME/cofx.sock.
+func ControlSocket() string {
+	if v := os.Getenv("COFX_SOCKET"); v != "" {
+		return v
+	}
+	return filepath.Join(HomeDir(), "cofx.sock")
+}
+
+// APIAddr returns the tcp address serving the control api besides the unix socket, it's set by the environment
+// variable 'COFX_API_ADDR', e.g. 127.0.0.1:8089. The api isn't served on tcp by default.
+func APIAddr() string {
+	return os.Getenv("COFX_API_ADDR")
+}
+
+// APIToken returns the token authenticating the requests of the control api on tcp, it's set by the environment
+// variable 'COFX_API_TOKEN'.
+func APIToken() string {
+	return os.Getenv("COFX_API_TOKEN")
+}
+
+// DaemonConfigFile returns the path of the config file of the daemon, it can be set by the environment
+// variable 'COFX_DAEMON_CONFIG'. Default $COFX_HOME/daemon.json.
+func DaemonConfigFile() string {
+	if v := os.Getenv("COFX_DAEMON_CONFIG"); v != "" {
+		return v
+	}
+	return filepath.Join(HomeDir(), "daemon.json")
+}
+
 func prettyDirPath(p string) string {
 	return filepath.Clean(p) + "/"
 }
@@ -3,12 +3,12 @@ package functiondriver
 import (
 	"context"
 	"errors"
+	"fmt"
 	"path"
 	"strings"
 
-	godriver "github.com/skoowoo/cofx/functiondriver/go"
-	shelldriver "github.com/skoowoo/cofx/functiondriver/shell"
 	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/semver"
 	"github.com/skoowoo/cofx/service/resource"
 )
 
@@ -27,25 +27,27 @@ type Driver interface {
 	StopAndRelease(context.Context) error
 }
 
+// InlineDriver is implemented by the driver that is able to execute the code defined inline in the 'fn'
+// block, the function needn't be loaded by the 'load' statement.
+type InlineDriver interface {
+	Driver
+	// Inline sets the function that returns the options defined by the 'var' statements of the 'fn' block,
+	// the code is defined by the option 'code'.
+	Inline(option func(name string) string)
+}
+
 // New creates a driver instance based on the 'load' information in flowl source file,
 // A Driver instance contains two parts that's driver and function
-func New(l Location) Driver {
-	var dr Driver
-	switch l.DriverName {
-	case godriver.Name:
-		if d := godriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
-			return nil
-		} else {
-			dr = d
-		}
-	case shelldriver.Name:
-		if d := shelldriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
-			return nil
-		} else {
-			dr = d
-		}
+func New(l Location) (Driver, error) {
+	r, ok := Lookup(l.DriverName)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s', the registered drivers are %s", ErrDriverNotFound, l.DriverName, strings.Join(names(), ", "))
 	}
-	return dr
+	d := r.factory(l.FuncName, l.FuncPath, l.Version)
+	if d == nil {
+		return nil, fmt.Errorf("%w: '%s' created nothing", ErrDriverNotFound, l.DriverName)
+	}
+	return d, nil
 }
 
 type Location struct {
@@ -59,11 +61,13 @@ func NewLocation(s string) Location {
 	fields := strings.Split(s, ":")
 	dname, fpath := fields[0], fields[1]
 
+	// The version is a constraint, e.g. 'print@1.2.0', 'print@^1.2', default 'latest'
 	fname := path.Base(fields[1])
-	version := "latest"
+	version := semver.Latest
 	if names := strings.Split(fname, "@"); len(names) == 2 {
 		fname = names[0]
 		version = names[1]
+		fpath = strings.TrimSuffix(fpath, "@"+version)
 	}
 
 	loc := Location{
@@ -76,6 +80,9 @@ func NewLocation(s string) Location {
 }
 
 func (l Location) String() string {
+	if l.Version != "" && l.Version != semver.Latest {
+		return l.DriverName + ":" + l.FuncPath + "@" + l.Version
+	}
 	return l.DriverName + ":" + l.FuncPath
 }
 
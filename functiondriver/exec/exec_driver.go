@@ -0,0 +1,314 @@
+package execdriver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/protocol"
+	"github.com/skoowoo/cofx/functiondriver/store"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/output"
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+const Name = "exec"
+
+// releaseTimeout is how long to wait for the long-lived process to exit after its stdin is closed,
+// the process is killed after the timeout.
+const releaseTimeout = 5 * time.Second
+
+var ErrProcessExited = errors.New("process exited")
+
+// execManifest is the manifest of the exec function, it extends the common manifest with the options of
+// the exec driver.
+type execManifest struct {
+	manifest.Manifest
+	// LongLived makes the process handle many runs over newline-delimited json, the process is started
+	// by the first run and is stopped when the driver is released.
+	LongLived bool `json:"long_lived"`
+}
+
+// ExecDriver is used to execute any executable as a function, the executable and its manifest.json are
+// stored in $COFX_HOME/exec/<function> directory, or the directory given by an absolute path in the
+// 'load' statement. The driver sends the arguments as json on the stdin of the process, and reads the
+// return values as json from the stdout, the lines written into the stderr are the logs of the function.
+type ExecDriver struct {
+	fname   string
+	fpath   string
+	version string
+	// dir is the directory of the loaded function version
+	dir string
+	// manifest be defined by function
+	manifest *execManifest
+	// resources contains some services that can be used by driver self.
+	resources resource.Resources
+
+	// mu serializes the runs of the long-lived process
+	mu   sync.Mutex
+	proc *process
+}
+
+// New creates a new ExecDriver instance to execute the executable functions.
+func New(fname, fpath, version string) *ExecDriver {
+	return &ExecDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+	}
+}
+
+func (d *ExecDriver) program() string {
+	if filepath.IsAbs(d.manifest.Entrypoint) {
+		return d.manifest.Entrypoint
+	}
+	return filepath.Join(d.dir, d.manifest.Entrypoint)
+}
+
+// Load loads the manifest of the function, and checks the executable exists. The highest version that
+// satisfies the version of the 'load' statement is loaded.
+func (d *ExecDriver) Load(ctx context.Context, resources resource.Resources) error {
+	dir, version, err := manifest.FindVersion(store.Locate(Name, d.fpath), d.version)
+	if err != nil {
+		return fmt.Errorf("%w: exec driver load", err)
+	}
+	mfPath := filepath.Join(dir, "manifest.json")
+	file, err := os.Open(mfPath)
+	if err != nil {
+		return fmt.Errorf("%w: exec driver load", err)
+	}
+	defer file.Close()
+	var _manifest execManifest
+	if err := json.NewDecoder(file).Decode(&_manifest); err != nil {
+		return fmt.Errorf("%w: exec driver decode manifest", err)
+	}
+	if _manifest.Entrypoint == "" {
+		return fmt.Errorf("not found entrypoint in exec function: %s", d.fname)
+	}
+	if _manifest.Version == "" {
+		_manifest.Version = version
+	}
+	d.dir = dir
+	d.manifest = &_manifest
+	if _, err := os.Stat(d.program()); err != nil {
+		return fmt.Errorf("%w: not found entrypoint program", err)
+	}
+	d.resources = resources
+	return nil
+}
+
+// Run executes the function, a new process is started for every run, unless the function is long-lived.
+func (d *ExecDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	pretty, ok := d.resources.Logwriter.(resource.OutPrettyPrinter)
+	if ok {
+		defer func() {
+			pretty.Reset()
+		}()
+		pretty.WriteTitle(d.resources.Labels.Get("node_name"), d.Name()+":"+d.FunctionName())
+	}
+	req, err := protocol.NewRequest(d.manifest.Args, args, d.resources.Labels, d.manifest.Version).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.manifest.LongLived {
+		return d.runLongLived(ctx, req)
+	}
+	return d.runOnce(ctx, req)
+}
+
+func (d *ExecDriver) runOnce(ctx context.Context, req []byte) (map[string]string, error) {
+	var stdout bytes.Buffer
+	stderr := d.logOutput()
+
+	cmd := exec.CommandContext(ctx, d.program())
+	cmd.Dir = d.dir
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	stderr.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// The process may exit with an error after writing the response with the error message.
+	rets, perr := protocol.ParseResponse(bytes.TrimSpace(stdout.Bytes()))
+	if err != nil {
+		if perr != nil {
+			return nil, err
+		}
+		if rets.Error == "" {
+			return nil, err
+		}
+	}
+	if perr != nil {
+		return nil, perr
+	}
+	return rets.Values()
+}
+
+func (d *ExecDriver) runLongLived(ctx context.Context, req []byte) (map[string]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.proc == nil || d.proc.isExited() {
+		proc, err := d.startProcess()
+		if err != nil {
+			return nil, err
+		}
+		d.proc = proc
+	}
+	proc := d.proc
+
+	type result struct {
+		line []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	// The request is written in the goroutine too, the write blocks if the process doesn't read its stdin,
+	// the goroutine exits after the process is killed.
+	go func() {
+		if _, err := proc.stdin.Write(req); err != nil {
+			ch <- result{nil, err}
+			return
+		}
+		for {
+			line, err := proc.stdout.ReadBytes('\n')
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 && err == nil {
+				continue
+			}
+			ch <- result{line, err}
+			return
+		}
+	}()
+
+	select {
+	case r := <-ch:
+		if len(r.line) == 0 && r.err != nil {
+			proc.kill()
+			d.proc = nil
+			return nil, fmt.Errorf("%w: %s", ErrProcessExited, r.err.Error())
+		}
+		rets, err := protocol.ParseResponse(r.line)
+		if err != nil {
+			return nil, err
+		}
+		return rets.Values()
+	case <-ctx.Done():
+		// The process is in an unknown state, kill it and start a new one in the next run.
+		proc.kill()
+		d.proc = nil
+		return nil, ctx.Err()
+	}
+}
+
+func (d *ExecDriver) startProcess() (*process, error) {
+	cmd := exec.Command(d.program())
+	cmd.Dir = d.dir
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := d.logOutput()
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		exited: make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		stderr.Close()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// StopAndRelease stops the long-lived process, its stdin is closed first to make it exit gracefully.
+func (d *ExecDriver) StopAndRelease(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.proc == nil {
+		return nil
+	}
+	proc := d.proc
+	d.proc = nil
+	proc.stdin.Close()
+	select {
+	case <-proc.exited:
+	case <-time.After(releaseTimeout):
+		proc.kill()
+	case <-ctx.Done():
+		proc.kill()
+	}
+	return nil
+}
+
+// FunctionName returns the name of the exec function.
+func (d *ExecDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the exec driver.
+func (d *ExecDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the exec function.
+func (d *ExecDriver) Manifest() manifest.Manifest {
+	return d.manifest.Manifest
+}
+
+// logOutput returns a writer that writes the stderr of the process into the log line by line.
+func (d *ExecDriver) logOutput() *output.Output {
+	return &output.Output{
+		HandleFunc: func(line []byte) {
+			if w := d.resources.Logwriter; w != nil {
+				if len(line) > 0 && line[len(line)-1] != '\n' {
+					line = append(line, '\n')
+				}
+				w.Write(line)
+			}
+		},
+	}
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	exited chan struct{}
+}
+
+func (p *process) isExited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *process) kill() {
+	p.cmd.Process.Kill()
+	<-p.exited
+}
@@ -0,0 +1,182 @@
+package execdriver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/protocol"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain makes the testing binary work as the function executable when the environment variable
+// 'COFX_EXEC_TESTING' is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("COFX_EXEC_TESTING") == "noread" {
+		// The function is stuck without reading its stdin
+		time.Sleep(time.Hour)
+	}
+	if os.Getenv("COFX_EXEC_TESTING") != "" {
+		testingFunction()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testingFunction echoes the arguments as the return values, it handles the requests until the stdin
+// is closed, so it works in both modes.
+func testingFunction() {
+	scanner := bufio.NewScanner(os.Stdin)
+	pid := strconv.Itoa(os.Getpid())
+	for scanner.Scan() {
+		var req protocol.Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			fmt.Println(`{"error": "invalid request"}`)
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "log: "+req.Args["message"])
+		switch req.Args["message"] {
+		case "fail":
+			fmt.Println(`{"error": "failed by request"}`)
+			os.Exit(1)
+		case "hang":
+			time.Sleep(time.Hour)
+		}
+		resp := map[string]interface{}{
+			"returns": map[string]interface{}{
+				"message": req.Args["message"],
+				"node":    req.Labels["node_name"],
+				"version": req.Version,
+				"pid":     pid,
+				"number":  1,
+			},
+		}
+		b, _ := json.Marshal(resp)
+		fmt.Println(string(b))
+	}
+}
+
+func loadTestingDriver(t *testing.T, longLived bool, logwriter *bytes.Buffer) *ExecDriver {
+	dir := t.TempDir()
+	os.Setenv("COFX_EXEC_TESTING", "1")
+	t.Cleanup(func() { os.Unsetenv("COFX_EXEC_TESTING") })
+
+	program, err := os.Executable()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := fmt.Sprintf(`{
+		"name": "echo",
+		"driver": "exec",
+		"version": "1.0.0",
+		"entrypoint": %q,
+		"long_lived": %v,
+		"args": {"message": "default"}
+	}`, program, longLived)
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	lbs := make(labels.Labels)
+	lbs.Set("node_name", "echo_node")
+	driver := New("echo", dir, "1.0.0")
+	err = driver.Load(context.Background(), resource.Resources{
+		Logwriter: logwriter,
+		Labels:    lbs,
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	return driver
+}
+
+func TestExecDriver(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := loadTestingDriver(t, false, &buf)
+	assert.Equal(t, "echo", driver.Manifest().Name)
+
+	rets, err := driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", rets["message"])
+	assert.Equal(t, "echo_node", rets["node"])
+	assert.Equal(t, "1.0.0", rets["version"])
+	assert.Equal(t, "1", rets["number"])
+
+	rets2, err := driver.Run(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "default", rets2["message"])
+	// a new process for every run
+	assert.NotEqual(t, rets["pid"], rets2["pid"])
+	assert.Equal(t, "log: hello\nlog: default\n", buf.String())
+
+	_, err = driver.Run(ctx, map[string]string{"message": "fail"})
+	assert.EqualError(t, err, "failed by request")
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = driver.Run(ctx, map[string]string{"message": "hang"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, driver.StopAndRelease(context.Background()))
+}
+
+func TestExecDriverLongLived(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := loadTestingDriver(t, true, &buf)
+
+	rets, err := driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.NoError(t, err)
+	rets2, err := driver.Run(ctx, map[string]string{"message": "world"})
+	assert.NoError(t, err)
+	assert.Equal(t, "world", rets2["message"])
+	// the same process handles all runs
+	assert.Equal(t, rets["pid"], rets2["pid"])
+
+	// the process is restarted after it exited
+	_, err = driver.Run(ctx, map[string]string{"message": "fail"})
+	assert.EqualError(t, err, "failed by request")
+	<-driver.proc.exited
+	rets3, err := driver.Run(ctx, map[string]string{"message": "again"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, rets["pid"], rets3["pid"])
+
+	// the process is killed when the run is canceled
+	tctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = driver.Run(tctx, map[string]string{"message": "hang"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, driver.proc)
+
+	_, err = driver.Run(ctx, map[string]string{"message": "last"})
+	assert.NoError(t, err)
+	proc := driver.proc
+	assert.NoError(t, driver.StopAndRelease(ctx))
+	assert.True(t, proc.isExited())
+	assert.Nil(t, driver.proc)
+	assert.Contains(t, buf.String(), "log: last\n")
+}
+
+func TestExecDriverLongLivedNoRead(t *testing.T) {
+	var buf bytes.Buffer
+	driver := loadTestingDriver(t, true, &buf)
+	os.Setenv("COFX_EXEC_TESTING", "noread")
+
+	// The request is larger than the pipe buffer, so the write blocks until the run is canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := driver.Run(ctx, map[string]string{"message": strings.Repeat("x", 1<<20)})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, driver.proc)
+	assert.NoError(t, driver.StopAndRelease(context.Background()))
+}
@@ -43,7 +43,10 @@ func New(fname, fpath, version string) *GoDriver {
 
 // Load loads the expected function into the driver.
 func (d *GoDriver) Load(ctx context.Context, resources resource.Resources) error {
-	mf, ep, create := std.Lookup(d.fname)
+	mf, ep, create, err := std.LookupVersion(d.fname, d.version)
+	if err != nil {
+		return err
+	}
 	if mf == nil || ep == nil {
 		return errors.New("in std, not found function's manifest or entrypoint: " + d.path)
 	}
@@ -60,7 +63,7 @@ func (d *GoDriver) Load(ctx context.Context, resources resource.Resources) error
 func (d *GoDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
 	merged := d.mergeArgs(args)
 	bundle := spec.EntrypointBundle{
-		Version:   d.version,
+		Version:   d.manifest.Version,
 		Custom:    d.custom,
 		Resources: d.resources,
 	}
@@ -0,0 +1,251 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/textparse"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	readerType   = reflect.TypeOf((*io.Reader)(nil)).Elem()
+)
+
+// argField is an argument described by the tags of a struct field:
+//
+//	type args struct {
+//		Cmd     string        `arg:"cmd,required" desc:"Specify a command to run"`
+//		Env     []string      `arg:"env" desc:"Specify environment variables"`
+//		Timeout time.Duration `arg:"timeout" default:"30s"`
+//		URL     string        `arg:"url,type=url" pattern:"^https://"`
+//		Mode    string        `arg:"mode" enum:"fast,safe" default:"safe"`
+//		Body    io.Reader     `arg:"json_file_path"`
+//	}
+//
+// The type of the argument is inferred from the type of the field, it can be overridden by the option
+// 'type' of the tag 'arg'.
+type argField struct {
+	index int
+	desc  manifest.UsageDesc
+}
+
+func argFields(t reflect.Type) ([]argField, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: expect a struct, got %s", manifest.ErrInvalidArg, t)
+	}
+	var fields []argField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("arg")
+		if !ok || tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		desc := manifest.UsageDesc{
+			Name:    opts[0],
+			Type:    inferType(sf.Type),
+			Default: sf.Tag.Get("default"),
+			Pattern: sf.Tag.Get("pattern"),
+			Desc:    sf.Tag.Get("desc"),
+		}
+		if desc.Name == "" {
+			return nil, fmt.Errorf("%w: field %s has no argument name", manifest.ErrInvalidArg, sf.Name)
+		}
+		for _, opt := range opts[1:] {
+			switch {
+			case opt == "required":
+				desc.Required = true
+			case strings.HasPrefix(opt, "type="):
+				desc.Type = strings.TrimPrefix(opt, "type=")
+			default:
+				return nil, fmt.Errorf("%w: field %s has an unknown option '%s'", manifest.ErrInvalidArg, sf.Name, opt)
+			}
+		}
+		if enum := sf.Tag.Get("enum"); enum != "" {
+			desc.Enum = textparse.String2Slice(enum)
+		}
+		fields = append(fields, argField{index: i, desc: desc})
+	}
+	return fields, nil
+}
+
+func inferType(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return manifest.TypeDuration
+	case t == readerType:
+		return manifest.TypePath
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return manifest.TypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return manifest.TypeInt
+	case reflect.Bool:
+		return manifest.TypeBool
+	case reflect.Float32, reflect.Float64:
+		return manifest.TypeString
+	case reflect.Slice:
+		if isBasic(t.Elem()) {
+			return manifest.TypeList
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String {
+			return manifest.TypeList
+		}
+	}
+	return manifest.TypeJSON
+}
+
+func isBasic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// ArgsUsage returns the usage of the arguments described by the tags of the struct, so the manifest and
+// the parsing of the arguments can't drift apart. It panics if the tags are invalid, it's expected to be
+// called when initializing the manifest of the function.
+func ArgsUsage(v interface{}) []manifest.UsageDesc {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields, err := argFields(t)
+	if err != nil {
+		panic(err)
+	}
+	var usage []manifest.UsageDesc
+	for _, f := range fields {
+		usage = append(usage, f.desc)
+	}
+	return usage
+}
+
+// Decode decodes the arguments into the struct pointed by v, the fields are described by the tags, see
+// argField. The missing arguments are set to the default value, and the values are validated by the
+// type, pattern and enum of the arguments. A string is split into a slice by ',' or '\n', and a map is
+// from the items like 'k1=v1, k2: v2'. The value of an io.Reader is a file path, the file is read
+// into memory.
+func (e EntrypointArgs) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("%w: expect a pointer to struct, got %T", manifest.ErrInvalidArg, v)
+	}
+	rv = rv.Elem()
+	fields, err := argFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		s, ok := e[f.desc.Name]
+		if !ok || s == "" {
+			s = f.desc.Default
+		}
+		if s == "" {
+			if f.desc.Required {
+				return fmt.Errorf("%w: '%s'", manifest.ErrMissingArg, f.desc.Name)
+			}
+			continue
+		}
+		if err := f.desc.Check(s); err != nil {
+			return err
+		}
+		if err := setValue(rv.Field(f.index), s); err != nil {
+			return fmt.Errorf("%w: '%s' value '%s', %s", manifest.ErrInvalidArg, f.desc.Name, s, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, s string) error {
+	t := v.Type()
+	switch {
+	case t == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case t == readerType:
+		b, err := os.ReadFile(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(bytes.NewReader(b)))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := EntrypointArgs{"_": s}.GetBool("_")
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if !isBasic(t.Elem()) {
+			return json.Unmarshal([]byte(s), v.Addr().Interface())
+		}
+		items := textparse.String2Slice(s)
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+			return json.Unmarshal([]byte(s), v.Addr().Interface())
+		}
+		m := reflect.MakeMap(t)
+		for _, item := range textparse.String2Slice(s) {
+			i := strings.IndexAny(item, "=:")
+			if i <= 0 {
+				return fmt.Errorf("expect 'key=value' or 'key: value', got '%s'", item)
+			}
+			k, val := strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+			m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), reflect.ValueOf(val).Convert(t.Elem()))
+		}
+		v.Set(m)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
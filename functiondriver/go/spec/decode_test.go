@@ -0,0 +1,125 @@
+package spec
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+type testArgs struct {
+	Cmd     string            `arg:"cmd,required" desc:"A command"`
+	Count   int               `arg:"count" default:"3"`
+	Verbose bool              `arg:"verbose"`
+	Timeout time.Duration     `arg:"timeout" default:"30s"`
+	Fields  []int             `arg:"fields"`
+	Names   []string          `arg:"names"`
+	Headers map[string]string `arg:"headers"`
+	URL     string            `arg:"url,type=url"`
+	Mode    string            `arg:"mode" enum:"fast,safe" default:"safe"`
+	Body    io.Reader         `arg:"body"`
+	Extra   struct {
+		A int `json:"a"`
+	} `arg:"extra"`
+	Ignored string
+}
+
+func TestDecode(t *testing.T) {
+	body := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(body, []byte(`{"hello": "cofx"}`), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	var a testArgs
+	err := EntrypointArgs{
+		"cmd":     "echo hello",
+		"verbose": "yes",
+		"fields":  "0, 1,2",
+		"names":   "a\nb",
+		"headers": "Accept: application/json, Authorization: Bearer x=",
+		"url":     "https://github.com",
+		"body":    body,
+		"extra":   `{"a": 1}`,
+		"unknown": "ignored",
+	}.Decode(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, "echo hello", a.Cmd)
+	assert.Equal(t, 3, a.Count)
+	assert.True(t, a.Verbose)
+	assert.Equal(t, 30*time.Second, a.Timeout)
+	assert.Equal(t, []int{0, 1, 2}, a.Fields)
+	assert.Equal(t, []string{"a", "b"}, a.Names)
+	assert.Equal(t, map[string]string{"Accept": "application/json", "Authorization": "Bearer x="}, a.Headers)
+	assert.Equal(t, "https://github.com", a.URL)
+	assert.Equal(t, "safe", a.Mode)
+	assert.Equal(t, 1, a.Extra.A)
+	b, err := io.ReadAll(a.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"hello": "cofx"}`, string(b))
+
+	testcases := []struct {
+		args EntrypointArgs
+		err  error
+	}{
+		{EntrypointArgs{}, manifest.ErrMissingArg},
+		{EntrypointArgs{"cmd": "ls", "count": "x"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "timeout": "10"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "fields": "0,x"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "headers": "x"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "url": "github.com"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "mode": "slow"}, manifest.ErrInvalidArg},
+		{EntrypointArgs{"cmd": "ls", "body": filepath.Join(t.TempDir(), "missing")}, manifest.ErrInvalidArg},
+	}
+	for _, c := range testcases {
+		var a testArgs
+		assert.ErrorIs(t, c.args.Decode(&a), c.err, c.args)
+	}
+
+	assert.ErrorIs(t, EntrypointArgs{}.Decode(a), manifest.ErrInvalidArg)
+	var bad struct {
+		Name string `arg:"name,optional"`
+	}
+	assert.ErrorIs(t, EntrypointArgs{}.Decode(&bad), manifest.ErrInvalidArg)
+}
+
+func TestArgsUsage(t *testing.T) {
+	usage := ArgsUsage(&testArgs{})
+	assert.Len(t, usage, 11)
+	assert.Equal(t, manifest.UsageDesc{Name: "cmd", Type: manifest.TypeString, Required: true, Desc: "A command"}, usage[0])
+
+	types := make(map[string]string)
+	for _, u := range usage {
+		types[u.Name] = u.Type
+	}
+	assert.Equal(t, map[string]string{
+		"cmd":     manifest.TypeString,
+		"count":   manifest.TypeInt,
+		"verbose": manifest.TypeBool,
+		"timeout": manifest.TypeDuration,
+		"fields":  manifest.TypeList,
+		"names":   manifest.TypeList,
+		"headers": manifest.TypeList,
+		"url":     manifest.TypeURL,
+		"mode":    manifest.TypeString,
+		"body":    manifest.TypePath,
+		"extra":   manifest.TypeJSON,
+	}, types)
+	assert.Equal(t, []string{"fast", "safe"}, usage[8].Enum)
+	assert.Equal(t, "safe", usage[8].Default)
+
+	// The usage validates the same values as Decode
+	mf := manifest.Usage{Args: usage}
+	assert.NoError(t, mf.Validate(map[string]string{"cmd": "ls", "count": "1"}))
+	assert.ErrorIs(t, mf.Validate(map[string]string{"count": "1"}), manifest.ErrMissingArg)
+	assert.ErrorIs(t, mf.Validate(map[string]string{"cmd": "ls", "count": "x"}), manifest.ErrInvalidArg)
+
+	assert.Panics(t, func() {
+		ArgsUsage(struct {
+			Name string `arg:""`
+		}{})
+	})
+}
@@ -96,8 +96,9 @@ func (e EntrypointArgs) Get(name string, typ ArgValType) (interface{}, error) {
 	case isInt:
 		n, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return n, nil
+			return nil, err
 		}
+		return n, nil
 	case isBool:
 		if s := strings.ToLower(v); s == "true" || s == "yes" || s == "1" {
 			return true, nil
@@ -16,3 +16,20 @@ func TestFunc2Name(t *testing.T) {
 	name := Func2Name(f)
 	assert.Equal(t, "github.com/skoowoo/cofx/functiondriver/go/spec.IsAFunction", name)
 }
+
+func TestEntrypointArgsGet(t *testing.T) {
+	args := EntrypointArgs{"n": "12", "bad": "1x", "b": "yes"}
+
+	n, err := args.GetInt("n")
+	assert.NoError(t, err)
+	assert.Equal(t, 12, n)
+
+	_, err = args.GetInt("bad")
+	assert.Error(t, err)
+	_, err = args.GetInt("missing")
+	assert.Error(t, err)
+
+	b, err := args.GetBool("b")
+	assert.NoError(t, err)
+	assert.True(t, b)
+}
@@ -0,0 +1,90 @@
+// Package protocol defines the json messages exchanged between the drivers and the functions that run
+// out of the cofx process, e.g. the exec and wasm functions.
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+var ErrInvalidResponse = errors.New("invalid response")
+
+// Request is sent to the function as a json line.
+type Request struct {
+	Args    map[string]string `json:"args"`
+	Labels  map[string]string `json:"labels"`
+	Version string            `json:"version"`
+}
+
+// NewRequest creates a request, the 'args' are merged with the default arguments of the manifest.
+func NewRequest(defaults, args map[string]string, labels resource.LabelManger, version string) Request {
+	merged := make(map[string]string)
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	lbs := make(map[string]string)
+	if labels != nil {
+		lbs["flow_id"] = labels.GetFlowID()
+		lbs["node_seq"] = labels.GetNodeSeq()
+		lbs["node_name"] = labels.GetNodeName()
+	}
+	return Request{
+		Args:    merged,
+		Labels:  lbs,
+		Version: version,
+	}
+}
+
+// Marshal returns the json line of the request.
+func (r Request) Marshal() ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Response is returned by the function as a json line, the values that aren't strings in 'returns' are
+// converted to json strings.
+type Response struct {
+	Returns map[string]interface{} `json:"returns"`
+	Error   string                 `json:"error"`
+}
+
+// ParseResponse parses a json line into the response.
+func ParseResponse(line []byte) (*Response, error) {
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidResponse)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidResponse, err.Error())
+	}
+	return &resp, nil
+}
+
+// Values returns the return values of the function, or the error returned by the function.
+func (r *Response) Values() (map[string]string, error) {
+	if r.Error != "" {
+		return nil, errors.New(r.Error)
+	}
+	values := make(map[string]string)
+	for k, v := range r.Returns {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case nil:
+			values[k] = ""
+		default:
+			b, _ := json.Marshal(v)
+			values[k] = string(b)
+		}
+	}
+	return values, nil
+}
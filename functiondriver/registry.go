@@ -0,0 +1,143 @@
+package functiondriver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	execdriver "github.com/skoowoo/cofx/functiondriver/exec"
+	godriver "github.com/skoowoo/cofx/functiondriver/go"
+	scriptdriver "github.com/skoowoo/cofx/functiondriver/script"
+	shelldriver "github.com/skoowoo/cofx/functiondriver/shell"
+	wasmdriver "github.com/skoowoo/cofx/functiondriver/wasm"
+)
+
+var (
+	ErrDriverNotFound   = errors.New("driver not found")
+	ErrDriverRegistered = errors.New("driver registered")
+)
+
+// Factory creates a driver instance of the function, the arguments are got from the 'load' statement.
+type Factory func(fname, fpath, version string) Driver
+
+// Capabilities describes the features supported by a driver.
+type Capabilities struct {
+	// Versions means the driver is able to load the different versions of a function.
+	Versions bool `json:"versions"`
+	// LongLived means the function is able to run as a long-lived process that handles many runs.
+	LongLived bool `json:"long_lived"`
+	// Sandboxed means the function can't access the host unless the permissions are granted.
+	Sandboxed bool `json:"sandboxed"`
+	// Inline means the code of the function can be defined inline in the 'fn' block, the driver must
+	// implement the InlineDriver interface.
+	Inline bool `json:"inline"`
+}
+
+// Registered is a driver in the registry.
+type Registered struct {
+	Name         string
+	Capabilities Capabilities
+	factory      Factory
+}
+
+var registry = struct {
+	sync.RWMutex
+	drivers map[string]Registered
+}{
+	drivers: make(map[string]Registered),
+}
+
+// Register adds a driver into the registry, so the functions of the driver can be loaded by the 'load'
+// statement, e.g. load "<name>:<function>". It's usually called in the init function of the package that
+// implements the driver.
+func Register(name string, factory Factory, caps Capabilities) error {
+	if name == "" || strings.Contains(name, ":") {
+		return fmt.Errorf("invalid driver name '%s'", name)
+	}
+	if factory == nil {
+		return fmt.Errorf("factory is nil of driver '%s'", name)
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.drivers[name]; ok {
+		return fmt.Errorf("%w: '%s'", ErrDriverRegistered, name)
+	}
+	registry.drivers[name] = Registered{
+		Name:         name,
+		Capabilities: caps,
+		factory:      factory,
+	}
+	return nil
+}
+
+// Lookup returns the registered driver by name.
+func Lookup(name string) (Registered, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	r, ok := registry.drivers[name]
+	return r, ok
+}
+
+// ListAll returns all registered drivers, they are sorted by name.
+func ListAll() []Registered {
+	registry.RLock()
+	defer registry.RUnlock()
+	all := make([]Registered, 0, len(registry.drivers))
+	for _, r := range registry.drivers {
+		all = append(all, r)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+func names() []string {
+	var names []string
+	for _, r := range ListAll() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func init() {
+	builtins := []struct {
+		name    string
+		factory Factory
+		caps    Capabilities
+	}{
+		{
+			godriver.Name,
+			func(fname, fpath, version string) Driver { return godriver.New(fname, fpath, version) },
+			Capabilities{Versions: true},
+		},
+		{
+			shelldriver.Name,
+			func(fname, fpath, version string) Driver { return shelldriver.New(fname, fpath, version) },
+			Capabilities{Versions: true},
+		},
+		{
+			execdriver.Name,
+			func(fname, fpath, version string) Driver { return execdriver.New(fname, fpath, version) },
+			Capabilities{Versions: true, LongLived: true},
+		},
+		{
+			wasmdriver.Name,
+			func(fname, fpath, version string) Driver { return wasmdriver.New(fname, fpath, version) },
+			Capabilities{Versions: true, Sandboxed: true},
+		},
+		{
+			// Not sandboxed, the builtin 'call' can call the shell and exec functions loaded by the flow
+			scriptdriver.Name,
+			func(fname, fpath, version string) Driver { return scriptdriver.New(fname, fpath, version) },
+			Capabilities{Versions: true, Inline: true},
+		},
+	}
+	for _, b := range builtins {
+		if err := Register(b.name, b.factory, b.caps); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package functiondriver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+type testingDriver struct {
+	fname string
+}
+
+func (d *testingDriver) Name() string                                   { return "testing" }
+func (d *testingDriver) FunctionName() string                           { return d.fname }
+func (d *testingDriver) Manifest() manifest.Manifest                    { return manifest.Manifest{Name: d.fname} }
+func (d *testingDriver) Load(context.Context, resource.Resources) error { return nil }
+func (d *testingDriver) StopAndRelease(context.Context) error           { return nil }
+func (d *testingDriver) Run(context.Context, map[string]string) (map[string]string, error) {
+	return nil, nil
+}
+
+func TestRegister(t *testing.T) {
+	factory := func(fname, fpath, version string) Driver {
+		return &testingDriver{fname: fname}
+	}
+	assert.NoError(t, Register("testing", factory, Capabilities{Versions: true}))
+	// Unregister the driver, so the registry is left as it was for the other tests
+	t.Cleanup(func() {
+		registry.Lock()
+		defer registry.Unlock()
+		delete(registry.drivers, "testing")
+	})
+	assert.ErrorIs(t, Register("testing", factory, Capabilities{}), ErrDriverRegistered)
+	assert.Error(t, Register("bad:name", factory, Capabilities{}))
+	assert.Error(t, Register("nil", nil, Capabilities{}))
+
+	r, ok := Lookup("testing")
+	assert.True(t, ok)
+	assert.True(t, r.Capabilities.Versions)
+
+	d, err := New(NewLocation("testing:foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", d.FunctionName())
+
+	var names []string
+	for _, r := range ListAll() {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"exec", "go", "script", "shell", "testing", "wasm"}, names)
+
+	_, err = New(NewLocation("unknown:foo"))
+	assert.ErrorIs(t, err, ErrDriverNotFound)
+	assert.Contains(t, err.Error(), "exec, go, script, shell, testing, wasm")
+}
+
+func TestBuiltinCapabilities(t *testing.T) {
+	sandboxed := make(map[string]bool)
+	for _, r := range ListAll() {
+		sandboxed[r.Name] = r.Capabilities.Sandboxed
+	}
+	assert.True(t, sandboxed["wasm"])
+	// The script can call the shell and exec functions
+	assert.False(t, sandboxed["script"])
+	assert.False(t, sandboxed["shell"])
+}
@@ -0,0 +1,181 @@
+package scriptdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/skoowoo/cofx/service/resource"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// The builtins can be used by the script besides the universal builtins of Starlark:
+//
+//	json.encode(x), json.decode(s), json.indent(s)
+//	re.match(pattern, s)         the groups of the first match, None if not matched
+//	re.find_all(pattern, s)      all matched strings
+//	re.sub(pattern, repl, s)     replaces all matches with 'repl', '$1' refers to the group
+//	re.split(pattern, s)         splits the string by the pattern
+//	call(fname, args)            calls a function loaded by the flow, returns its return values
+
+// maxCallDepth limits the depth of the nested calls between the script functions.
+const maxCallDepth = 16
+
+var ErrCallTooDeep = errors.New("call too deep")
+
+const localContext = "context"
+
+type callDepthKey struct{}
+
+func predeclared(functions resource.FunctionCaller) starlark.StringDict {
+	return starlark.StringDict{
+		"json": starlarkjson.Module,
+		"re": &starlarkstruct.Module{
+			Name: "re",
+			Members: starlark.StringDict{
+				"match":    starlark.NewBuiltin("re.match", reMatch),
+				"find_all": starlark.NewBuiltin("re.find_all", reFindAll),
+				"sub":      starlark.NewBuiltin("re.sub", reSub),
+				"split":    starlark.NewBuiltin("re.split", reSplit),
+			},
+		},
+		"call": starlark.NewBuiltin("call", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return call(thread, b, args, kwargs, functions)
+		}),
+	}
+}
+
+func compile(b *starlark.Builtin, pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	return re, nil
+}
+
+func reMatch(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := compile(b, pattern)
+	if err != nil {
+		return nil, err
+	}
+	groups := re.FindStringSubmatch(s)
+	if groups == nil {
+		return starlark.None, nil
+	}
+	return toList(groups), nil
+}
+
+func reFindAll(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := compile(b, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return toList(re.FindAllString(s, -1)), nil
+}
+
+func reSub(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, repl, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &pattern, &repl, &s); err != nil {
+		return nil, err
+	}
+	re, err := compile(b, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return starlark.String(re.ReplaceAllString(s, repl)), nil
+}
+
+func reSplit(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var pattern, s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &pattern, &s); err != nil {
+		return nil, err
+	}
+	re, err := compile(b, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return toList(re.Split(s, -1)), nil
+}
+
+func call(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple, functions resource.FunctionCaller) (starlark.Value, error) {
+	var (
+		fname string
+		fargs *starlark.Dict
+	)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "fname", &fname, "args?", &fargs); err != nil {
+		return nil, err
+	}
+	if functions == nil {
+		return nil, fmt.Errorf("%s: not able to call functions", b.Name())
+	}
+	ctx, _ := thread.Local(localContext).(context.Context)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	depth, _ := ctx.Value(callDepthKey{}).(int)
+	if depth >= maxCallDepth {
+		return nil, fmt.Errorf("%w: '%s'", ErrCallTooDeep, fname)
+	}
+	ctx = context.WithValue(ctx, callDepthKey{}, depth+1)
+
+	input := make(map[string]string)
+	if fargs != nil {
+		var err error
+		if input, err = fromDict(thread, fargs); err != nil {
+			return nil, err
+		}
+	}
+	rets, err := functions.Call(ctx, fname, input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", b.Name(), err)
+	}
+	dict := starlark.NewDict(len(rets))
+	for k, v := range rets {
+		dict.SetKey(starlark.String(k), starlark.String(v))
+	}
+	return dict, nil
+}
+
+func toList(ss []string) *starlark.List {
+	elems := make([]starlark.Value, 0, len(ss))
+	for _, s := range ss {
+		elems = append(elems, starlark.String(s))
+	}
+	return starlark.NewList(elems)
+}
+
+// fromDict converts a dict into string values, the string keeps itself, None is converted into an empty
+// string, other values are encoded as json.
+func fromDict(thread *starlark.Thread, dict *starlark.Dict) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, item := range dict.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			return nil, fmt.Errorf("%w: key %s isn't a string", ErrInvalidReturn, item[0].String())
+		}
+		switch v := item[1].(type) {
+		case starlark.String:
+			values[k] = string(v)
+		case starlark.NoneType:
+			values[k] = ""
+		default:
+			encoded, err := starlark.Call(thread, starlarkjson.Module.Members["encode"], starlark.Tuple{v}, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%w: '%s', %s", ErrInvalidReturn, k, err.Error())
+			}
+			values[k] = string(encoded.(starlark.String))
+		}
+	}
+	return values, nil
+}
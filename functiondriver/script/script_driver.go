@@ -0,0 +1,278 @@
+package scriptdriver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/skoowoo/cofx/functiondriver/store"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/semver"
+	"github.com/skoowoo/cofx/service/resource"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const Name = "script"
+
+// DefaultMaxSteps is the default limit of the execution steps of a run, the run is canceled when it
+// exceeds the limit, so a runaway script can't hang the flow.
+const DefaultMaxSteps = 10000000
+
+var (
+	ErrNotFoundMain   = errors.New("not found main")
+	ErrInvalidReturn  = errors.New("invalid return")
+	ErrInvalidOption  = errors.New("invalid option")
+	ErrTooManySteps   = errors.New("too many steps")
+	ErrNotFoundInline = errors.New("not found inline code")
+)
+
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// scriptManifest is the manifest of the script function, it extends the common manifest with the options
+// of the script driver.
+type scriptManifest struct {
+	manifest.Manifest
+	// MaxSteps is the limit of the execution steps of a run, default DefaultMaxSteps.
+	MaxSteps uint64 `json:"max_steps"`
+}
+
+// ScriptDriver is used to execute the Starlark script functions. A script function is a '.star' file in
+// $COFX_HOME/script directory, or a directory with a manifest.json and a '.star' file, it also can be
+// defined inline by the 'code' option of the 'fn' block:
+//
+//	fn reshape = script {
+//		var code = "
+//	def main(args):
+//	    return {'title': args['name'].upper()}
+//	"
+//	}
+//
+// The script defines the function 'main', it receives the arguments as a dict and returns a dict, the
+// messages printed by the script are the logs of the function.
+type ScriptDriver struct {
+	fname   string
+	fpath   string
+	version string
+	// manifest be defined by function
+	manifest *scriptManifest
+	// resources contains some services that can be used by driver self.
+	resources resource.Resources
+	// option returns the option defined in the 'fn' block, it's only set for the inline function.
+	option  func(name string) string
+	program *starlark.Program
+}
+
+// New creates a new ScriptDriver instance to execute the script functions.
+func New(fname, fpath, version string) *ScriptDriver {
+	return &ScriptDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+	}
+}
+
+// Inline makes the driver execute the code defined by the option 'code' of the 'fn' block, the option
+// 'max_steps' overrides the default limit of the execution steps.
+func (d *ScriptDriver) Inline(option func(name string) string) {
+	d.option = option
+}
+
+// Load loads and compiles the script of the function.
+func (d *ScriptDriver) Load(ctx context.Context, resources resource.Resources) error {
+	var (
+		_manifest *scriptManifest
+		filename  string
+		src       []byte
+		err       error
+	)
+	if d.option != nil {
+		if _manifest, err = d.inlineManifest(); err != nil {
+			return err
+		}
+		filename = d.fname + ".star"
+		src = []byte(d.option("code"))
+	} else {
+		if _manifest, filename, err = d.loadManifest(); err != nil {
+			return err
+		}
+		if src, err = os.ReadFile(filename); err != nil {
+			return fmt.Errorf("%w: read script", err)
+		}
+	}
+	if _manifest.MaxSteps == 0 {
+		_manifest.MaxSteps = DefaultMaxSteps
+	}
+
+	names := predeclared(nil)
+	_, program, err := starlark.SourceProgramOptions(fileOptions, filename, src, names.Has)
+	if err != nil {
+		return fmt.Errorf("%w: compile script", err)
+	}
+
+	d.manifest = _manifest
+	d.resources = resources
+	d.program = program
+	return nil
+}
+
+func (d *ScriptDriver) inlineManifest() (*scriptManifest, error) {
+	if d.option("code") == "" {
+		return nil, fmt.Errorf("%w: '%s'", ErrNotFoundInline, d.fname)
+	}
+	mf := &scriptManifest{
+		Manifest: manifest.Manifest{
+			Name:   d.fname,
+			Driver: Name,
+		},
+	}
+	if v := d.option("max_steps"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: max_steps '%s'", ErrInvalidOption, v)
+		}
+		mf.MaxSteps = n
+	}
+	return mf, nil
+}
+
+// loadManifest returns the manifest and the script file of the function, the manifest of a single '.star'
+// file is generated, and it has no version.
+func (d *ScriptDriver) loadManifest() (*scriptManifest, string, error) {
+	base := store.Locate(Name, d.fpath)
+	if st, err := os.Stat(base + ".star"); err == nil && !st.IsDir() {
+		if c, err := semver.ParseConstraint(d.version); err != nil || !c.Any() {
+			return nil, "", fmt.Errorf("%w: '%s' of %s, the function isn't versioned", manifest.ErrVersionNotFound,
+				d.version, d.fname)
+		}
+		mf := &scriptManifest{
+			Manifest: manifest.Manifest{
+				Name:       d.fname,
+				Driver:     Name,
+				Entrypoint: filepath.Base(base) + ".star",
+			},
+		}
+		return mf, base + ".star", nil
+	}
+
+	dir, version, err := manifest.FindVersion(base, d.version)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: script driver load", err)
+	}
+	file, err := os.Open(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: script driver load", err)
+	}
+	defer file.Close()
+	var _manifest scriptManifest
+	if err := json.NewDecoder(file).Decode(&_manifest); err != nil {
+		return nil, "", fmt.Errorf("%w: script driver decode manifest", err)
+	}
+	if _manifest.Entrypoint == "" {
+		return nil, "", fmt.Errorf("not found entrypoint in script function: %s", d.fname)
+	}
+	if _manifest.Version == "" {
+		_manifest.Version = version
+	}
+	return &_manifest, filepath.Join(dir, _manifest.Entrypoint), nil
+}
+
+// Run calls the function 'main' of the script, a new thread is created for every run, so the global
+// variables of the script aren't shared between runs.
+func (d *ScriptDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	pretty, ok := d.resources.Logwriter.(resource.OutPrettyPrinter)
+	if ok {
+		defer func() {
+			pretty.Reset()
+		}()
+		pretty.WriteTitle(d.resources.Labels.Get("node_name"), d.Name()+":"+d.FunctionName())
+	}
+
+	thread := &starlark.Thread{
+		Name: d.fname,
+		Print: func(_ *starlark.Thread, msg string) {
+			if w := d.resources.Logwriter; w != nil {
+				w.Write([]byte(msg + "\n"))
+			}
+		},
+	}
+	thread.SetLocal(localContext, ctx)
+	thread.SetMaxExecutionSteps(d.manifest.MaxSteps)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	globals, err := d.program.Init(thread, predeclared(d.resources.Functions))
+	if err != nil {
+		return nil, d.runError(ctx, thread, err)
+	}
+	main, ok := globals["main"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%w: in script function %s", ErrNotFoundMain, d.fname)
+	}
+
+	input := starlark.NewDict(len(args))
+	for k, v := range d.manifest.Args {
+		input.SetKey(starlark.String(k), starlark.String(v))
+	}
+	for k, v := range args {
+		input.SetKey(starlark.String(k), starlark.String(v))
+	}
+	ret, err := starlark.Call(thread, main, starlark.Tuple{input}, nil)
+	if err != nil {
+		return nil, d.runError(ctx, thread, err)
+	}
+	switch ret := ret.(type) {
+	case starlark.NoneType:
+		return map[string]string{}, nil
+	case *starlark.Dict:
+		return fromDict(thread, ret)
+	}
+	return nil, fmt.Errorf("%w: main returns %s, expect dict", ErrInvalidReturn, ret.Type())
+}
+
+func (d *ScriptDriver) runError(ctx context.Context, thread *starlark.Thread, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if thread.ExecutionSteps() >= d.manifest.MaxSteps {
+		return fmt.Errorf("%w: exceeded %d in script function %s", ErrTooManySteps, d.manifest.MaxSteps, d.fname)
+	}
+	return err
+}
+
+// StopAndRelease is used to stop and release the all resources.
+func (d *ScriptDriver) StopAndRelease(ctx context.Context) error {
+	return nil
+}
+
+// FunctionName returns the name of the script function.
+func (d *ScriptDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the script driver.
+func (d *ScriptDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the script function.
+func (d *ScriptDriver) Manifest() manifest.Manifest {
+	return d.manifest.Manifest
+}
@@ -0,0 +1,197 @@
+package scriptdriver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+type testingCaller struct{}
+
+func (testingCaller) Call(ctx context.Context, fname string, args map[string]string) (map[string]string, error) {
+	if fname != "upper" {
+		return nil, errors.New("function not loaded")
+	}
+	return map[string]string{"out": strings.ToUpper(args["in"])}, nil
+}
+
+func testingResources(logwriter *bytes.Buffer) resource.Resources {
+	lbs := make(labels.Labels)
+	lbs.Set("node_name", "script_node")
+	return resource.Resources{
+		Logwriter: logwriter,
+		Labels:    lbs,
+		Functions: testingCaller{},
+	}
+}
+
+func inline(options map[string]string) func(string) string {
+	return func(name string) string {
+		return options[name]
+	}
+}
+
+func TestScriptDriverFile(t *testing.T) {
+	dir := t.TempDir()
+	script := `
+def main(args):
+    print("title: " + args["title"])
+    m = re.match(r"v(\d+)\.(\d+)", args["title"])
+    data = json.decode(args["data"])
+    return {
+        "major": m[1],
+        "minor": m[2],
+        "words": re.split(r"\s+", args["title"]),
+        "count": len(data["items"]),
+        "none": None,
+        "called": call("upper", {"in": "abc"})["out"],
+    }
+`
+	if err := os.WriteFile(filepath.Join(dir, "reshape.star"), []byte(script), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := New("reshape", filepath.Join(dir, "reshape"), "latest")
+	assert.NoError(t, driver.Load(ctx, testingResources(&buf)))
+	assert.Equal(t, "reshape", driver.Manifest().Name)
+	assert.Equal(t, "reshape.star", driver.Manifest().Entrypoint)
+
+	rets, err := driver.Run(ctx, map[string]string{
+		"title": "release v1.2 now",
+		"data":  `{"items": [1, 2, 3]}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"major":  "1",
+		"minor":  "2",
+		"words":  `["release","v1.2","now"]`,
+		"count":  "3",
+		"none":   "",
+		"called": "ABC",
+	}, rets)
+	assert.Equal(t, "title: release v1.2 now\n", buf.String())
+}
+
+func TestScriptDriverManifest(t *testing.T) {
+	dir := t.TempDir()
+	mf := `{"name": "hello", "driver": "script", "entrypoint": "main.star", "args": {"name": "cofx"}}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	script := `
+def main(args):
+    return {"greeting": "hello " + args["name"]}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.star"), []byte(script), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := New("hello", dir, "latest")
+	assert.NoError(t, driver.Load(ctx, testingResources(&buf)))
+	rets, err := driver.Run(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello cofx", rets["greeting"])
+	rets, err = driver.Run(ctx, map[string]string{"name": "world"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", rets["greeting"])
+}
+
+func TestScriptDriverInline(t *testing.T) {
+	testings := []struct {
+		options map[string]string
+		args    map[string]string
+		expect  map[string]string
+		err     error
+	}{
+		{
+			map[string]string{"code": "def main(args):\n    return {'out': re.sub('o', '0', args['in'])}"},
+			map[string]string{"in": "foo"},
+			map[string]string{"out": "f00"},
+			nil,
+		},
+		{
+			map[string]string{"code": "def main(args):\n    pass"},
+			nil,
+			map[string]string{},
+			nil,
+		},
+		{
+			map[string]string{"code": "def main(args):\n    return [1]"},
+			nil,
+			nil,
+			ErrInvalidReturn,
+		},
+		{
+			map[string]string{"code": "x = 1"},
+			nil,
+			nil,
+			ErrNotFoundMain,
+		},
+		{
+			map[string]string{"code": "def main(args):\n    while True:\n        pass", "max_steps": "1000"},
+			nil,
+			nil,
+			ErrTooManySteps,
+		},
+	}
+
+	for _, tt := range testings {
+		var buf bytes.Buffer
+		ctx := context.Background()
+		driver := New("inline", "", "inline")
+		driver.Inline(inline(tt.options))
+		assert.NoError(t, driver.Load(ctx, testingResources(&buf)))
+
+		rets, err := driver.Run(ctx, tt.args)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expect, rets)
+	}
+}
+
+func TestScriptDriverLoadFailed(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+
+	driver := New("inline", "", "inline")
+	driver.Inline(inline(map[string]string{}))
+	assert.ErrorIs(t, driver.Load(ctx, testingResources(&buf)), ErrNotFoundInline)
+
+	driver = New("inline", "", "inline")
+	driver.Inline(inline(map[string]string{"code": "def main(args)\n    pass"}))
+	assert.Error(t, driver.Load(ctx, testingResources(&buf)))
+
+	driver = New("inline", "", "inline")
+	driver.Inline(inline(map[string]string{"code": "def main(args):\n    pass", "max_steps": "many"}))
+	assert.ErrorIs(t, driver.Load(ctx, testingResources(&buf)), ErrInvalidOption)
+}
+
+func TestScriptDriverCanceled(t *testing.T) {
+	var buf bytes.Buffer
+	driver := New("inline", "", "inline")
+	driver.Inline(inline(map[string]string{
+		"code":      "def main(args):\n    while True:\n        pass",
+		"max_steps": "100000000000",
+	}))
+	assert.NoError(t, driver.Load(context.Background(), testingResources(&buf)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := driver.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
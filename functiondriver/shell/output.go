@@ -0,0 +1,124 @@
+package shelldriver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A shell function returns values to the flow in two ways, they can be used together:
+//
+// 1. Write into the file of the environment variable 'COFX_OUTPUT', the content of the file is a json
+// object, or 'key=value' lines. A multi-line value uses the delimiter syntax:
+//
+//	echo "version=1.0.0" >> $COFX_OUTPUT
+//	echo "changelog<<EOF" >> $COFX_OUTPUT
+//	git log --oneline -3 >> $COFX_OUTPUT
+//	echo "EOF" >> $COFX_OUTPUT
+//
+// 2. Print a marker line into the stdout, the marker isn't written into the log. '%25', '%0D' and '%0A'
+// in the value are unescaped to '%', '\r' and '\n':
+//
+//	echo "::set-output name=version::1.0.0"
+//
+// The values in the file override the values in the markers with the same name. If the manifest declares
+// the return values, the function must return all of them and nothing else.
+
+const outputEnv = "COFX_OUTPUT"
+
+var (
+	ErrInvalidOutput         = errors.New("invalid output")
+	ErrUndeclaredReturnValue = errors.New("undeclared return value")
+	ErrMissingReturnValue    = errors.New("missing return value")
+)
+
+var setOutputRegexp = regexp.MustCompile(`^::set-output name=([^:]+)::(.*)$`)
+
+// parseSetOutput parses a marker line of the stdout, returns false if the line isn't a marker.
+func parseSetOutput(line string) (string, string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	m := setOutputRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	v := strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%").Replace(m[2])
+	return strings.TrimSpace(m[1]), v, true
+}
+
+// parseOutputFile parses the content of the output file into return values.
+func parseOutputFile(content []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	trimed := bytes.TrimSpace(content)
+	if len(trimed) == 0 {
+		return values, nil
+	}
+
+	// json object
+	if trimed[0] == '{' {
+		var object map[string]interface{}
+		if err := json.Unmarshal(trimed, &object); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOutput, err.Error())
+		}
+		for k, v := range object {
+			switch v := v.(type) {
+			case string:
+				values[k] = v
+			case nil:
+				values[k] = ""
+			default:
+				b, _ := json.Marshal(v)
+				values[k] = string(b)
+			}
+		}
+		return values, nil
+	}
+
+	// key=value lines
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	ln := 0
+	for scanner.Scan() {
+		ln++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if i := strings.Index(line, "<<"); i > 0 && !strings.Contains(line[:i], "=") {
+			name, delimiter := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+2:])
+			if delimiter == "" {
+				return nil, fmt.Errorf("%w: line %d, empty delimiter", ErrInvalidOutput, ln)
+			}
+			var (
+				lines  []string
+				closed bool
+			)
+			for scanner.Scan() {
+				ln++
+				l := strings.TrimRight(scanner.Text(), "\r")
+				if l == delimiter {
+					closed = true
+					break
+				}
+				lines = append(lines, l)
+			}
+			if !closed {
+				return nil, fmt.Errorf("%w: not found delimiter '%s' of '%s'", ErrInvalidOutput, delimiter, name)
+			}
+			values[name] = strings.Join(lines, "\n")
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: line %d, '%s'", ErrInvalidOutput, ln, line)
+		}
+		values[strings.TrimSpace(line[:i])] = line[i+1:]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutput, err.Error())
+	}
+	return values, nil
+}
@@ -0,0 +1,56 @@
+package shelldriver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSetOutput(t *testing.T) {
+	cases := []struct {
+		line  string
+		name  string
+		value string
+		ok    bool
+	}{
+		{"::set-output name=foo::bar\n", "foo", "bar", true},
+		{"::set-output name=foo::", "foo", "", true},
+		{"::set-output name=foo::a%0Ab%25", "foo", "a\nb%", true},
+		{"::set-output name=foo::a::b", "foo", "a::b", true},
+		{"set-output name=foo::bar", "", "", false},
+		{"hello world", "", "", false},
+	}
+	for _, c := range cases {
+		name, value, ok := parseSetOutput(c.line)
+		assert.Equal(t, c.ok, ok, c.line)
+		assert.Equal(t, c.name, name, c.line)
+		assert.Equal(t, c.value, value, c.line)
+	}
+}
+
+func TestParseOutputFile(t *testing.T) {
+	cases := []struct {
+		content string
+		expect  map[string]string
+		err     bool
+	}{
+		{"", map[string]string{}, false},
+		{"a=1\nb=x=y\n\nc=\n", map[string]string{"a": "1", "b": "x=y", "c": ""}, false},
+		{"a<<EOF\nline1\n\nline3\nEOF\nb=2", map[string]string{"a": "line1\n\nline3", "b": "2"}, false},
+		{"a=x<<y", map[string]string{"a": "x<<y"}, false},
+		{`{"a": "1", "b": 2, "c": [1, 2], "d": null}`, map[string]string{"a": "1", "b": "2", "c": "[1,2]", "d": ""}, false},
+		{"a<<EOF\nline1", nil, true},
+		{"a<<\nline1", nil, true},
+		{"line without equal sign", nil, true},
+		{`{"a": `, nil, true},
+	}
+	for _, c := range cases {
+		values, err := parseOutputFile([]byte(c.content))
+		if c.err {
+			assert.ErrorIs(t, err, ErrInvalidOutput, c.content)
+			continue
+		}
+		assert.NoError(t, err, c.content)
+		assert.Equal(t, c.expect, values, c.content)
+	}
+}
@@ -7,9 +7,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/functiondriver/store"
 	"github.com/skoowoo/cofx/manifest"
 	"github.com/skoowoo/cofx/pkg/output"
 	"github.com/skoowoo/cofx/service/resource"
@@ -23,6 +24,8 @@ type ShellDriver struct {
 	fpath   string
 	fname   string
 	version string
+	// dir is the directory of the loaded function version
+	dir string
 	// manifest be defined by function
 	manifest *manifest.Manifest
 	// resources contains some services that can be used by driver self. the shell function
@@ -39,9 +42,13 @@ func New(fname, fpath, version string) *ShellDriver {
 	}
 }
 
-// Load loads the shell script function from $cofx_HOME/shell directory.
+// Load loads the shell script function from $cofx_HOME/shell directory, or the shell directory of the
+// installed program, the highest version that satisfies the version of the 'load' statement is loaded.
 func (d *ShellDriver) Load(ctx context.Context, resources resource.Resources) error {
-	functionDir := filepath.Join(config.PrivateShellDir(), d.fpath)
+	functionDir, version, err := manifest.FindVersion(store.Locate(Name, d.fpath), d.version)
+	if err != nil {
+		return fmt.Errorf("%w: shell driver load", err)
+	}
 	mfPath := filepath.Join(functionDir, "manifest.json")
 	file, err := os.Open(mfPath)
 	if err != nil {
@@ -60,6 +67,10 @@ func (d *ShellDriver) Load(ctx context.Context, resources resource.Resources) er
 		return fmt.Errorf("%w: not found entrypoint program", err)
 	}
 
+	if _manifest.Version == "" {
+		_manifest.Version = version
+	}
+	d.dir = functionDir
 	d.manifest = &_manifest
 	d.resources = resources
 
@@ -76,18 +87,32 @@ func (d *ShellDriver) Run(ctx context.Context, args map[string]string) (map[stri
 		pretty.WriteTitle(d.resources.Labels.Get("node_name"), d.Name()+":"+d.FunctionName())
 	}
 	merged := d.mergeArgs(args)
-	functionDir := filepath.Join(config.PrivateShellDir(), d.fpath)
+	functionDir := d.dir
 	program := filepath.Join(functionDir, d.manifest.Entrypoint)
 
+	// The function writes the return values into the output file
+	outfile, err := os.CreateTemp("", "cofx-output-*")
+	if err != nil {
+		return nil, fmt.Errorf("%w: create output file", err)
+	}
+	outfile.Close()
+	defer os.Remove(outfile.Name())
+
 	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", program)
 	cmd.Dir = functionDir
 	cmd.Env = append(cmd.Env, d.toEnv(merged)...)
+	cmd.Env = append(cmd.Env, outputEnv+"="+outfile.Name())
 
 	retValues := make(map[string]string)
 	out := &output.Output{
-		W: d.resources.Logwriter,
 		HandleFunc: func(line []byte) {
-			// TODO:
+			if name, value, ok := parseSetOutput(string(line)); ok {
+				retValues[name] = value
+				return
+			}
+			if w := d.resources.Logwriter; w != nil {
+				w.Write(line)
+			}
 		},
 	}
 	cmd.Stderr = out
@@ -95,12 +120,59 @@ func (d *ShellDriver) Run(ctx context.Context, args map[string]string) (map[stri
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	out.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(outfile.Name())
+	if err != nil {
+		return nil, fmt.Errorf("%w: read output file", err)
+	}
+	values, err := parseOutputFile(content)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range values {
+		retValues[k] = v
+	}
+	if err := d.checkReturnValues(retValues); err != nil {
 		return nil, err
 	}
 	return retValues, nil
 }
 
+// checkReturnValues checks the return values match the ones declared in the manifest, every returned value
+// must be declared and every declared value must be returned. If the manifest doesn't declare any return
+// value, the check is skipped.
+func (d *ShellDriver) checkReturnValues(values map[string]string) error {
+	declared := d.manifest.Usage.ReturnValues
+	if len(declared) == 0 {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, desc := range declared {
+		names[desc.Name] = true
+	}
+	for k := range values {
+		if !names[k] {
+			return fmt.Errorf("%w: '%s' in shell function %s", ErrUndeclaredReturnValue, k, d.fname)
+		}
+	}
+	var missing []string
+	for name := range names {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: '%s' in shell function %s", ErrMissingReturnValue, strings.Join(missing, "', '"), d.fname)
+	}
+	return nil
+}
+
 // StopAndReslease is used to stop and release the all resources.
 func (d *ShellDriver) StopAndRelease(ctx context.Context) error {
 	return nil
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/skoowoo/cofx/manifest"
 	"github.com/skoowoo/cofx/service/resource"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,7 +24,7 @@ func TestShellDriver(t *testing.T) {
 	var buf bytes.Buffer
 	ctx := context.Background()
 
-	driver := New("echo", "echo", "lastest")
+	driver := New("echo", "echo", "latest")
 	if err := driver.Load(ctx, resource.Resources{
 		Logwriter: &buf,
 	}); err != nil {
@@ -34,3 +35,80 @@ func TestShellDriver(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "testing shell driver", strings.TrimSpace(buf.String()))
 }
+
+func TestShellDriverReturnValues(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	os.Setenv("COFX_HOME", filepath.Join(wd, "testdata"))
+	defer os.Unsetenv("COFX_HOME")
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+
+	driver := New("outputs", "outputs", "latest")
+	if err := driver.Load(ctx, resource.Resources{
+		Logwriter: &buf,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	rets, err := driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"from_marker": "hello",
+		"version":     "1.0.0",
+		"changelog":   "line1\nline2",
+	}, rets)
+	// the markers aren't written into the log
+	assert.Equal(t, "start to output\ndone\n", buf.String())
+
+	// the return value declared in the manifest isn't returned
+	declared := driver.manifest.Usage.ReturnValues
+	driver.manifest.Usage.ReturnValues = append(declared, manifest.UsageDesc{Name: "checksum"})
+	_, err = driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.ErrorIs(t, err, ErrMissingReturnValue)
+	assert.Contains(t, err.Error(), "'checksum'")
+
+	// the return value isn't declared in the manifest
+	driver.manifest.Usage.ReturnValues = declared[1:]
+	_, err = driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.ErrorIs(t, err, ErrUndeclaredReturnValue)
+}
+
+func TestShellDriverVersions(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	os.Setenv("COFX_HOME", filepath.Join(wd, "testdata"))
+	defer os.Unsetenv("COFX_HOME")
+
+	ctx := context.Background()
+	testcases := []struct {
+		version  string
+		expected string
+	}{
+		{"latest", "greet 1.2.0"},
+		{"^1.0", "greet 1.2.0"},
+		{"1.0", "greet 1.0.0"},
+		{"1.0.0", "greet 1.0.0"},
+	}
+	for _, c := range testcases {
+		var buf bytes.Buffer
+		driver := New("greet", "greet", c.version)
+		if err := driver.Load(ctx, resource.Resources{
+			Logwriter: &buf,
+		}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		_, err := driver.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, strings.TrimSpace(buf.String()))
+		assert.Equal(t, strings.TrimPrefix(c.expected, "greet "), driver.Manifest().Version)
+	}
+
+	driver := New("greet", "greet", "^2")
+	err = driver.Load(ctx, resource.Resources{})
+	assert.ErrorIs(t, err, manifest.ErrVersionNotFound)
+}
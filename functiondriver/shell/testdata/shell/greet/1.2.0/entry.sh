@@ -0,0 +1,3 @@
+#!/bin/sh
+
+echo "greet 1.2.0"
@@ -0,0 +1,11 @@
+#!/bin/sh
+
+echo "start to output"
+echo "::set-output name=from_marker::${COFX_MESSAGE}"
+echo "::set-output name=version::0.0.1"
+echo "version=1.0.0" >> $COFX_OUTPUT
+echo "changelog<<EOF" >> $COFX_OUTPUT
+echo "line1" >> $COFX_OUTPUT
+echo "line2" >> $COFX_OUTPUT
+echo "EOF" >> $COFX_OUTPUT
+echo "done"
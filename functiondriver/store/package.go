@@ -0,0 +1,289 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/semver"
+)
+
+// packageManifest is the manifest.json of a function package, the name, driver, version and entrypoint
+// are required, the checksum is optional:
+//
+//	{
+//	    "name": "deploy",
+//	    "driver": "shell",
+//	    "version": "1.2.0",
+//	    "entrypoint": "entry.sh",
+//	    "checksum": "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+//	}
+type packageManifest struct {
+	manifest.Manifest
+	// Checksum is the sha256 of the entrypoint file, it's verified when installing the package.
+	Checksum string `json:"checksum"`
+}
+
+// Install installs the function package into the private directory of its driver, the source is a
+// directory, a tarball (.tar.gz or .tgz) or a local git repository. The revision of the git repository
+// can be specified after '#', e.g. '/path/to/repo#v1.2.0', the files committed in the revision are
+// installed. The installed version isn't replaced unless force is true.
+func Install(source string, force bool) (*Info, error) {
+	dir, cleanup, err := unpack(source)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	mf, err := readPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Join(Dirs(mf.Driver)[0], mf.Name)
+	if _, err := os.Stat(filepath.Join(base, "manifest.json")); err == nil {
+		return nil, fmt.Errorf("%w: %s:%s is installed without version, remove it first", ErrConflict, mf.Driver,
+			mf.Name)
+	}
+	if _, err := os.Stat(base + ".star"); err == nil && mf.Driver == "script" {
+		return nil, fmt.Errorf("%w: %s:%s is installed as a single file, remove it first", ErrConflict, mf.Driver,
+			mf.Name)
+	}
+	target := filepath.Join(base, mf.Version)
+	if _, err := os.Stat(target); err == nil {
+		if !force {
+			return nil, fmt.Errorf("%w: %s:%s@%s is already installed", ErrConflict, mf.Driver, mf.Name, mf.Version)
+		}
+	}
+
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	// Copy into a temporary directory first, so the version is never half installed.
+	tmp, err := os.MkdirTemp(base, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return nil, err
+	}
+	if err := copyDir(dir, tmp); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return nil, err
+	}
+	return Inspect(mf.Driver, mf.Name, mf.Version)
+}
+
+// readPackage reads and validates the manifest of the package in the directory.
+func readPackage(dir string) (*packageManifest, error) {
+	mf, err := readManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	if !validName(mf.Name) {
+		return nil, fmt.Errorf("%w: invalid function name '%s'", ErrInvalidPackage, mf.Name)
+	}
+	if mf.Driver == "go" {
+		return nil, fmt.Errorf("%w: the go functions are built into the standard library", ErrNotInstallable)
+	}
+	if _, ok := dirs[mf.Driver]; !ok {
+		return nil, fmt.Errorf("%w: driver '%s'", ErrNotInstallable, mf.Driver)
+	}
+	if _, err := semver.Parse(mf.Version); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	if mf.Entrypoint == "" {
+		return nil, fmt.Errorf("%w: not found entrypoint", ErrInvalidPackage)
+	}
+	if filepath.IsAbs(mf.Entrypoint) {
+		return mf, nil
+	}
+	sum, err := checksum(dir, mf.Entrypoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	if mf.Checksum != "" && mf.Checksum != sum {
+		return nil, fmt.Errorf("%w: expect %s, got %s", ErrChecksumMismatch, mf.Checksum, sum)
+	}
+	return mf, nil
+}
+
+// checksum returns the sha256 of the entrypoint file.
+func checksum(dir, entrypoint string) (string, error) {
+	if entrypoint == "" || filepath.IsAbs(entrypoint) {
+		return "", fmt.Errorf("entrypoint '%s' isn't in the package", entrypoint)
+	}
+	f, err := os.Open(filepath.Join(dir, entrypoint))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unpack returns the directory that contains the manifest.json of the package, the cleanup function
+// removes the temporary files.
+func unpack(source string) (string, func(), error) {
+	noop := func() {}
+	path, rev := source, ""
+	if i := strings.LastIndex(source, "#"); i > 0 {
+		path, rev = source[:i], source[i+1:]
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", noop, err
+	}
+
+	var tmp string
+	switch {
+	case st.IsDir() && rev == "":
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			return path, noop, nil
+		}
+		fallthrough
+	case st.IsDir():
+		if tmp, err = os.MkdirTemp("", "cofx-fn-"); err != nil {
+			return "", noop, err
+		}
+		err = gitClone(path, rev, tmp)
+	case strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz"):
+		if tmp, err = os.MkdirTemp("", "cofx-fn-"); err != nil {
+			return "", noop, err
+		}
+		err = untar(path, tmp)
+	default:
+		return "", noop, fmt.Errorf("%w: '%s' isn't a directory, tarball or git repository", ErrInvalidPackage, source)
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	if err != nil {
+		cleanup()
+		return "", noop, err
+	}
+
+	// The files of the package can be in a top level directory of the tarball or repository.
+	dir := tmp
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); os.IsNotExist(err) {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) == 1 && entries[0].IsDir() {
+			dir = filepath.Join(dir, entries[0].Name())
+		}
+	}
+	return dir, cleanup, nil
+}
+
+func gitClone(repo, rev, dst string) error {
+	abs, err := filepath.Abs(repo)
+	if err != nil {
+		return err
+	}
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if rev != "" {
+		args = append(args, "--branch", rev)
+	}
+	args = append(args, "file://"+abs, dst)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: git clone '%s': %s", ErrInvalidPackage, repo, strings.TrimSpace(string(out)))
+	}
+	return os.RemoveAll(filepath.Join(dst, ".git"))
+}
+
+func untar(tarball, dst string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+		}
+		name := filepath.Clean(hdr.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: illegal path '%s' in tarball", ErrInvalidPackage, hdr.Name)
+		}
+		path := filepath.Join(dst, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeFile(path, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unsupported file '%s' in tarball", ErrInvalidPackage, hdr.Name)
+		}
+	}
+}
+
+// copyDir copies the directories and regular files, the '.git' directory is skipped.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case info.IsDir() && info.Name() == ".git":
+			return filepath.SkipDir
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, info.Mode().Perm())
+		}
+		return fmt.Errorf("%w: unsupported file '%s'", ErrInvalidPackage, rel)
+	})
+}
+
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,287 @@
+// Package store manages the functions installed in $COFX_HOME, the versions of a function are installed
+// side by side into the directory '<driver directory>/<function name>/<version>', so the drivers are able
+// to load the version that satisfies the constraint of the 'load' statement.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/semver"
+)
+
+var (
+	ErrInvalidPackage   = errors.New("invalid package")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrConflict         = errors.New("conflict")
+	ErrNotInstalled     = errors.New("not installed")
+	ErrNotInstallable   = errors.New("not installable")
+)
+
+// dirs store kvs of driver name -> the directories of the functions, the first one is the private
+// directory that the functions are installed into, the others are searched in order.
+var dirs = map[string][]func() string{
+	"shell":  {config.PrivateShellDir, config.BaseShellDir},
+	"exec":   {config.PrivateExecDir},
+	"wasm":   {config.PrivateWasmDir},
+	"script": {config.PrivateScriptDir},
+}
+
+// Drivers returns the names of the drivers whose functions can be installed into the store.
+func Drivers() []string {
+	var names []string
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Dirs returns the directories of the functions of the driver, the first one is the private directory.
+func Dirs(driver string) []string {
+	var ds []string
+	for _, dir := range dirs[driver] {
+		ds = append(ds, dir())
+	}
+	return ds
+}
+
+// Locate returns the directory of the function, the first directory of the driver that contains the
+// function wins. If the function isn't found, the directory in the private directory is returned.
+func Locate(driver, fpath string) string {
+	if filepath.IsAbs(fpath) {
+		return fpath
+	}
+	ds := Dirs(driver)
+	if len(ds) == 0 {
+		return fpath
+	}
+	for _, d := range ds {
+		p := filepath.Join(d, fpath)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(ds[0], fpath)
+}
+
+// Function is a function installed in the store.
+type Function struct {
+	Driver string
+	Name   string
+	// Dir is the directory of the function that contains all versions.
+	Dir string
+	// Versions are from the highest to the lowest, the version of the function that isn't versioned
+	// is empty.
+	Versions    []string
+	Description string
+}
+
+// Info is a version of the function installed in the store.
+type Info struct {
+	Function
+	// Path is the directory of the version.
+	Path     string
+	Manifest manifest.Manifest
+	// Checksum is the checksum of the entrypoint file, e.g. sha256:2c26b46b...
+	Checksum string
+}
+
+// List returns all functions installed in the directories of the drivers, a function in the private
+// directory shadows the one with the same name in the other directories.
+func List() ([]Function, error) {
+	var all []Function
+	for _, driver := range Drivers() {
+		seen := make(map[string]bool)
+		for _, d := range Dirs(driver) {
+			entries, err := os.ReadDir(d)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			for _, e := range entries {
+				name := e.Name()
+				if strings.HasPrefix(name, ".") {
+					continue
+				}
+				if !e.IsDir() {
+					name = strings.TrimSuffix(name, ".star")
+					if driver != "script" || name == e.Name() {
+						continue
+					}
+				}
+				if seen[name] {
+					continue
+				}
+				f, err := lookup(driver, d, name)
+				if err != nil {
+					continue
+				}
+				seen[name] = true
+				all = append(all, f)
+			}
+		}
+	}
+	return all, nil
+}
+
+// Lookup returns the installed function of the driver.
+func Lookup(driver, name string) (Function, error) {
+	if _, ok := dirs[driver]; !ok {
+		return Function{}, fmt.Errorf("%w: driver '%s'", ErrNotInstallable, driver)
+	}
+	for _, d := range Dirs(driver) {
+		if f, err := lookup(driver, d, name); err == nil {
+			return f, nil
+		}
+	}
+	return Function{}, fmt.Errorf("%w: %s:%s", ErrNotInstalled, driver, name)
+}
+
+func lookup(driver, dir, name string) (Function, error) {
+	f := Function{
+		Driver: driver,
+		Name:   name,
+		Dir:    filepath.Join(dir, name),
+	}
+	if driver == "script" {
+		if st, err := os.Stat(f.Dir + ".star"); err == nil && !st.IsDir() {
+			f.Dir += ".star"
+			f.Versions = []string{""}
+			return f, nil
+		}
+	}
+	versions, err := manifest.Versions(f.Dir)
+	if err != nil {
+		return f, err
+	}
+	mfDir := f.Dir
+	if len(versions) > 0 {
+		mfDir = filepath.Join(f.Dir, versions[0])
+	}
+	mf, err := readManifest(mfDir)
+	if err != nil {
+		return f, err
+	}
+	if len(versions) == 0 {
+		versions = []string{mf.Version}
+	}
+	f.Versions = versions
+	f.Description = mf.Description
+	return f, nil
+}
+
+// Inspect returns the highest version of the installed function that satisfies the constraint.
+func Inspect(driver, name, constraint string) (*Info, error) {
+	f, err := Lookup(driver, name)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Function: f}
+	if filepath.Ext(f.Dir) == ".star" {
+		if c, err := semver.ParseConstraint(constraint); err != nil || !c.Any() {
+			return nil, fmt.Errorf("%w: '%s' of %s, the function isn't versioned", manifest.ErrVersionNotFound,
+				constraint, name)
+		}
+		info.Path = filepath.Dir(f.Dir)
+		info.Manifest = manifest.Manifest{
+			Name:       name,
+			Driver:     driver,
+			Entrypoint: filepath.Base(f.Dir),
+		}
+	} else {
+		dir, version, err := manifest.FindVersion(f.Dir, constraint)
+		if err != nil {
+			return nil, err
+		}
+		mf, err := readManifest(dir)
+		if err != nil {
+			return nil, err
+		}
+		if mf.Version == "" {
+			mf.Version = version
+		}
+		info.Path = dir
+		info.Manifest = mf.Manifest
+	}
+	if sum, err := checksum(info.Path, info.Manifest.Entrypoint); err == nil {
+		info.Checksum = sum
+	}
+	return info, nil
+}
+
+// Remove removes the version of the installed function from the private directory, all versions are
+// removed if the version is empty.
+func Remove(driver, name, version string) error {
+	ds := Dirs(driver)
+	if len(ds) == 0 {
+		return fmt.Errorf("%w: driver '%s'", ErrNotInstallable, driver)
+	}
+	if !validName(name) {
+		return fmt.Errorf("%w: %s:%s", ErrNotInstalled, driver, name)
+	}
+	base := filepath.Join(ds[0], name)
+	if version == "" {
+		if driver == "script" {
+			if err := os.Remove(base + ".star"); err == nil {
+				return nil
+			}
+		}
+		if _, err := os.Stat(base); err != nil {
+			return fmt.Errorf("%w: %s:%s", ErrNotInstalled, driver, name)
+		}
+		return os.RemoveAll(base)
+	}
+
+	// The version is a directory name, so it must be an exact version, e.g. not '^1.2' or '../x'
+	if _, err := semver.Parse(version); err != nil || !validName(version) {
+		if _, cerr := semver.ParseConstraint(version); cerr == nil {
+			return fmt.Errorf("%w: '%s' is a constraint, remove an exact version of %s:%s, e.g. 1.2.0",
+				semver.ErrInvalidVersion, version, driver, name)
+		}
+		return fmt.Errorf("%w: '%s' of %s:%s", semver.ErrInvalidVersion, version, driver, name)
+	}
+	target := filepath.Join(base, version)
+	if _, err := os.Stat(filepath.Join(target, "manifest.json")); err != nil {
+		// Only one version
+		mf, err := readManifest(base)
+		if err != nil || mf.Version != version {
+			return fmt.Errorf("%w: %s:%s@%s", ErrNotInstalled, driver, name, version)
+		}
+		return os.RemoveAll(base)
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return err
+	}
+	if versions, err := manifest.Versions(base); err == nil && len(versions) == 0 {
+		if _, err := os.Stat(filepath.Join(base, "manifest.json")); os.IsNotExist(err) {
+			return os.RemoveAll(base)
+		}
+	}
+	return nil
+}
+
+func readManifest(dir string) (*packageManifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+	var mf packageManifest
+	if err := json.Unmarshal(b, &mf); err != nil {
+		return nil, fmt.Errorf("%w: decode manifest in '%s'", err, dir)
+	}
+	return &mf, nil
+}
+
+func validName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\:@`)
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/skoowoo/cofx/pkg/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePackage(t *testing.T, dir, version, sum string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := fmt.Sprintf(`{
+		"name": "deploy",
+		"description": "deploy %s",
+		"driver": "shell",
+		"version": %q,
+		"entrypoint": "entry.sh",
+		"checksum": %q
+	}`, version, version, sum)
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "entry.sh"), []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func TestInstallDirectory(t *testing.T) {
+	t.Setenv("COFX_HOME", t.TempDir())
+	src := t.TempDir()
+
+	writePackage(t, filepath.Join(src, "v1"), "1.0.0", "")
+	info, err := Install(filepath.Join(src, "v1"), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", info.Manifest.Version)
+	assert.Equal(t, []string{"1.0.0"}, info.Versions)
+	assert.Contains(t, info.Checksum, "sha256:")
+	st, err := os.Stat(filepath.Join(info.Path, "entry.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), st.Mode().Perm())
+
+	_, err = Install(filepath.Join(src, "v1"), false)
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = Install(filepath.Join(src, "v1"), true)
+	assert.NoError(t, err)
+
+	writePackage(t, filepath.Join(src, "v2"), "1.2.0", info.Checksum)
+	_, err = Install(filepath.Join(src, "v2"), false)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	writePackage(t, filepath.Join(src, "v2"), "1.2.0", "")
+	_, err = Install(filepath.Join(src, "v2"), false)
+	assert.NoError(t, err)
+
+	all, err := List()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	assert.Equal(t, "deploy", all[0].Name)
+	assert.Equal(t, "shell", all[0].Driver)
+	assert.Equal(t, []string{"1.2.0", "1.0.0"}, all[0].Versions)
+	assert.Equal(t, "deploy 1.2.0", all[0].Description)
+
+	info, err = Inspect("shell", "deploy", "~1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", info.Manifest.Version)
+
+	assert.ErrorIs(t, Remove("shell", "deploy", "2.0.0"), ErrNotInstalled)
+	assert.ErrorIs(t, Remove("shell", "deploy", ".."), semver.ErrInvalidVersion)
+	assert.ErrorIs(t, Remove("shell", "deploy", "1.0.0-x/../.."), semver.ErrInvalidVersion)
+	err = Remove("shell", "deploy", "^1.2")
+	assert.ErrorIs(t, err, semver.ErrInvalidVersion)
+	assert.Contains(t, err.Error(), "constraint")
+	assert.NoError(t, Remove("shell", "deploy", "1.2.0"))
+	f, err := Lookup("shell", "deploy")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0"}, f.Versions)
+
+	assert.NoError(t, Remove("shell", "deploy", "1.0.0"))
+	_, err = Lookup("shell", "deploy")
+	assert.ErrorIs(t, err, ErrNotInstalled)
+	assert.ErrorIs(t, Remove("shell", "deploy", ""), ErrNotInstalled)
+}
+
+func TestInstallInvalid(t *testing.T) {
+	t.Setenv("COFX_HOME", t.TempDir())
+	src := t.TempDir()
+
+	testcases := []struct {
+		manifest string
+		err      error
+	}{
+		{`{"name": "a", "driver": "go", "version": "1.0.0", "entrypoint": "entry.sh"}`, ErrNotInstallable},
+		{`{"name": "a", "driver": "unknown", "version": "1.0.0", "entrypoint": "entry.sh"}`, ErrNotInstallable},
+		{`{"name": "../a", "driver": "shell", "version": "1.0.0", "entrypoint": "entry.sh"}`, ErrInvalidPackage},
+		{`{"name": "a", "driver": "shell", "entrypoint": "entry.sh"}`, ErrInvalidPackage},
+		{`{"name": "a", "driver": "shell", "version": "1.0.0"}`, ErrInvalidPackage},
+		{`{"name": "a", "driver": "shell", "version": "1.0.0", "entrypoint": "missing.sh"}`, ErrInvalidPackage},
+	}
+	for i, c := range testcases {
+		dir := filepath.Join(src, fmt.Sprint(i))
+		writePackage(t, dir, "1.0.0", "")
+		if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(c.manifest), 0644); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		_, err := Install(dir, false)
+		assert.ErrorIs(t, err, c.err, c.manifest)
+	}
+
+	_, err := Install(filepath.Join(src, "0", "entry.sh"), false)
+	assert.ErrorIs(t, err, ErrInvalidPackage)
+}
+
+func TestInstallUnversionedConflict(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	src := t.TempDir()
+
+	// Installed by hand without version
+	writePackage(t, filepath.Join(home, "shell", "deploy"), "", "")
+	writePackage(t, src, "1.0.0", "")
+	_, err := Install(src, false)
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func TestInstallTarball(t *testing.T) {
+	t.Setenv("COFX_HOME", t.TempDir())
+	src := t.TempDir()
+	writePackage(t, filepath.Join(src, "deploy"), "1.1.0", "")
+
+	tarball := filepath.Join(t.TempDir(), "deploy.tar.gz")
+	f, err := os.Create(tarball)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range []string{"manifest.json", "entry.sh"} {
+		b, err := os.ReadFile(filepath.Join(src, "deploy", name))
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		hdr := &tar.Header{Name: "deploy/" + name, Mode: 0755, Size: int64(len(b)), Typeflag: tar.TypeReg}
+		assert.NoError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write(b)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, f.Close())
+
+	info, err := Install(tarball, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.0", info.Manifest.Version)
+}
+
+func TestInstallGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	t.Setenv("COFX_HOME", t.TempDir())
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=testing", "-c", "user.email=testing@cofx",
+			"-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			assert.FailNow(t, string(out))
+		}
+	}
+	git("init", "--quiet")
+	writePackage(t, repo, "1.0.0", "")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1.0.0")
+	writePackage(t, repo, "2.0.0", "")
+	git("commit", "--quiet", "-a", "-m", "v2")
+	// Not committed
+	writePackage(t, repo, "3.0.0", "")
+
+	info, err := Install(repo, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", info.Manifest.Version)
+	_, err = os.Stat(filepath.Join(info.Path, ".git"))
+	assert.True(t, os.IsNotExist(err))
+
+	info, err = Install(repo+"#v1.0.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", info.Manifest.Version)
+	assert.Equal(t, []string{"2.0.0", "1.0.0"}, info.Versions)
+}
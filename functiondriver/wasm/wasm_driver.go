@@ -0,0 +1,232 @@
+package wasmdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/skoowoo/cofx/functiondriver/protocol"
+	"github.com/skoowoo/cofx/functiondriver/store"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/output"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const Name = "wasm"
+
+var ErrModuleExited = errors.New("module exited")
+
+// wasmManifest is the manifest of the wasm function, it extends the common manifest with the
+// capabilities granted to the module, the module can't access anything of the host by default. The network
+// is never granted, there is no http host function.
+type wasmManifest struct {
+	manifest.Manifest
+	Capabilities capabilities `json:"capabilities"`
+}
+
+type capabilities struct {
+	// Dirs are the directories preopened for the module, the key is the path in the module, the value is
+	// the path in the host, a relative path is relative to the function directory.
+	Dirs map[string]string `json:"dirs"`
+	// ReadonlyDirs are the same as Dirs, but the module can't write them.
+	ReadonlyDirs map[string]string `json:"readonly_dirs"`
+	// Env are the names of the environment variables of the host passed to the module.
+	Env []string `json:"env"`
+	// Clock allows the module to read the real time of the host.
+	Clock bool `json:"clock"`
+}
+
+// WasmDriver is used to execute the WebAssembly functions in a sandbox. A function is a WASI command
+// module and its manifest.json, they are stored in $COFX_HOME/wasm/<function> directory. The module is
+// instantiated for every run, it reads the request as a json line from the stdin and writes the response
+// as a json line into the stdout, the lines written into the stderr are the logs of the function.
+type WasmDriver struct {
+	fname   string
+	fpath   string
+	version string
+	// dir is the directory of the loaded function version
+	dir string
+	// manifest be defined by function
+	manifest *wasmManifest
+	// resources contains some services that can be used by driver self.
+	resources resource.Resources
+
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// New creates a new WasmDriver instance to execute the wasm functions.
+func New(fname, fpath, version string) *WasmDriver {
+	return &WasmDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+	}
+}
+
+// Load loads the manifest and compiles the module of the function, the highest version that satisfies
+// the version of the 'load' statement is loaded.
+func (d *WasmDriver) Load(ctx context.Context, resources resource.Resources) error {
+	functionDir, version, err := manifest.FindVersion(store.Locate(Name, d.fpath), d.version)
+	if err != nil {
+		return fmt.Errorf("%w: wasm driver load", err)
+	}
+	file, err := os.Open(filepath.Join(functionDir, "manifest.json"))
+	if err != nil {
+		return fmt.Errorf("%w: wasm driver load", err)
+	}
+	defer file.Close()
+	var _manifest wasmManifest
+	if err := json.NewDecoder(file).Decode(&_manifest); err != nil {
+		return fmt.Errorf("%w: wasm driver decode manifest", err)
+	}
+	if _manifest.Entrypoint == "" {
+		return fmt.Errorf("not found entrypoint in wasm function: %s", d.fname)
+	}
+	code, err := os.ReadFile(filepath.Join(functionDir, _manifest.Entrypoint))
+	if err != nil {
+		return fmt.Errorf("%w: read wasm module", err)
+	}
+
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return fmt.Errorf("%w: instantiate wasi", err)
+	}
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		rt.Close(ctx)
+		return fmt.Errorf("%w: compile wasm module", err)
+	}
+
+	if _manifest.Version == "" {
+		_manifest.Version = version
+	}
+	d.dir = functionDir
+	d.manifest = &_manifest
+	d.resources = resources
+	d.runtime = rt
+	d.compiled = compiled
+	return nil
+}
+
+// Run instantiates the module to execute the function, the module exits after writing the response.
+func (d *WasmDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	pretty, ok := d.resources.Logwriter.(resource.OutPrettyPrinter)
+	if ok {
+		defer func() {
+			pretty.Reset()
+		}()
+		pretty.WriteTitle(d.resources.Labels.Get("node_name"), d.Name()+":"+d.FunctionName())
+	}
+	req, err := protocol.NewRequest(d.manifest.Args, args, d.resources.Labels, d.manifest.Version).Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	stderr := &output.Output{
+		HandleFunc: func(line []byte) {
+			if w := d.resources.Logwriter; w != nil {
+				if len(line) > 0 && line[len(line)-1] != '\n' {
+					line = append(line, '\n')
+				}
+				w.Write(line)
+			}
+		},
+	}
+	cfg := d.moduleConfig().
+		WithStdin(bytes.NewReader(req)).
+		WithStdout(&stdout).
+		WithStderr(stderr)
+
+	mod, err := d.runtime.InstantiateModule(ctx, d.compiled, cfg)
+	stderr.Close()
+	if mod != nil {
+		mod.Close(ctx)
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+		err = nil
+	}
+
+	// The module may exit with an error after writing the response with the error message.
+	rets, perr := protocol.ParseResponse(bytes.TrimSpace(stdout.Bytes()))
+	if err != nil {
+		if perr != nil || rets.Error == "" {
+			return nil, fmt.Errorf("%w: %s", ErrModuleExited, err.Error())
+		}
+	}
+	if perr != nil {
+		return nil, perr
+	}
+	return rets.Values()
+}
+
+// moduleConfig grants the capabilities of the manifest to the module.
+func (d *WasmDriver) moduleConfig() wazero.ModuleConfig {
+	// Anonymous module, so the module can be instantiated multiple times at the same time.
+	cfg := wazero.NewModuleConfig().WithName("").WithArgs(d.fname)
+	caps := d.manifest.Capabilities
+
+	hostPath := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(d.dir, p)
+	}
+	fscfg := wazero.NewFSConfig()
+	for guest, host := range caps.Dirs {
+		fscfg = fscfg.WithDirMount(hostPath(host), guest)
+	}
+	for guest, host := range caps.ReadonlyDirs {
+		fscfg = fscfg.WithReadOnlyDirMount(hostPath(host), guest)
+	}
+	cfg = cfg.WithFSConfig(fscfg)
+
+	for _, name := range caps.Env {
+		if v, ok := os.LookupEnv(name); ok {
+			cfg = cfg.WithEnv(name, v)
+		}
+	}
+	if caps.Clock {
+		cfg = cfg.WithSysWalltime().WithSysNanotime().WithSysNanosleep()
+	}
+	return cfg
+}
+
+// StopAndRelease closes the runtime and releases the compiled module.
+func (d *WasmDriver) StopAndRelease(ctx context.Context) error {
+	if d.runtime == nil {
+		return nil
+	}
+	err := d.runtime.Close(ctx)
+	d.runtime = nil
+	d.compiled = nil
+	return err
+}
+
+// FunctionName returns the name of the wasm function.
+func (d *WasmDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the wasm driver.
+func (d *WasmDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the wasm function.
+func (d *WasmDriver) Manifest() manifest.Manifest {
+	return d.manifest.Manifest
+}
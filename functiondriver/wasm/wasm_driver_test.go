@@ -0,0 +1,243 @@
+package wasmdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+// The testing modules are assembled by hand, so the testing doesn't depend on any wasm toolchain. They
+// import 'fd_read', 'fd_write' and 'proc_exit' of WASI as the function 0, 1 and 2, the '_start' is the
+// function 3.
+
+func uleb(n uint32) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func sleb(n int32) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && c&0x40 == 0) || (n == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	b := uleb(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func str(s string) []byte {
+	return append(uleb(uint32(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, uleb(uint32(len(content)))...), content...)
+}
+
+func i32const(n int32) []byte {
+	return append([]byte{0x41}, sleb(n)...)
+}
+
+// call calls the function 'idx' with the i32 arguments, and drops the result if 'drop' is true.
+func call(idx uint32, drop bool, args ...int32) []byte {
+	var b []byte
+	for _, a := range args {
+		b = append(b, i32const(a)...)
+	}
+	b = append(b, 0x10)
+	b = append(b, uleb(idx)...)
+	if drop {
+		b = append(b, 0x1a)
+	}
+	return b
+}
+
+// data returns a data segment that puts 'v' at 'offset' of the memory, 'v' is a string or []uint32.
+func data(offset int32, v interface{}) []byte {
+	var content []byte
+	switch v := v.(type) {
+	case string:
+		content = []byte(v)
+	case []uint32:
+		content = make([]byte, 4*len(v))
+		for i, n := range v {
+			binary.LittleEndian.PutUint32(content[4*i:], n)
+		}
+	}
+	b := []byte{0x00}
+	b = append(b, i32const(offset)...)
+	b = append(b, 0x0b)
+	return append(b, str(string(content))...)
+}
+
+func assemble(body []byte, segments ...[]byte) []byte {
+	fdType := []byte{0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f} // (i32, i32, i32, i32) -> i32
+	startType := []byte{0x60, 0x00, 0x00}                            // () -> ()
+	exitType := []byte{0x60, 0x01, 0x7f, 0x00}                       // (i32) -> ()
+	wasi := "wasi_snapshot_preview1"
+
+	m := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	m = append(m, section(1, vec(fdType, startType, exitType))...)
+	m = append(m, section(2, vec(
+		append(append(str(wasi), str("fd_read")...), 0x00, 0x00),
+		append(append(str(wasi), str("fd_write")...), 0x00, 0x00),
+		append(append(str(wasi), str("proc_exit")...), 0x00, 0x02),
+	))...)
+	m = append(m, section(3, vec([]byte{0x01}))...)
+	m = append(m, section(5, vec([]byte{0x00, 0x01}))...)
+	m = append(m, section(7, vec(
+		append(str("memory"), 0x02, 0x00),
+		append(str("_start"), 0x00, 0x03),
+	))...)
+	code := append([]byte{0x00}, body...)
+	code = append(code, 0x0b)
+	m = append(m, section(10, vec(append(uleb(uint32(len(code))), code...)))...)
+	m = append(m, section(11, vec(segments...))...)
+	return m
+}
+
+// echoModule writes the request into the response as the return value 'request', and writes a log.
+func echoModule() []byte {
+	const (
+		prefix = `{"returns": {"request": `
+		suffix = "}}\n"
+		log    = "log from wasm\n"
+	)
+	var body []byte
+	body = append(body, call(0, true, 0, 0, 1, 100)...) // fd_read(stdin, iovs=0, 1, nread=100)
+	// set the length of the 2nd iovec of stdout to the length of the request
+	body = append(body, i32const(28)...)
+	body = append(body, i32const(100)...)
+	body = append(body, 0x28, 0x02, 0x00, 0x36, 0x02, 0x00)
+	body = append(body, call(1, true, 1, 16, 3, 100)...) // fd_write(stdout, iovs=16, 3, nwritten=100)
+	body = append(body, call(1, true, 2, 48, 1, 100)...) // fd_write(stderr, iovs=48, 1, nwritten=100)
+	return assemble(body,
+		data(0, []uint32{1024, 4096}),
+		data(16, []uint32{200, uint32(len(prefix)), 1024, 0, 300, uint32(len(suffix))}),
+		data(48, []uint32{400, uint32(len(log))}),
+		data(200, prefix),
+		data(300, suffix),
+		data(400, log),
+	)
+}
+
+// failModule writes a response with an error, then exits with 1.
+func failModule() []byte {
+	const resp = `{"error": "failed in wasm"}` + "\n"
+	var body []byte
+	body = append(body, call(1, true, 1, 0, 1, 100)...)
+	body = append(body, call(2, false, 1)...)
+	return assemble(body,
+		data(0, []uint32{200, uint32(len(resp))}),
+		data(200, resp),
+	)
+}
+
+// loopModule never exits.
+func loopModule() []byte {
+	return assemble([]byte{0x03, 0x40, 0x0c, 0x00, 0x0b})
+}
+
+func loadTestingDriver(t *testing.T, module []byte, logwriter *bytes.Buffer) *WasmDriver {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.wasm"), module, 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := `{
+		"name": "testing",
+		"driver": "wasm",
+		"version": "1.0.0",
+		"entrypoint": "main.wasm",
+		"args": {"message": "default"},
+		"capabilities": {"env": ["HOME"], "dirs": {"/data": "."}}
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	lbs := make(labels.Labels)
+	lbs.Set("node_name", "wasm_node")
+	driver := New("testing", dir, "1.0.0")
+	err := driver.Load(context.Background(), resource.Resources{
+		Logwriter: logwriter,
+		Labels:    lbs,
+	})
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	return driver
+}
+
+func TestWasmDriver(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := loadTestingDriver(t, echoModule(), &buf)
+	defer driver.StopAndRelease(ctx)
+	assert.Equal(t, "testing", driver.Manifest().Name)
+	assert.Equal(t, []string{"HOME"}, driver.manifest.Capabilities.Env)
+
+	for i := 0; i < 2; i++ {
+		rets, err := driver.Run(ctx, map[string]string{"message": fmt.Sprintf("hello %d", i)})
+		assert.NoError(t, err)
+
+		var req struct {
+			Args    map[string]string `json:"args"`
+			Labels  map[string]string `json:"labels"`
+			Version string            `json:"version"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(rets["request"]), &req))
+		assert.Equal(t, fmt.Sprintf("hello %d", i), req.Args["message"])
+		assert.Equal(t, "wasm_node", req.Labels["node_name"])
+		assert.Equal(t, "1.0.0", req.Version)
+	}
+	assert.Equal(t, "log from wasm\nlog from wasm\n", buf.String())
+}
+
+func TestWasmDriverFailed(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	driver := loadTestingDriver(t, failModule(), &buf)
+	defer driver.StopAndRelease(ctx)
+
+	_, err := driver.Run(ctx, nil)
+	assert.EqualError(t, err, "failed in wasm")
+}
+
+func TestWasmDriverCanceled(t *testing.T) {
+	var buf bytes.Buffer
+	driver := loadTestingDriver(t, loopModule(), &buf)
+	defer driver.StopAndRelease(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := driver.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
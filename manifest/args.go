@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidArg = errors.New("invalid argument")
+	ErrMissingArg = errors.New("missing argument")
+)
+
+// The types of the argument, the value of an argument is always a string in flowl, the type defines
+// how the string is parsed by the function.
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeBool     = "bool"
+	TypeDuration = "duration"
+	TypeURL      = "url"
+	TypePath     = "path"
+	TypeList     = "list"
+	TypeJSON     = "json"
+)
+
+// Check checks whether the value satisfies the type, pattern and enum of the argument.
+func (u UsageDesc) Check(value string) error {
+	if err := checkType(u.Type, value); err != nil {
+		return fmt.Errorf("%w: '%s' %s", ErrInvalidArg, u.Name, err)
+	}
+	if u.Pattern != "" {
+		re, err := regexp.Compile(u.Pattern)
+		if err != nil {
+			return fmt.Errorf("%w: '%s' has an invalid pattern '%s'", ErrInvalidArg, u.Name, u.Pattern)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%w: '%s' value '%s' doesn't match pattern '%s'", ErrInvalidArg, u.Name, value, u.Pattern)
+		}
+	}
+	if len(u.Enum) > 0 {
+		for _, e := range u.Enum {
+			if e == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: '%s' value '%s' isn't one of %s", ErrInvalidArg, u.Name, value, strings.Join(u.Enum, ", "))
+	}
+	return nil
+}
+
+func checkType(typ, value string) error {
+	var err error
+	switch typ {
+	case "", TypeString, TypeList:
+	case TypeInt:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeBool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "1", "false", "no", "0":
+		default:
+			err = errors.New("invalid bool")
+		}
+	case TypeDuration:
+		_, err = time.ParseDuration(value)
+	case TypeURL:
+		var u *url.URL
+		if u, err = url.Parse(value); err == nil && (u.Scheme == "" || u.Host == "") {
+			err = errors.New("missing scheme or host")
+		}
+	case TypePath:
+		if value == "" || strings.ContainsRune(value, 0) {
+			err = errors.New("invalid path")
+		}
+	case TypeJSON:
+		if !json.Valid([]byte(value)) {
+			err = errors.New("invalid json")
+		}
+	default:
+		return fmt.Errorf("has an unknown type '%s'", typ)
+	}
+	if err != nil {
+		return fmt.Errorf("value '%s' isn't %s, %s", value, typ, err)
+	}
+	return nil
+}
+
+// Lookup returns the description of the argument.
+func (u Usage) Lookup(name string) (UsageDesc, bool) {
+	for _, a := range u.Args {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return UsageDesc{}, false
+}
+
+// Defaults returns the default values of the arguments.
+func (u Usage) Defaults() map[string]string {
+	defaults := make(map[string]string)
+	for _, a := range u.Args {
+		if a.Default != "" {
+			defaults[a.Name] = a.Default
+		}
+	}
+	return defaults
+}
+
+// Validate checks the arguments merged with the default values, the required arguments must be
+// given, and the arguments that aren't described are allowed.
+func (u Usage) Validate(args map[string]string) error {
+	for _, a := range u.Args {
+		v, ok := args[a.Name]
+		if !ok {
+			if a.Required {
+				return fmt.Errorf("%w: '%s'", ErrMissingArg, a.Name)
+			}
+			continue
+		}
+		if err := a.Check(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
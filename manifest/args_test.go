@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageDescCheck(t *testing.T) {
+	testcases := []struct {
+		desc  UsageDesc
+		value string
+		ok    bool
+	}{
+		{UsageDesc{Name: "a"}, "anything", true},
+		{UsageDesc{Name: "a", Type: TypeInt}, "12", true},
+		{UsageDesc{Name: "a", Type: TypeInt}, "1.2", false},
+		{UsageDesc{Name: "a", Type: TypeBool}, "yes", true},
+		{UsageDesc{Name: "a", Type: TypeBool}, "maybe", false},
+		{UsageDesc{Name: "a", Type: TypeDuration}, "1m30s", true},
+		{UsageDesc{Name: "a", Type: TypeDuration}, "10", false},
+		{UsageDesc{Name: "a", Type: TypeURL}, "https://example.com/x", true},
+		{UsageDesc{Name: "a", Type: TypeURL}, "example.com", false},
+		{UsageDesc{Name: "a", Type: TypePath}, "/tmp/a", true},
+		{UsageDesc{Name: "a", Type: TypePath}, "", false},
+		{UsageDesc{Name: "a", Type: TypeList}, "a,b", true},
+		{UsageDesc{Name: "a", Type: TypeJSON}, `{"k": [1, 2]}`, true},
+		{UsageDesc{Name: "a", Type: TypeJSON}, `{"k":`, false},
+		{UsageDesc{Name: "a", Type: "float"}, "1.0", false},
+		{UsageDesc{Name: "a", Pattern: `^v\d+$`}, "v12", true},
+		{UsageDesc{Name: "a", Pattern: `^v\d+$`}, "12", false},
+		{UsageDesc{Name: "a", Pattern: `(`}, "12", false},
+		{UsageDesc{Name: "a", Enum: []string{"dev", "prod"}}, "prod", true},
+		{UsageDesc{Name: "a", Enum: []string{"dev", "prod"}}, "test", false},
+	}
+	for _, c := range testcases {
+		err := c.desc.Check(c.value)
+		if c.ok {
+			assert.NoError(t, err, c.value)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidArg, c.value)
+		}
+	}
+}
+
+func TestUsageValidate(t *testing.T) {
+	usage := Usage{
+		Args: []UsageDesc{
+			{Name: "url", Type: TypeURL, Required: true},
+			{Name: "retries", Type: TypeInt, Default: "3"},
+		},
+	}
+	assert.Equal(t, map[string]string{"retries": "3"}, usage.Defaults())
+
+	err := usage.Validate(map[string]string{"retries": "1"})
+	assert.ErrorIs(t, err, ErrMissingArg)
+	assert.Contains(t, err.Error(), "'url'")
+
+	err = usage.Validate(map[string]string{"url": "https://example.com", "retries": "x"})
+	assert.ErrorIs(t, err, ErrInvalidArg)
+	assert.Contains(t, err.Error(), "'retries'")
+
+	assert.NoError(t, usage.Validate(map[string]string{"url": "https://example.com", "other": "v"}))
+}
@@ -5,13 +5,18 @@ type Manifest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Driver      string `json:"driver"`
+	// Version is the semantic version of the function, e.g. 1.2.0
+	Version string `json:"version"`
 	// Note: You don't need to specify the Entrypoint field, When develop a new std function.
 	// Because the Entrypoint field is automatically filled in, When register the new function into std.
 	Entrypoint     string            `json:"entrypoint"`
 	Args           map[string]string `json:"args"`
 	RetryOnFailure int               `json:"retry_on_failure"`
 	IgnoreFailure  bool              `json:"ignore_failure"`
-	Usage          Usage             `json:"usage"`
+	// Cache is the TTL of the cached return values, e.g. 1h, the function opts in caching if it's set,
+	// it should be set only if the function is deterministic for the same inputs.
+	Cache string `json:"cache"`
+	Usage Usage  `json:"usage"`
 }
 
 type Usage struct {
@@ -20,7 +25,16 @@ type Usage struct {
 }
 
 type UsageDesc struct {
-	Name           string   `json:"name"`
+	Name string `json:"name"`
+	// Type is one of string, int, bool, duration, url, path, list and json, default string.
+	Type string `json:"type"`
+	// Required means the argument must be given, unless it has a default value.
+	Required bool   `json:"required"`
+	Default  string `json:"default"`
+	// Pattern is a regular expression that the value must match.
+	Pattern string `json:"pattern"`
+	// Enum is the list of the allowed values.
+	Enum           []string `json:"enum"`
 	OptionalValues []string `json:"optional_values"`
 	Desc           string   `json:"desc"`
 }
@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skoowoo/cofx/pkg/semver"
+)
+
+var ErrVersionNotFound = errors.New("version not found")
+
+// FindVersion returns the directory of the function version that satisfies the constraint, e.g. '^1.2'
+// or 'latest'. The versions of a function are installed side by side in the function directory:
+//
+//	deploy/
+//	    1.0.0/manifest.json
+//	    1.2.0/manifest.json
+//
+// A function directory that contains manifest.json directly has only one version, that is the version
+// of the manifest. The returned version is the name of the version directory, or the version of the
+// manifest.
+func FindVersion(functionDir, constraint string) (string, string, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return "", "", err
+	}
+	versions, err := Versions(functionDir)
+	if err != nil {
+		return "", "", err
+	}
+	if len(versions) > 0 {
+		found, ok := semver.Highest(c, versions)
+		if !ok {
+			return "", "", fmt.Errorf("%w: '%s' of %s, the installed versions are %s", ErrVersionNotFound, constraint,
+				filepath.Base(functionDir), strings.Join(versions, ", "))
+		}
+		return filepath.Join(functionDir, found), found, nil
+	}
+
+	// Only one version
+	var mf struct {
+		Version string `json:"version"`
+	}
+	if b, err := os.ReadFile(filepath.Join(functionDir, "manifest.json")); err != nil {
+		return "", "", err
+	} else if err := json.Unmarshal(b, &mf); err != nil {
+		return "", "", err
+	}
+	if c.Any() {
+		return functionDir, mf.Version, nil
+	}
+	if mf.Version == "" {
+		return "", "", fmt.Errorf("%w: '%s' of %s, the function isn't versioned", ErrVersionNotFound, constraint,
+			filepath.Base(functionDir))
+	}
+	if _, ok := semver.Highest(c, []string{mf.Version}); !ok {
+		return "", "", fmt.Errorf("%w: '%s' of %s, the installed version is %s", ErrVersionNotFound, constraint,
+			filepath.Base(functionDir), mf.Version)
+	}
+	return functionDir, mf.Version, nil
+}
+
+// Versions returns the versions installed side by side in the function directory, from the highest to
+// the lowest. It returns nothing if the function directory contains manifest.json directly.
+func Versions(functionDir string) ([]string, error) {
+	entries, err := os.ReadDir(functionDir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.Parse(e.Name()); err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(functionDir, e.Name(), "manifest.json")); err != nil {
+			continue
+		}
+		versions = append(versions, e.Name())
+	}
+	semver.Sort(versions)
+	return versions, nil
+}
@@ -0,0 +1,80 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeManifest(t *testing.T, dir, version string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := `{"name": "deploy", "version": "` + version + `"}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func TestFindVersionSideBySide(t *testing.T) {
+	dir := t.TempDir()
+	for _, v := range []string{"1.0.0", "1.2.0", "1.2.5", "2.0.0-rc.1"} {
+		writeManifest(t, filepath.Join(dir, v), v)
+	}
+	// Not a version directory
+	writeManifest(t, filepath.Join(dir, "backup"), "9.9.9")
+
+	testcases := []struct {
+		constraint string
+		expected   string
+	}{
+		{"latest", "1.2.5"},
+		{"", "1.2.5"},
+		{"^1.2", "1.2.5"},
+		{"~1.2.0", "1.2.5"},
+		{"1.0", "1.0.0"},
+		{"1.2.0", "1.2.0"},
+		{"2.0.0-rc.1", "2.0.0-rc.1"},
+		{"<1.2", "1.0.0"},
+	}
+	for _, c := range testcases {
+		d, v, err := FindVersion(dir, c.constraint)
+		assert.NoError(t, err, c.constraint)
+		assert.Equal(t, c.expected, v, c.constraint)
+		assert.Equal(t, filepath.Join(dir, c.expected), d, c.constraint)
+	}
+
+	_, _, err := FindVersion(dir, "^3")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	assert.Contains(t, err.Error(), "2.0.0-rc.1, 1.2.5, 1.2.0, 1.0.0")
+
+	_, _, err = FindVersion(dir, "^bad")
+	assert.Error(t, err)
+}
+
+func TestFindVersionSingle(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest(t, dir, "1.3.0")
+
+	d, v, err := FindVersion(dir, "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, dir, d)
+	assert.Equal(t, "1.3.0", v)
+
+	_, v, err = FindVersion(dir, "^1.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.0", v)
+
+	_, _, err = FindVersion(dir, "1.2")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	unversioned := t.TempDir()
+	writeManifest(t, unversioned, "")
+	_, v, err = FindVersion(unversioned, "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, "", v)
+	_, _, err = FindVersion(unversioned, "1.0.0")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/skoowoo/cofx/pkg/enabled"
+	"github.com/skoowoo/cofx/pkg/eval"
 )
 
 type Block struct {
@@ -80,6 +81,24 @@ func (b *Block) AddField2Var(name, field, val string) error {
 	return nil
 }
 
+// ResetFields replaces all fields of the variable with 'fields', e.g. the return values of the trigger that
+// fired are bound to its variable, the fields are removed if 'fields' is empty.
+func (b *Block) ResetFields(name string, fields map[string]string) error {
+	v, _ := b.getVar(name)
+	if v == nil {
+		return fmt.Errorf("%w: variable '%s'", ErrVariableNotDefined, name)
+	}
+	v.resetFields(fields)
+	return nil
+}
+
+// HasCondition returns true if the block has a condition, e.g. the block is in 'if' or the event 'co' has
+// the 'when' filter.
+func (b *Block) HasCondition() bool {
+	_, ok := b.vtbl.get(_condition_expr_var)
+	return ok
+}
+
 func (b *Block) ExecCondition() bool {
 	_, ok := b.vtbl.get(_condition_expr_var)
 	if !ok {
@@ -223,6 +242,93 @@ func (b *Block) GetVarValue(name string) string {
 	return v
 }
 
+// Line returns the line number of the block in the flowl source file.
+func (b *Block) Line() int {
+	return b.kind.ln
+}
+
+// Vars returns all variables that can be seen by the block and their current values, the
+// variable defined in the nearest block wins. Field variables are returned as 'name.field'.
+func (b *Block) Vars() map[string]string {
+	vars := make(map[string]string)
+	for p := b; p != nil; p = p.parent {
+		p.vtbl.Lock()
+		names := make([]string, 0, len(p.vtbl.vars))
+		for name := range p.vtbl.vars {
+			names = append(names, name)
+		}
+		p.vtbl.Unlock()
+
+		for _, name := range names {
+			if name == _condition_expr_var {
+				continue
+			}
+			if _, ok := vars[name]; ok {
+				continue
+			}
+			v, _ := p.vtbl.get(name)
+			if v.isenv {
+				continue
+			}
+			vars[name] = p.GetVarValue(name)
+			v.Lock()
+			for field, val := range v.fields {
+				vars[name+"."+field] = val
+			}
+			v.Unlock()
+		}
+	}
+	return vars
+}
+
+// SetVarValue overwrites the value of the variable with a literal string, the argument 'name' can
+// be a field variable such as 'out.key'.
+func (b *Block) SetVarValue(name, value string) error {
+	if main, field, ok := isFieldVar(name); ok {
+		return b.AddField2Var(main, field, value)
+	}
+	v, inblock := b.getVar(name)
+	if v == nil {
+		return fmt.Errorf("%w: variable '%s'", ErrVariableNotDefined, name)
+	}
+	inblock.putVar(name, &_var{
+		v: value,
+		segments: []struct {
+			str   string
+			isvar bool
+		}{{value, false}},
+		cached: true,
+	})
+	return nil
+}
+
+// Evaluate replaces the variables like '$(name)' in the string 's' with their values in the scope
+// of the block, then tries to evaluate the result as an expression. If the result isn't a valid
+// expression, the replaced string will be returned.
+func (b *Block) Evaluate(s string) (res string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	t := &Token{
+		str: s,
+		typ: _string_t,
+		_b:  b,
+	}
+	if err := t.extractVar(); err != nil {
+		return "", err
+	}
+	if err := t.validate(); err != nil {
+		return "", err
+	}
+	res = t.Value()
+	if v, err := eval.String(res); err == nil {
+		return v, nil
+	}
+	return res, nil
+}
+
 // GetVar lookup variable by name in map
 func (b *Block) getVar(name string) (*_var, *Block) {
 	for p := b; p != nil; p = p.parent {
@@ -348,6 +454,30 @@ func (m *MapBody) ToMap() map[string]string {
 	return ret
 }
 
+// MapEntry is a kv of the map body, Static means the value doesn't refer to any variable, so the value is
+// known before the flow runs.
+type MapEntry struct {
+	Key    string
+	Value  string
+	Static bool
+	Line   int
+}
+
+// Entries returns the kvs of the map body in the order of the source.
+func (m *MapBody) Entries() []MapEntry {
+	var entries []MapEntry
+	for _, ln := range m.lines {
+		k, v := ln.tokens[0], ln.tokens[1]
+		entries = append(entries, MapEntry{
+			Key:    k.Value(),
+			Value:  v.Value(),
+			Static: !v.hasVar(),
+			Line:   v.ln,
+		})
+	}
+	return entries
+}
+
 func (m *MapBody) Append(o interface{}) error {
 	ts := o.([]*Token)
 	if len(ts) != 3 {
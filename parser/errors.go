@@ -51,6 +51,7 @@ var (
 	ErrStatementInferFailed error = errors.New("statement infer failed")
 	ErrStatementTooMany     error = errors.New("statement too many")
 	ErrIdentConflict        error = errors.New("ident conflict")
+	ErrConditionMissing     error = errors.New("condition missing")
 )
 
 func statementErrorf(ln int, err error, format string, args ...interface{}) error {
@@ -202,6 +202,8 @@ type AST struct {
 	global Block
 	// We use the first line comment in the flowl file as the description of the flow
 	desc string
+	// tags are declared by the 'tags:' comment before the first statement, e.g. // tags: ci, nightly
+	tags []string
 
 	// for parsing
 	_InferTree *inferNode
@@ -275,6 +277,11 @@ func (ast *AST) Desc() string {
 	return ast.desc
 }
 
+// Tags returns the tags of the flow, they are used to select a group of flows, e.g. by the daemon.
+func (ast *AST) Tags() []string {
+	return ast.tags
+}
+
 func (ast *AST) GetBlocks() (loads []*Block, fns []*Block, runs []*Block) {
 	ast.Foreach(func(b *Block) error {
 		if b.IsLoad() {
@@ -317,9 +324,15 @@ func (ast *AST) scan(lx *lexer) error {
 		}
 		// the line is a commment
 		if line[0].String() == _kw_comment {
-			// save the first line comment as the description of the flow
-			if ast.desc == "" && len(ast.global.child) == 0 && len(line) > 1 {
-				ast.desc = line[1].String()
+			if len(ast.global.child) == 0 && len(line) > 1 {
+				if tags, ok := parseTags(line[1].String()); ok {
+					ast.tags = append(ast.tags, tags...)
+					return nil
+				}
+				// save the first line comment as the description of the flow
+				if ast.desc == "" {
+					ast.desc = line[1].String()
+				}
 			}
 			// discard the other line comments
 			return nil
@@ -1187,15 +1200,26 @@ func (ast *AST) parseEventBody(line []*Token, ln int, current *Block) (*Block, e
 	kind := line[0]
 	switch kind.String() {
 	case _kw_co:
+		line, when := splitWhen(line)
 		block, err := ast.parseCo(line, ln, current)
 		if err != nil {
 			return nil, err
 		}
+		if when != nil {
+			if err := parseWhen(block, when, ln); err != nil {
+				return nil, err
+			}
+		}
 		// need to goto and parse the body of 'co'
 		if block.body != nil {
 			ast._goto(_ast_co_body)
 			return block, nil
 		}
+	case _kw_var:
+		// the variables of 'event' are the options of the event triggers, e.g. policy, max_queue
+		if err := ast.parseVar(line, ln, current); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, statementErrorf(ln, ErrStatementUnknow, "%s", kind)
 	}
@@ -1203,6 +1227,46 @@ func (ast *AST) parseEventBody(line []*Token, ln int, current *Block) (*Block, e
 	return current, nil
 }
 
+// splitWhen splits the 'when' filter from the line of the event 'co', e.g. co event_http -> ev when $(ev.ref) == "main" {,
+// the tokens of the expression are returned, they're nil if the line has no filter.
+func splitWhen(line []*Token) ([]*Token, []*Token) {
+	for i, t := range line {
+		if t.String() != _kw_when || !t.TypeEqual(_ident_t) {
+			continue
+		}
+		co := append([]*Token{}, line[:i]...)
+		when := line[i+1:]
+		if l := len(when); l > 0 && when[l-1].String() == "{" {
+			co = append(co, when[l-1])
+			when = when[:l-1]
+		}
+		return co, when
+	}
+	return line, nil
+}
+
+// parseWhen adds the 'when' filter as the condition of the event 'co' block, the event is dropped if the
+// condition is false.
+func parseWhen(b *Block, tokens []*Token, ln int) error {
+	if len(tokens) == 0 {
+		return statementErrorf(ln, ErrConditionMissing, "'%s' of event trigger", _kw_when)
+	}
+	expr := newExpression(tokens).ToToken()
+	expr.ln = ln
+	expr._b = b
+	if err := expr.extractVar(); err != nil {
+		return err
+	}
+	cond := &Token{
+		ln:  ln,
+		_b:  b,
+		str: _condition_expr_var,
+		typ: _varname_t,
+	}
+	stm := NewStatement("var").Append(cond).Append(expr)
+	return b.initVar(stm)
+}
+
 func (ast *AST) parseBuiltDirective(line []*Token, ln int, parent *Block) error {
 	b := &Block{
 		parent: parent,
@@ -1363,3 +1427,18 @@ func parseRewriteVarWithExp(b *Block, line []*Token, ln int) error {
 	//}
 	return b.body.Append(stm)
 }
+
+// parseTags parses the tags from the comment like 'tags: ci, nightly', it returns false if the comment
+// doesn't declare tags.
+func parseTags(comment string) ([]string, bool) {
+	if !strings.HasPrefix(comment, "tags:") {
+		return nil, false
+	}
+	var tags []string
+	for _, t := range strings.Split(strings.TrimPrefix(comment, "tags:"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags, true
+}
@@ -134,6 +134,23 @@ load "shell:function3"
 	check(blocks[4], "go:function4")
 }
 
+func TestDescAndTags(t *testing.T) {
+	const testingdata string = `
+// Build and release the binaries
+// tags: ci, release,
+load "go:print"
+
+// tags: ignored
+co print
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "Build and release the binaries", ast.Desc())
+	assert.Equal(t, []string{"ci", "release"}, ast.Tags())
+}
+
 func TestParseBlocksOnlyfn(t *testing.T) {
 	const testingdata string = `
 	fn f1 = function1 {
@@ -621,6 +638,64 @@ func TestEvent(t *testing.T) {
 		assert.Equal(t, _kw_co, blocks[2].kind.String())
 		assert.Equal(t, _kw_co, blocks[3].kind.String())
 	}
+	{
+		const testingdata string = `
+		var out
+		event {
+			var policy = "skip"
+			var max_queue = 10
+			co function1 -> out
+		}
+	`
+		blocks, err := loadTestingdata(testingdata)
+		assert.NoError(t, err)
+
+		assert.Len(t, blocks, 3)
+		assert.Equal(t, _kw_event, blocks[1].kind.String())
+		assert.Equal(t, "skip", blocks[1].GetVarValue("policy"))
+		assert.Equal(t, "10", blocks[1].GetVarValue("max_queue"))
+	}
+	{
+		const testingdata string = `
+		var out
+		var cron
+		event {
+			co function1 -> out when $(out.ref) == "refs/heads/main" && $(out.event) == "push" {
+				"k": "v"
+			}
+			co function2 -> cron
+		}
+	`
+		blocks, err := loadTestingdata(testingdata)
+		assert.NoError(t, err)
+
+		assert.Len(t, blocks, 4)
+		cob := blocks[2]
+		assert.Equal(t, "function1", cob.Target1().String())
+		assert.Equal(t, "out", cob.Target2().String())
+		assert.Equal(t, map[string]string{"k": "v"}, cob.Body().(*MapBody).ToMap())
+		assert.True(t, cob.HasCondition())
+		assert.False(t, cob.ExecCondition())
+
+		assert.NoError(t, cob.ResetFields("out", map[string]string{"ref": "refs/heads/main", "event": "push"}))
+		assert.True(t, cob.ExecCondition())
+		assert.NoError(t, cob.ResetFields("out", map[string]string{"ref": "refs/heads/dev", "event": "push"}))
+		assert.False(t, cob.ExecCondition())
+		assert.ErrorIs(t, cob.ResetFields("notfound", nil), ErrVariableNotDefined)
+
+		assert.False(t, blocks[3].HasCondition())
+		assert.True(t, blocks[3].ExecCondition())
+	}
+	{
+		const testingdata string = `
+		var out
+		event {
+			co function1 -> out when
+		}
+	`
+		_, err := loadTestingdata(testingdata)
+		assert.ErrorContains(t, err, ErrConditionMissing.Error())
+	}
 }
 
 func TestIf(t *testing.T) {
@@ -804,3 +879,30 @@ func TestBuiltinDirective(t *testing.T) {
 		assert.True(t, blocks[7].ExecCondition())
 	}
 }
+
+func TestMapBodyEntries(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+var name = "cofx"
+
+co print {
+	"static": "v1"
+	"ref": "hello $(name)"
+}
+	`
+	blocks, err := loadTestingdata(testingdata)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var entries []MapEntry
+	for _, b := range blocks {
+		if m, ok := b.Body().(*MapBody); ok && b.IsCo() {
+			entries = m.Entries()
+		}
+	}
+	assert.Equal(t, []MapEntry{
+		{Key: "static", Value: "v1", Static: true, Line: 7},
+		{Key: "ref", Value: "hello cofx", Static: false, Line: 8},
+	}, entries)
+}
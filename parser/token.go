@@ -22,6 +22,7 @@ const (
 	_kw_case    = "case"
 	_kw_default = "default"
 	_kw_event   = "event"
+	_kw_when    = "when"
 )
 
 var keywordTable = map[string]struct{}{
@@ -37,6 +38,7 @@ var keywordTable = map[string]struct{}{
 	_kw_switch:  {},
 	_kw_var:     {},
 	_kw_event:   {},
+	_kw_when:    {},
 }
 
 func iskeyword(ss ...string) (string, bool) {
@@ -144,7 +146,7 @@ func (t *Token) String() string {
 }
 
 func (t *Token) FormatString() string {
-	return fmt.Sprintf("['%s','%s']", t.str, t.typ)
+	return fmt.Sprintf("['%s','%d']", t.str, t.typ)
 }
 
 func _lookupVar(b *Block, name string) (string, bool) {
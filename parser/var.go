@@ -139,6 +139,15 @@ func (v *_var) addField(key, val string) {
 	v.fields[key] = val
 }
 
+func (v *_var) resetFields(fields map[string]string) {
+	v.Lock()
+	defer v.Unlock()
+	v.fields = make(map[string]string, len(fields))
+	for k, val := range fields {
+		v.fields[k] = val
+	}
+}
+
 func (v *_var) readField(f string) string {
 	v.Lock()
 	defer v.Unlock()
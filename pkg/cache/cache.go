@@ -0,0 +1,173 @@
+// Package cache stores the return values of the functions on disk, every entry is a json file named by
+// its key and expires after its TTL.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const suffix = ".json"
+
+// Entry is a cached result of a function.
+type Entry struct {
+	Key string `json:"key"`
+	// Function is the function that the result belongs to, e.g. go:http_get@1.0.0
+	Function string            `json:"function"`
+	Created  time.Time         `json:"created"`
+	Expires  time.Time         `json:"expires"`
+	Returns  map[string]string `json:"returns"`
+}
+
+// Expired returns true if the entry is expired.
+func (e Entry) Expired() bool {
+	return time.Now().After(e.Expires)
+}
+
+// Key returns the hex string of the sha256 of the json encoding of v, the keys of the maps are sorted by
+// the json encoding, so the same inputs always get the same key.
+func Key(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Cache is the entries stored in a directory.
+type Cache struct {
+	sync.Mutex
+	dir string
+}
+
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Get returns the returns of the entry, the expired entry is removed and isn't returned.
+func (c *Cache) Get(key string) (map[string]string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, err := c.read(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	if e.Expired() {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return e.Returns, true
+}
+
+// Put saves the returns of the function with the key, the entry expires after ttl.
+func (c *Cache) Put(key, function string, ttl time.Duration, returns map[string]string) error {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	b, err := json.Marshal(Entry{
+		Key:      key,
+		Function: function,
+		Created:  now,
+		Expires:  now.Add(ttl),
+		Returns:  returns,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, ".put-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// List returns all entries including the expired ones, the latest created entry is the first.
+func (c *Cache) List() ([]Entry, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.list()
+}
+
+// Clear removes the entries of the function, all entries are removed if the function is empty. The
+// function matches the entries of all versions if it has no version, e.g. go:http_get. Only the expired
+// entries are removed if expired is true. It returns the number of the removed entries.
+func (c *Cache) Clear(function string, expired bool) (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	entries, err := c.list()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, e := range entries {
+		if function != "" && e.Function != function && !strings.HasPrefix(e.Function, function+"@") {
+			continue
+		}
+		if expired && !e.Expired() {
+			continue
+		}
+		if err := os.Remove(c.path(e.Key)); err != nil && !os.IsNotExist(err) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (c *Cache) list() ([]Entry, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), suffix) {
+			continue
+		}
+		e, err := c.read(filepath.Join(c.dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	return entries, nil
+}
+
+func (c *Cache) read(path string) (Entry, error) {
+	var e Entry
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(b, &e)
+	return e, err
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+suffix)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	k1, err := Key(map[string]string{"a": "1", "b": "2"})
+	assert.NoError(t, err)
+	k2, err := Key(map[string]string{"b": "2", "a": "1"})
+	assert.NoError(t, err)
+	assert.Equal(t, k1, k2)
+	assert.Len(t, k1, 64)
+
+	k3, err := Key(map[string]string{"a": "1", "b": "3"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, k1, k3)
+}
+
+func TestCache(t *testing.T) {
+	c := New(t.TempDir())
+
+	_, ok := c.Get("k1")
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Put("k1", "go:http_get@1.0.0", time.Hour, map[string]string{"status_code": "200"}))
+	assert.NoError(t, c.Put("k2", "go:http_get@1.1.0", time.Hour, nil))
+	assert.NoError(t, c.Put("k3", "go:go_build@1.0.0", -time.Second, nil))
+
+	rets, ok := c.Get("k1")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"status_code": "200"}, rets)
+
+	entries, err := c.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "k3", entries[0].Key)
+	assert.True(t, entries[0].Expired())
+
+	// The expired entry is removed when it's read
+	_, ok = c.Get("k3")
+	assert.False(t, ok)
+	entries, err = c.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.NoError(t, c.Put("k3", "go:go_build@1.0.0", -time.Second, nil))
+	n, err := c.Clear("", true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	n, err = c.Clear("go:http_get@1.1.0", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.NoError(t, c.Put("k2", "go:http_get@1.1.0", time.Hour, nil))
+	n, err = c.Clear("go:http", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	n, err = c.Clear("go:http_get", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	entries, err = c.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
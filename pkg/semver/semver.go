@@ -0,0 +1,242 @@
+// Package semver parses the semantic versions of the functions, and matches them with the version
+// constraints of the 'load' statement, e.g. load "shell:deploy@^1.2".
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidVersion    = errors.New("invalid version")
+	ErrInvalidConstraint = errors.New("invalid constraint")
+)
+
+// Latest is the alias of the highest version.
+const Latest = "latest"
+
+// Version is a semantic version, e.g. 1.2.3, v1.2.3-beta.1
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string
+	// parts is the number of the numeric parts in the string, e.g. 2 for '1.2'
+	parts int
+}
+
+// Parse parses the string into a version, the prefix 'v' and the missing minor and patch are allowed,
+// e.g. v1, 1.2, 1.2.3-rc.1
+func Parse(s string) (Version, error) {
+	var v Version
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if str == "" {
+		return v, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+	}
+	if i := strings.IndexAny(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.Pre = str[i+1:]
+		str = str[:i]
+		if v.Pre == "" {
+			return v, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+		}
+	}
+	fields := strings.Split(str, ".")
+	if len(fields) > 3 {
+		return v, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+		}
+		*nums[i] = n
+	}
+	v.parts = len(fields)
+	return v, nil
+}
+
+// String returns the full form of the version without the prefix 'v', e.g. 1.2.0
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if the version is less than, equal to or greater than the other one.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	}
+	return 1
+}
+
+type bound struct {
+	op string
+	v  Version
+}
+
+func (b bound) check(v Version) bool {
+	c := v.Compare(b.v)
+	switch b.op {
+	case "=":
+		return c == 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// Constraint is a set of the conditions that a version must satisfy.
+type Constraint struct {
+	raw    string
+	bounds []bound
+	// exact means the constraint is a full version, it's able to match a pre-release version.
+	exact bool
+}
+
+// ParseConstraint parses the constraint string, the supported forms are:
+//
+//	latest, *, empty    any version, the highest wins
+//	1.2.3               exactly 1.2.3
+//	1.2, 1              1.2.x, 1.x.x
+//	^1.2.3              >=1.2.3 <2.0.0, ^0.2.3 is >=0.2.3 <0.3.0
+//	~1.2.3              >=1.2.3 <1.3.0
+//	>=1.2 <2            the conditions separated by space or comma must all be satisfied
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	str := strings.TrimSpace(s)
+	if str == "" || str == Latest || str == "*" {
+		return c, nil
+	}
+	for _, field := range strings.FieldsFunc(str, func(r rune) bool { return r == ',' || r == ' ' }) {
+		bounds, exact, err := parseBounds(field)
+		if err != nil {
+			return c, fmt.Errorf("%w: '%s'", ErrInvalidConstraint, s)
+		}
+		c.bounds = append(c.bounds, bounds...)
+		c.exact = c.exact || exact
+	}
+	return c, nil
+}
+
+func parseBounds(s string) ([]bound, bool, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(s, op) {
+			v, err := Parse(s[len(op):])
+			if err != nil {
+				return nil, false, err
+			}
+			return []bound{{op, v}}, op == "=", nil
+		}
+	}
+
+	var prefix string
+	if strings.HasPrefix(s, "^") || strings.HasPrefix(s, "~") {
+		prefix, s = s[:1], s[1:]
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return nil, false, err
+	}
+	if prefix == "" && v.parts == 3 {
+		return []bound{{"=", v}}, true, nil
+	}
+
+	upper := Version{Major: v.Major + 1}
+	switch {
+	case prefix == "^" && v.Major == 0 && v.parts > 1 && (v.Minor > 0 || v.parts == 2):
+		upper = Version{Minor: v.Minor + 1}
+	case prefix == "^" && v.Major == 0 && v.parts == 3:
+		upper = Version{Patch: v.Patch + 1}
+	case prefix != "^" && v.parts > 1:
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	lower := v
+	lower.Pre = ""
+	return []bound{{">=", lower}, {"<", upper}}, false, nil
+}
+
+// String returns the original string of the constraint.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+// Any returns true if the constraint matches any version, e.g. 'latest'.
+func (c Constraint) Any() bool {
+	return len(c.bounds) == 0
+}
+
+// Check returns true if the version satisfies the constraint, a pre-release version only satisfies
+// the exact constraint.
+func (c Constraint) Check(v Version) bool {
+	if v.Pre != "" && !c.exact {
+		return false
+	}
+	for _, b := range c.bounds {
+		if !b.check(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Highest returns the highest version that satisfies the constraint in the list, the invalid versions in
+// the list are ignored.
+func Highest(c Constraint, versions []string) (string, bool) {
+	var (
+		found  string
+		latest Version
+	)
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if found == "" || v.Compare(latest) > 0 {
+			found, latest = s, v
+		}
+	}
+	return found, found != ""
+}
+
+// Sort sorts the versions from the highest to the lowest, the invalid versions are at the end.
+func Sort(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, erri := Parse(versions[i])
+		vj, errj := Parse(versions[j])
+		if erri != nil || errj != nil {
+			return erri == nil
+		}
+		return vi.Compare(vj) > 0
+	})
+}
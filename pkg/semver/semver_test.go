@@ -0,0 +1,97 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	testings := []struct {
+		s      string
+		expect string
+		err    bool
+	}{
+		{"1.2.3", "1.2.3", false},
+		{"v1.2.3", "1.2.3", false},
+		{"1.2", "1.2.0", false},
+		{"v2", "2.0.0", false},
+		{"1.2.3-rc.1", "1.2.3-rc.1", false},
+		{"1.2.3+build", "1.2.3", false},
+		{"", "", true},
+		{"latest", "", true},
+		{"1.2.3.4", "", true},
+		{"1.x", "", true},
+		{"1.2.3-", "", true},
+	}
+	for _, tt := range testings {
+		v, err := Parse(tt.s)
+		if tt.err {
+			assert.ErrorIs(t, err, ErrInvalidVersion, tt.s)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expect, v.String())
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	testings := []struct {
+		constraint string
+		matched    []string
+		unmatched  []string
+	}{
+		{"latest", []string{"0.0.1", "1.2.3", "10.0.0"}, []string{"1.0.0-rc.1"}},
+		{"", []string{"1.2.3"}, nil},
+		{"1.2.3", []string{"1.2.3", "v1.2.3"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"v1", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0"}},
+		{"^1.2", []string{"1.2.0", "1.9.0"}, []string{"1.1.9", "2.0.0", "1.5.0-beta"}},
+		{"^1.2.3", []string{"1.2.3", "1.3.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{">=1.2 <2", []string{"1.2.0", "1.9.9"}, []string{"1.1.0", "2.0.0"}},
+		{">1.0.0,<=1.2.0", []string{"1.0.1", "1.2.0"}, []string{"1.0.0", "1.2.1"}},
+		{"=1.0.0-rc.1", []string{"1.0.0-rc.1"}, []string{"1.0.0"}},
+	}
+	for _, tt := range testings {
+		c, err := ParseConstraint(tt.constraint)
+		assert.NoError(t, err, tt.constraint)
+		for _, s := range tt.matched {
+			v, _ := Parse(s)
+			assert.True(t, c.Check(v), "%s should match %s", tt.constraint, s)
+		}
+		for _, s := range tt.unmatched {
+			v, _ := Parse(s)
+			assert.False(t, c.Check(v), "%s shouldn't match %s", tt.constraint, s)
+		}
+	}
+
+	for _, s := range []string{"^", "~x", ">=a", "1.2.3.4"} {
+		_, err := ParseConstraint(s)
+		assert.ErrorIs(t, err, ErrInvalidConstraint, s)
+	}
+}
+
+func TestHighest(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "v1.10.0", "2.0.0-rc.1", "2.0.0", "dev"}
+
+	c, _ := ParseConstraint("^1.2")
+	v, ok := Highest(c, versions)
+	assert.True(t, ok)
+	assert.Equal(t, "v1.10.0", v)
+
+	c, _ = ParseConstraint("latest")
+	v, ok = Highest(c, versions)
+	assert.True(t, ok)
+	assert.Equal(t, "2.0.0", v)
+
+	c, _ = ParseConstraint("^3")
+	_, ok = Highest(c, versions)
+	assert.False(t, ok)
+
+	Sort(versions)
+	assert.Equal(t, []string{"2.0.0", "2.0.0-rc.1", "v1.10.0", "1.2.0", "1.0.0", "dev"}, versions)
+}
@@ -7,18 +7,26 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/skoowoo/cofx/functiondriver"
 	"github.com/skoowoo/cofx/parser"
 	"github.com/skoowoo/cofx/service/resource"
 )
 
+// The default delays before retrying a failed trigger.
+const (
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = 300 * time.Second
+)
+
 // RunQueue
 type RunQueue struct {
 	locations         functiondriver.LocationStore
 	configured        map[string]*TaskNode
 	steps             []Node
 	triggers          []Trigger
+	event             *parser.Block
 	global            *parser.Block
 	processingForNode *ForNode
 }
@@ -63,6 +71,68 @@ func (r *RunQueue) GetTriggers() []Trigger {
 	return r.triggers
 }
 
+// EventOption returns the value of the option defined by 'var' in the 'event' block, returns an empty
+// string if the flow hasn't the 'event' block or the option isn't defined.
+func (r *RunQueue) EventOption(name string) string {
+	if r.event == nil {
+		return ""
+	}
+	return r.event.GetVarValue(name)
+}
+
+// BindEvent binds the return values of the trigger to its variable for the running of the flow, the
+// variables of the other triggers are cleared, so the flow can tell which trigger fired. If the seq doesn't
+// belong to any trigger, e.g. the event is sent manually, the returns are bound to all variables.
+func (r *RunQueue) BindEvent(seq int, returns map[string]string) error {
+	fired := r.trigger(seq)
+	for _, tg := range r.triggers {
+		n := tg.(*TaskNode)
+		if n.returnVar == "" {
+			continue
+		}
+		if err := n.co.ResetFields(n.returnVar, nil); err != nil {
+			return err
+		}
+	}
+	for _, tg := range r.triggers {
+		n := tg.(*TaskNode)
+		if n.returnVar == "" || (fired != nil && n != fired) {
+			continue
+		}
+		if err := n.co.ResetFields(n.returnVar, returns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchEvent binds the return values to the variable of the trigger, then evaluates its 'when' filter, it
+// returns false if the event should be dropped. The event not from the triggers always matches.
+func (r *RunQueue) MatchEvent(seq int, returns map[string]string) (ok bool, err error) {
+	fired := r.trigger(seq)
+	if fired == nil || !fired.co.HasCondition() {
+		return true, nil
+	}
+	if err := r.BindEvent(seq, returns); err != nil {
+		return false, err
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			ok, err = false, fmt.Errorf("%w: trigger '%s', %v", ErrInvalidWhen, fired.name, v)
+		}
+	}()
+	return fired.co.ExecCondition(), nil
+}
+
+func (r *RunQueue) trigger(seq int) *TaskNode {
+	for _, tg := range r.triggers {
+		if n := tg.(*TaskNode); n.seq == seq {
+			return n
+		}
+	}
+	return nil
+}
+
 // WalkNode traverses all task nodes in order
 func (r *RunQueue) WalkNode(do func(Node) error) error {
 	for _, e := range r.steps {
@@ -156,9 +226,9 @@ func (r *RunQueue) createNode(nodename, fname string) (*TaskNode, error) {
 	if !ok {
 		return nil, wrapErrorf(ErrFunctionNotLoaded, "'%s'", fname)
 	}
-	driver := functiondriver.New(location)
-	if driver == nil {
-		return nil, wrapErrorf(ErrDriverNotFound, "'%s'", location)
+	driver, err := functiondriver.New(location)
+	if err != nil {
+		return nil, wrapErrorf(err, "'%s'", location)
 	}
 	node := &TaskNode{
 		name:   nodename,
@@ -177,10 +247,46 @@ func (r *RunQueue) generateLocations(blocks []*parser.Block) error {
 	return nil
 }
 
+// createInlineNode creates the node of the function whose code is defined by the option 'code' of the
+// 'fn' block, e.g. 'fn reshape = script { var code = "..." }', the driver name is used as the function.
+func (r *RunQueue) createInlineNode(nodename, dname string, fn *parser.Block) (*TaskNode, error) {
+	driver, err := functiondriver.New(functiondriver.Location{
+		DriverName: dname,
+		FuncName:   nodename,
+		Version:    "inline",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: inline function of node '%s'", err, nodename)
+	}
+	inline, ok := driver.(functiondriver.InlineDriver)
+	if !ok {
+		return nil, wrapErrorf(ErrFunctionNotLoaded, "'%s'", dname)
+	}
+	inline.Inline(fn.GetVarValue)
+	node := &TaskNode{
+		name:   nodename,
+		driver: inline,
+	}
+	return node, nil
+}
+
+// Location returns the location of the function loaded by the 'load' statement.
+func (r *RunQueue) Location(fname string) (functiondriver.Location, bool) {
+	return r.locations.Get(fname)
+}
+
 func (r *RunQueue) generateConfiguredFn(blocks []*parser.Block) error {
 	for _, b := range blocks {
 		nodename, fname := b.Target1().String(), b.Target2().String()
-		node, err := r.createNode(nodename, fname)
+		var (
+			node *TaskNode
+			err  error
+		)
+		if _, loaded := r.locations.Get(fname); !loaded && b.GetVarValue("code") != "" {
+			node, err = r.createInlineNode(nodename, fname, b)
+		} else {
+			node, err = r.createNode(nodename, fname)
+		}
 		if err != nil {
 			return err
 		}
@@ -195,6 +301,7 @@ func (r *RunQueue) generateEventTriggers(blocks []*parser.Block) error {
 	seq := 10000
 	for _, b := range blocks {
 		if b.IsCo() && b.Parent().IsEvent() {
+			r.event = b.Parent()
 			name := b.Target1().String()
 			node := r.getConfigured(name)
 			if node == nil {
@@ -326,6 +433,11 @@ type Task interface {
 	Driver() functiondriver.Driver
 	IgnoreFailure() bool
 	RetryOnFailure() int
+	Backoff() (time.Duration, time.Duration, error)
+	Block() *parser.Block
+	LastReturns() map[string]string
+	// Cached returns true if the return values of the last execution are from the cache
+	Cached() bool
 }
 
 type Trigger interface {
@@ -415,6 +527,16 @@ type TaskNode struct {
 
 	_args    *parser.MapBody
 	parallel *TaskNode
+	// loaded means the function has been loaded into the driver, so its manifest is available
+	loaded bool
+	// lastReturns saves the return values of the last execution
+	lastReturns map[string]string
+	// cache stores the return values of the node, it's nil if the resources don't provide it
+	cache resource.ResultCache
+	// cached means the return values of the last execution are from the cache
+	cached bool
+	// logwriter is the log writer of the node, the failures that don't fail the node are written into it
+	logwriter io.Writer
 }
 
 func (n *TaskNode) Step() int {
@@ -429,6 +551,16 @@ func (n *TaskNode) Driver() functiondriver.Driver {
 	return n.driver
 }
 
+// Block returns the 'co' block that starts the node.
+func (n *TaskNode) Block() *parser.Block {
+	return n.co
+}
+
+// LastReturns returns the return values of the last execution of the node.
+func (n *TaskNode) LastReturns() map[string]string {
+	return n.lastReturns
+}
+
 func (n *TaskNode) IgnoreFailure() bool {
 	ignore := n.driver.Manifest().IgnoreFailure
 	if n.fn != nil {
@@ -451,6 +583,45 @@ func (n *TaskNode) RetryOnFailure() int {
 	return retries
 }
 
+// Backoff returns the initial and the max delay before retrying the trigger after it failed, the
+// options 'backoff' and 'max_backoff' are read from the fn block of the trigger first, then the 'event'
+// block. The delay is doubled after every failure until reaching the max delay.
+func (n *TaskNode) Backoff() (time.Duration, time.Duration, error) {
+	var (
+		initial = DefaultBackoff
+		max     = DefaultMaxBackoff
+	)
+	option := func(name string) string {
+		if n.fn != nil {
+			if v := n.fn.GetVarValue(name); v != "" {
+				return v
+			}
+		}
+		if n.co != nil && n.co.Parent() != nil {
+			return n.co.Parent().GetVarValue(name)
+		}
+		return ""
+	}
+	if v := option("backoff"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, wrapErrorf(ErrInvalidBackoff, "backoff '%s'", v)
+		}
+		initial = d
+	}
+	if v := option("max_backoff"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, wrapErrorf(ErrInvalidBackoff, "max_backoff '%s'", v)
+		}
+		max = d
+	}
+	if max < initial {
+		max = initial
+	}
+	return initial, max, nil
+}
+
 func (n *TaskNode) FormatString() string {
 	return n.name + " ➜ " + n.driver.Name() + ":" + n.driver.FunctionName()
 }
@@ -460,7 +631,7 @@ func (n *TaskNode) Name() string {
 }
 
 func (n *TaskNode) Init(ctx context.Context, with ...func(context.Context, Node) error) error {
-	with = append(with, withArgs())
+	with = append(with, withArgs(), withStaticArgsValidation())
 	for _, f := range with {
 		if err := f(ctx, n); err != nil {
 			return err
@@ -482,16 +653,50 @@ func (n *TaskNode) Exec(ctx context.Context) error {
 		}
 	}
 
-	rets, err := n.driver.Run(ctx, n.args())
+	if err := n.validateArgs(true); err != nil {
+		return err
+	}
+	args := n.argsWithDefaults()
+	n.cached = false
+	key, ttl, err := n.prepareCache(args)
 	if err != nil {
 		return err
 	}
+	if key != "" {
+		if rets, ok := n.cache.Get(key); ok {
+			n.cached = true
+			n.lastReturns = rets
+			if n.needReturns() {
+				n.saveReturns(rets, nil)
+			}
+			return nil
+		}
+	}
+	rets, err := n.driver.Run(ctx, args)
+	if err != nil {
+		return err
+	}
+	n.lastReturns = rets
 	if n.needReturns() {
 		n.saveReturns(rets, nil)
 	}
+	if key != "" {
+		if err := n.cache.Put(key, n.cacheFunction(), ttl, rets); err != nil {
+			// The returns are right even if they aren't cached, so the node doesn't fail
+			n.warn(fmt.Errorf("%w: cache the returns of node '%s'", err, n.name))
+		}
+	}
 	return nil
 }
 
+// warn writes the error into the log of the node.
+func (n *TaskNode) warn(err error) {
+	if n.logwriter == nil {
+		return
+	}
+	fmt.Fprintf(n.logwriter, "warning: %s\n", err)
+}
+
 func (n *TaskNode) execCondition(ctx context.Context) error {
 	if n.co.InSwitch() || n.co.InIf() {
 		if !n.co.ExecCondition() {
@@ -508,6 +713,55 @@ func (n *TaskNode) args() map[string]string {
 	return n._args.ToMap()
 }
 
+// argsWithDefaults returns the arguments of the node, the missing arguments are filled with the default
+// values of the usage, unless the manifest has defined them.
+func (n *TaskNode) argsWithDefaults() map[string]string {
+	args := n.args()
+	mf := n.driver.Manifest()
+	for k, v := range mf.Usage.Defaults() {
+		if _, ok := args[k]; ok {
+			continue
+		}
+		if _, ok := mf.Args[k]; ok {
+			continue
+		}
+		args[k] = v
+	}
+	return args
+}
+
+// validateArgs validates the arguments of the node against the usage of the function manifest. If 'all'
+// is false, only the static values are validated, because the values that refer to variables are unknown
+// before the node runs.
+func (n *TaskNode) validateArgs(all bool) error {
+	mf := n.driver.Manifest()
+	if len(mf.Usage.Args) == 0 {
+		return nil
+	}
+	given := make(map[string]parser.MapEntry)
+	if n._args != nil {
+		for _, e := range n._args.Entries() {
+			given[e.Key] = e
+		}
+	}
+	for _, a := range mf.Usage.Args {
+		e, ok := given[a.Name]
+		if !ok {
+			if _, ok := mf.Args[a.Name]; ok || a.Default != "" || !a.Required {
+				continue
+			}
+			return fmt.Errorf("%w: '%s' of node '%s', line %d", ErrMissingArg, a.Name, n.name, n.co.Line())
+		}
+		if !e.Static && !all {
+			continue
+		}
+		if err := a.Check(e.Value); err != nil {
+			return fmt.Errorf("%w: node '%s', line %d", err, n.name, e.Line)
+		}
+	}
+	return nil
+}
+
 // saveReturns will create some field var
 // Field Var are dynamic var
 func (n *TaskNode) saveReturns(retkvs map[string]string, filter func(string) bool) bool {
@@ -523,8 +777,14 @@ func (n *TaskNode) saveReturns(retkvs map[string]string, filter func(string) boo
 	return true
 }
 
+// needReturns returns true if the return values are saved into the variable after the node executed. The
+// returns of the trigger aren't saved, they're bound to the variable by BindEvent when the flow runs.
 func (n *TaskNode) needReturns() bool {
-	return len(n.returnVar) != 0
+	return len(n.returnVar) != 0 && !n.isTrigger()
+}
+
+func (n *TaskNode) isTrigger() bool {
+	return n.co != nil && n.co.Parent() != nil && n.co.Parent().IsEvent()
 }
 
 func withArgs() func(context.Context, Node) error {
@@ -553,13 +813,31 @@ func withArgs() func(context.Context, Node) error {
 	}
 }
 
+// withStaticArgsValidation validates the static arguments of the node after the function is loaded, so the
+// invalid arguments are found before the flow runs.
+func withStaticArgsValidation() func(context.Context, Node) error {
+	return func(ctx context.Context, n Node) error {
+		funcnode, ok := n.(*TaskNode)
+		if !ok || !funcnode.loaded {
+			return nil
+		}
+		return funcnode.validateArgs(false)
+	}
+}
+
 func WithResources(resources resource.Resources) func(context.Context, Node) error {
 	return func(ctx context.Context, n Node) error {
 		funcnode, ok := n.(*TaskNode)
 		if !ok {
 			return nil
 		}
-		return funcnode.driver.Load(ctx, resources)
+		if err := funcnode.driver.Load(ctx, resources); err != nil {
+			return err
+		}
+		funcnode.loaded = true
+		funcnode.cache = resources.Cache
+		funcnode.logwriter = resources.Logwriter
+		return nil
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/skoowoo/cofx/functiondriver"
 	"github.com/skoowoo/cofx/parser"
 	"github.com/stretchr/testify/assert"
 )
@@ -168,6 +169,19 @@ func TestParseFullWithRunqWithErr(t *testing.T) {
 	}
 }
 
+func TestInlineNodeWithErr(t *testing.T) {
+	const testingdata string = `
+	fn f = nodriver {
+		var code = "echo hello"
+	}
+
+	co f
+	`
+	_, _, _, err := loadTestingdata2(testingdata)
+	assert.ErrorIs(t, err, functiondriver.ErrDriverNotFound)
+	assert.Contains(t, err.Error(), "inline function of node 'f'")
+}
+
 func TestBuiltiDirective(t *testing.T) {
 	{
 		const testingdata string = `
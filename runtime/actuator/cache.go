@@ -0,0 +1,163 @@
+package actuator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/cache"
+	"github.com/skoowoo/cofx/pkg/textparse"
+)
+
+// DefaultCacheTTL is the TTL of the cached return values, if the 'cache' option is true and the manifest
+// of the function doesn't define the TTL.
+const DefaultCacheTTL = time.Hour
+
+// CacheTTL returns the TTL of the cached return values of the node, zero means the node isn't cached. The
+// option 'cache' of the fn block is read first, it's a duration or a bool, then the manifest.
+func (n *TaskNode) CacheTTL() (time.Duration, error) {
+	var v string
+	if n.fn != nil {
+		v = n.fn.GetVarValue("cache")
+	}
+	mttl := n.driver.Manifest().Cache
+	if v == "" {
+		v = mttl
+	}
+	if v == "" {
+		return 0, nil
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		if !b {
+			return 0, nil
+		}
+		if d, err := time.ParseDuration(mttl); err == nil && d > 0 {
+			return d, nil
+		}
+		return DefaultCacheTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%w: cache '%s' of node '%s'", ErrInvalidCache, v, n.name)
+	}
+	return d, nil
+}
+
+// Cached returns true if the return values of the last execution are from the cache.
+func (n *TaskNode) Cached() bool {
+	return n.cached
+}
+
+// cacheFunction returns the function that the cached entries belong to, e.g. go:http_get@1.0.0
+func (n *TaskNode) cacheFunction() string {
+	s := n.driver.Name() + ":" + n.driver.FunctionName()
+	if v := n.driver.Manifest().Version; v != "" {
+		s += "@" + v
+	}
+	return s
+}
+
+// cacheKey figures out the key of the cached return values from the function, its version, the merged
+// arguments and the content of the files specified by the option 'cache_key_files'.
+func (n *TaskNode) cacheKey(args map[string]string) (string, error) {
+	mf := n.driver.Manifest()
+	merged := make(map[string]string)
+	for k, v := range mf.Args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	input := struct {
+		Function string            `json:"function"`
+		Args     map[string]string `json:"args"`
+		Code     string            `json:"code,omitempty"`
+		Files    map[string]string `json:"files,omitempty"`
+	}{
+		Function: n.cacheFunction(),
+		Args:     merged,
+	}
+	if n.fn != nil {
+		// The code of the inline function is an input too
+		input.Code = n.fn.GetVarValue("code")
+		if v := n.fn.GetVarValue("cache_key_files"); v != "" {
+			files, err := hashFiles(textparse.String2Slice(v))
+			if err != nil {
+				return "", fmt.Errorf("%w: cache_key_files of node '%s', %s", ErrInvalidCache, n.name, err)
+			}
+			input.Files = files
+		}
+	}
+	return cache.Key(input)
+}
+
+// hashFiles returns the sha256 of the files matched by the patterns, the files under a directory are all
+// hashed except the '.git' directory. A pattern matches nothing is hashed as empty, so the key is changed
+// after the file is created.
+func hashFiles(patterns []string) (map[string]string, error) {
+	sums := make(map[string]string)
+	hash := func(path string) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		sums[path] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	}
+	for _, p := range patterns {
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			sums[p] = ""
+			continue
+		}
+		for _, m := range matches {
+			err := filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if d.Name() == ".git" {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() {
+					return nil
+				}
+				return hash(path)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sums, nil
+}
+
+// prepareCache returns the key and the TTL of the cached return values, the key is empty if the node
+// isn't cached.
+func (n *TaskNode) prepareCache(args map[string]string) (string, time.Duration, error) {
+	ttl, err := n.CacheTTL()
+	if err != nil || ttl == 0 || n.cache == nil {
+		return "", 0, err
+	}
+	key, err := n.cacheKey(args)
+	if err != nil {
+		return "", 0, err
+	}
+	return key, ttl, nil
+}
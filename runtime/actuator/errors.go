@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	"github.com/skoowoo/cofx/functiondriver"
+	"github.com/skoowoo/cofx/manifest"
 )
 
 var (
@@ -11,11 +14,16 @@ var (
 	ErrFunctionNotLoaded          error = errors.New("function not loaded")
 	ErrLoadedFunctionDuplicated   error = errors.New("loaded function duplicated")
 	ErrConfigedFunctionDuplicated error = errors.New("configured function duplicated")
-	ErrDriverNotFound             error = errors.New("driver not found")
+	ErrDriverNotFound             error = functiondriver.ErrDriverNotFound
 	ErrNameConflict               error = errors.New("name conflict")
 	ErrConditionIsFalse           error = errors.New("condition is false")
 	ErrNodeReused                 error = errors.New("node reused")
 	ErrBuiltinDirectiveNotFound   error = errors.New("builtin directive not found")
+	ErrInvalidBackoff             error = errors.New("invalid backoff")
+	ErrInvalidCache               error = errors.New("invalid cache")
+	ErrInvalidWhen                error = errors.New("invalid when")
+	ErrInvalidArg                 error = manifest.ErrInvalidArg
+	ErrMissingArg                 error = manifest.ErrMissingArg
 )
 
 func wrapErrorf(err error, format string, args ...interface{}) error {
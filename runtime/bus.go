@@ -0,0 +1,238 @@
+package runtime
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/pkg/output"
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+type BusEventType string
+
+const (
+	BusFlowStarted   = BusEventType("FLOW_STARTED")
+	BusFlowStopped   = BusEventType("FLOW_STOPPED")
+	BusNodeReady     = BusEventType("NODE_READY")
+	BusNodeRunning   = BusEventType("NODE_RUNNING")
+	BusNodeRetrying  = BusEventType("NODE_RETRYING")
+	BusNodeStopped   = BusEventType("NODE_STOPPED")
+	BusTriggerFired  = BusEventType("TRIGGER_FIRED")
+	BusTriggerFailed = BusEventType("TRIGGER_FAILED")
+	BusLogLine       = BusEventType("LOG_LINE")
+	BusEventDropped  = BusEventType("EVENT_DROPPED")
+	BusFlowWarning   = BusEventType("FLOW_WARNING")
+)
+
+// defaultSubscriptionCapacity is the max number of events that are buffered for a subscriber.
+const defaultSubscriptionCapacity = 4096
+
+// BusEvent is a state change of a flow or a node, it's pushed to subscribers by the runtime.
+type BusEvent struct {
+	Type     BusEventType
+	FlowID   string
+	FlowName string
+	// Serial is increased one by one in a flow, the events of a flow are delivered in the order of it.
+	Serial uint64
+	Time   time.Time
+	// RunID identifies the running of the flow, it's only set for the flow started/stopped events.
+	RunID string
+	// Seq and Node are the sequence and name of the node, they are only set for node/trigger/log events.
+	Seq  int
+	Node string
+	// Runs is the number of runs of the node, it's only set for the node events.
+	Runs int
+	// Err is the error of the flow or the node, it's only set for the stopped events, the failed trigger
+	// events, the reason for the dropped event, and the warning of the flow.
+	Err error
+	// Line is a log line without the suffix '\n', it's only set for the log event.
+	Line string
+}
+
+// eventbus dispatches the events to all subscribers, it never blocks the publisher.
+type eventbus struct {
+	sync.Mutex
+	serials map[string]uint64
+	subs    map[*Subscription]struct{}
+}
+
+func newEventbus() *eventbus {
+	return &eventbus{
+		serials: make(map[string]uint64),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *eventbus) publish(id nameid.ID, ev BusEvent) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.serials[id.ID()]++
+	ev.FlowID = id.ID()
+	ev.FlowName = id.Name()
+	ev.Serial = b.serials[id.ID()]
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	// Enqueue under the bus lock, so every subscriber sees the same order of events.
+	for sub := range b.subs {
+		sub.enqueue(ev)
+	}
+}
+
+func (b *eventbus) subscribe(capacity int, ids ...nameid.ID) *Subscription {
+	if capacity <= 0 {
+		capacity = defaultSubscriptionCapacity
+	}
+	sub := &Subscription{
+		bus:      b,
+		capacity: capacity,
+		c:        make(chan BusEvent),
+		done:     make(chan struct{}),
+	}
+	if len(ids) > 0 {
+		sub.flows = make(map[string]struct{})
+		for _, id := range ids {
+			sub.flows[id.ID()] = struct{}{}
+		}
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+	go sub.pump()
+
+	b.Lock()
+	b.subs[sub] = struct{}{}
+	b.Unlock()
+	return sub
+}
+
+func (b *eventbus) unsubscribe(sub *Subscription) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.subs, sub)
+}
+
+// Subscription receives the events from the runtime, every subscription has its own queue, so a slow
+// subscriber can't block the runtime and the other subscribers. When the queue is full, the oldest
+// events are dropped.
+type Subscription struct {
+	bus      *eventbus
+	flows    map[string]struct{}
+	capacity int
+	c        chan BusEvent
+	done     chan struct{}
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []BusEvent
+	dropped int
+	closed  bool
+}
+
+// C returns the channel to receive the events, it's closed after the subscription is closed.
+func (s *Subscription) C() <-chan BusEvent {
+	return s.c
+}
+
+// Dropped returns the number of events dropped because the subscriber is too slow.
+func (s *Subscription) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close stops receiving the events, the events in the queue are discarded.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.queue = nil
+	close(s.done)
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) enqueue(ev BusEvent) {
+	if s.flows != nil {
+		if _, ok := s.flows[ev.FlowID]; !ok {
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if len(s.queue) >= s.capacity {
+		s.queue = s.queue[1:]
+		s.dropped++
+	}
+	s.queue = append(s.queue, ev)
+	s.cond.Signal()
+}
+
+// pump moves the events from the queue to the channel one by one.
+func (s *Subscription) pump() {
+	defer close(s.c)
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		ev := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.c <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// logTap publishes every line written into the log writer of a node as a log event.
+type logTap struct {
+	sync.Mutex
+	out *output.Output
+}
+
+func newLogTap(w io.Writer, publish func(line string)) io.Writer {
+	tap := &logTap{
+		out: &output.Output{
+			W: w,
+			HandleFunc: func(line []byte) {
+				publish(strings.TrimSuffix(string(line), "\n"))
+			},
+		},
+	}
+	// Keep the pretty printer of the writer available to the drivers.
+	if p, ok := w.(resource.OutPrettyPrinter); ok {
+		return &prettyLogTap{
+			logTap:           tap,
+			OutPrettyPrinter: p,
+		}
+	}
+	return tap
+}
+
+func (t *logTap) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+	return t.out.Write(p)
+}
+
+type prettyLogTap struct {
+	*logTap
+	resource.OutPrettyPrinter
+}
@@ -0,0 +1,144 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/stretchr/testify/assert"
+)
+
+const busTestingdata string = `
+load "go:print"
+
+co print {
+	"_": "hello"
+}
+co print {
+	"_": "world"
+}
+`
+
+func readyBusTestingFlow(t *testing.T, rt *Runtime, id nameid.ID) {
+	ctx := context.Background()
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(busTestingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var out bytes.Buffer
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return &out, nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func collectBusEvents(sub *Subscription, until BusEventType) []BusEvent {
+	var events []BusEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-sub.C():
+			events = append(events, ev)
+			if ev.Type == until {
+				return events
+			}
+		case <-timeout:
+			return events
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	other := nameid.New("other.flowl")
+
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+	all := rt.Subscribe(0)
+	defer all.Close()
+
+	readyBusTestingFlow(t, rt, id)
+	readyBusTestingFlow(t, rt, other)
+	assert.NoError(t, rt.ExecFlow(context.Background(), id))
+
+	events := collectBusEvents(sub, BusFlowStopped)
+	var types []BusEventType
+	for i, ev := range events {
+		assert.Equal(t, id.ID(), ev.FlowID)
+		assert.Equal(t, uint64(i+1), ev.Serial)
+		types = append(types, ev.Type)
+	}
+	assert.Equal(t, []BusEventType{
+		BusNodeReady, BusNodeReady,
+		BusFlowStarted,
+		BusNodeRunning, BusLogLine, BusNodeStopped,
+		BusNodeRunning, BusLogLine, BusNodeStopped,
+		BusFlowStopped,
+	}, types)
+	assert.Equal(t, "hello", events[4].Line)
+	assert.Equal(t, 1000, events[4].Seq)
+	assert.Equal(t, 1, events[5].Runs)
+	assert.NoError(t, events[5].Err)
+	assert.Equal(t, "world", events[7].Line)
+
+	// The subscriber without flow ids receives the events of all flows.
+	events = collectBusEvents(all, BusFlowStopped)
+	var flows = make(map[string]int)
+	for _, ev := range events {
+		flows[ev.FlowID]++
+	}
+	assert.Equal(t, 2, flows[other.ID()])
+	assert.Equal(t, 10, flows[id.ID()])
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+
+	// The slow subscriber never reads the events until the flow finished.
+	slow := rt.Subscribe(0, id)
+	defer slow.Close()
+	tiny := rt.Subscribe(3, id)
+	defer tiny.Close()
+	fast := rt.Subscribe(0, id)
+	defer fast.Close()
+
+	readyBusTestingFlow(t, rt, id)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.ExecFlow(context.Background(), id)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "flow is blocked by the slow subscriber")
+	}
+
+	fastEvents := collectBusEvents(fast, BusFlowStopped)
+	slowEvents := collectBusEvents(slow, BusFlowStopped)
+	assert.Len(t, fastEvents, 10)
+	assert.Equal(t, fastEvents, slowEvents)
+	assert.Equal(t, 0, slow.Dropped())
+
+	// The subscriber with a small capacity drops the oldest events, but keeps the order.
+	time.Sleep(100 * time.Millisecond)
+	tinyEvents := collectBusEvents(tiny, BusFlowStopped)
+	assert.Greater(t, tiny.Dropped(), 0)
+	assert.Equal(t, BusFlowStopped, tinyEvents[len(tinyEvents)-1].Type)
+	for i := 1; i < len(tinyEvents); i++ {
+		assert.Less(t, tinyEvents[i-1].Serial, tinyEvents[i].Serial)
+	}
+
+	// After closed, the channel of the subscription is closed.
+	slow.Close()
+	_, ok := <-slow.C()
+	assert.False(t, ok)
+}
@@ -0,0 +1,44 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/skoowoo/cofx/functiondriver"
+	"github.com/skoowoo/cofx/runtime/actuator"
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+// functionCaller calls the functions loaded by the flow on behalf of a node, it's the resource
+// 'Functions'. Every call loads a new driver instance of the function and releases it after the call,
+// the called function shares the resources with the node, so its logs are written into the log of
+// the node.
+type functionCaller struct {
+	runq      *actuator.RunQueue
+	resources resource.Resources
+}
+
+func newFunctionCaller(runq *actuator.RunQueue, resources resource.Resources) *functionCaller {
+	c := &functionCaller{
+		runq: runq,
+	}
+	resources.Functions = c
+	c.resources = resources
+	return c
+}
+
+func (c *functionCaller) Call(ctx context.Context, fname string, args map[string]string) (map[string]string, error) {
+	location, ok := c.runq.Location(fname)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", actuator.ErrFunctionNotLoaded, fname)
+	}
+	driver, err := functiondriver.New(location)
+	if err != nil {
+		return nil, err
+	}
+	if err := driver.Load(ctx, c.resources); err != nil {
+		return nil, err
+	}
+	defer driver.StopAndRelease(ctx)
+	return driver.Run(ctx, args)
+}
@@ -0,0 +1,433 @@
+package runtime
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/skoowoo/cofx/runtime/actuator"
+)
+
+var (
+	ErrDebuggerNotPaused  = errors.New("debugger not paused")
+	ErrDebuggerDetached   = errors.New("debugger detached")
+	ErrInvalidBreakpoint  = errors.New("invalid breakpoint")
+	ErrUnknownDebugCmd    = errors.New("unknown debug command")
+	ErrInvalidDebugCmdArg = errors.New("invalid debug command argument")
+)
+
+// Breakpoint describes where the debugger pauses the flow, the flow will be paused before
+// the node whose seq, name or source line matches the breakpoint.
+type Breakpoint struct {
+	Kind  string
+	Value string
+}
+
+// ParseBreakpoint parses the string like 'seq:1001', 'name:print' or 'line:12' into a breakpoint,
+// a bare number is treated as a seq and a bare word is treated as a node name.
+func ParseBreakpoint(s string) (Breakpoint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Breakpoint{}, fmt.Errorf("%w: empty", ErrInvalidBreakpoint)
+	}
+	kind, value, found := strings.Cut(s, ":")
+	if !found {
+		value = kind
+		kind = "name"
+		if _, err := strconv.Atoi(value); err == nil {
+			kind = "seq"
+		}
+	}
+	switch kind {
+	case "seq", "line":
+		if _, err := strconv.Atoi(value); err != nil {
+			return Breakpoint{}, fmt.Errorf("%w: '%s'", ErrInvalidBreakpoint, s)
+		}
+	case "name":
+		if value == "" {
+			return Breakpoint{}, fmt.Errorf("%w: '%s'", ErrInvalidBreakpoint, s)
+		}
+	default:
+		return Breakpoint{}, fmt.Errorf("%w: '%s'", ErrInvalidBreakpoint, s)
+	}
+	return Breakpoint{Kind: kind, Value: value}, nil
+}
+
+func (b Breakpoint) String() string {
+	return b.Kind + ":" + b.Value
+}
+
+func (b Breakpoint) match(node actuator.Node) bool {
+	task, ok := node.(actuator.Task)
+	if !ok {
+		return false
+	}
+	switch b.Kind {
+	case "seq":
+		return b.Value == strconv.Itoa(task.Seq())
+	case "name":
+		return b.Value == node.Name()
+	case "line":
+		return task.Block() != nil && b.Value == strconv.Itoa(task.Block().Line())
+	}
+	return false
+}
+
+type DebugAction int
+
+const (
+	// DebugContinue executes the paused node and runs until the next breakpoint.
+	DebugContinue DebugAction = iota
+	// DebugStep executes the paused node and pauses before the next node.
+	DebugStep
+	// DebugSkip doesn't execute the paused node and pauses before the next node.
+	DebugSkip
+)
+
+// DebugFrame is the context of a paused node, it's only valid while the node is paused.
+type DebugFrame struct {
+	flow   *Flow
+	node   actuator.Node
+	resume chan DebugAction
+}
+
+// Node returns the paused node.
+func (f *DebugFrame) Node() actuator.Node {
+	return f.node
+}
+
+// Where returns a short description of the paused node.
+func (f *DebugFrame) Where() string {
+	task := f.node.(actuator.Task)
+	return fmt.Sprintf("seq %d, step %d, line %d: %s", task.Seq(), task.Step(), task.Block().Line(), f.node.FormatString())
+}
+
+// Vars returns all variables that can be seen by the paused node.
+func (f *DebugFrame) Vars() map[string]string {
+	return f.node.(actuator.Task).Block().Vars()
+}
+
+// SetVar overwrites the value of a variable that can be seen by the paused node.
+func (f *DebugFrame) SetVar(name, value string) error {
+	return f.node.(actuator.Task).Block().SetVarValue(name, value)
+}
+
+// Eval evaluates an expression in the scope of the paused node, e.g. '$(a) + 1'.
+func (f *DebugFrame) Eval(expr string) (string, error) {
+	return f.node.(actuator.Task).Block().Evaluate(expr)
+}
+
+// Returns returns the last return values of all nodes that have been executed, the key is the
+// node name.
+func (f *DebugFrame) Returns() map[string]map[string]string {
+	returns := make(map[string]map[string]string)
+	f.flow.RunQ().WalkNode(func(n actuator.Node) error {
+		if rets := n.(actuator.Task).LastReturns(); rets != nil {
+			returns[n.Name()] = rets
+		}
+		return nil
+	})
+	return returns
+}
+
+// Debugger pauses a flow before the nodes that hit breakpoints, the paused flow can be inspected
+// and resumed through the debugger. A debugger is attached to a flow with the option 'WithDebugger',
+// and it's detached when the flow stopped.
+type Debugger struct {
+	sync.Mutex
+	breakpoints []Breakpoint
+	// stepping means the debugger pauses before the next node whatever the breakpoints are.
+	stepping bool
+	current  *DebugFrame
+	notify   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewDebugger creates a debugger, if 'stopOnEntry' is true, the flow will be paused before the first node.
+func NewDebugger(stopOnEntry bool, bps ...Breakpoint) *Debugger {
+	return &Debugger{
+		breakpoints: bps,
+		stepping:    stopOnEntry,
+		notify:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+// AddBreakpoint adds a breakpoint into the debugger.
+func (d *Debugger) AddBreakpoint(bp Breakpoint) {
+	d.Lock()
+	defer d.Unlock()
+	for _, b := range d.breakpoints {
+		if b == bp {
+			return
+		}
+	}
+	d.breakpoints = append(d.breakpoints, bp)
+}
+
+// RemoveBreakpoint removes a breakpoint from the debugger, returns false if not found it.
+func (d *Debugger) RemoveBreakpoint(bp Breakpoint) bool {
+	d.Lock()
+	defer d.Unlock()
+	for i, b := range d.breakpoints {
+		if b == bp {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns all breakpoints of the debugger.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.Lock()
+	defer d.Unlock()
+	return append([]Breakpoint{}, d.breakpoints...)
+}
+
+// Paused returns the current paused frame, returns nil if the flow isn't paused.
+func (d *Debugger) Paused() *DebugFrame {
+	d.Lock()
+	defer d.Unlock()
+	return d.current
+}
+
+// Done returns a channel that's closed when the debugger is detached from the flow.
+func (d *Debugger) Done() <-chan struct{} {
+	return d.done
+}
+
+// Wait blocks until the flow is paused, it returns an error if the debugger is detached
+// or the context is done.
+func (d *Debugger) Wait(ctx context.Context) (*DebugFrame, error) {
+	for {
+		if frame := d.Paused(); frame != nil {
+			return frame, nil
+		}
+		select {
+		case <-d.notify:
+		case <-d.done:
+			return nil, ErrDebuggerDetached
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Resume makes the paused flow continue with the action.
+func (d *Debugger) Resume(action DebugAction) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.current == nil {
+		return ErrDebuggerNotPaused
+	}
+	d.current.resume <- action
+	d.current = nil
+	d.stepping = action != DebugContinue
+	return nil
+}
+
+// Release clears all breakpoints and resumes the paused flow, then the flow runs to the end.
+func (d *Debugger) Release() {
+	d.Lock()
+	d.breakpoints = nil
+	d.stepping = false
+	d.Unlock()
+	d.Resume(DebugContinue)
+}
+
+// Detach detaches the debugger from the flow, all waiters will be woken up.
+func (d *Debugger) Detach() {
+	d.once.Do(func() {
+		close(d.done)
+	})
+}
+
+// pause is invoked by the runtime before executing a node, it blocks until the frontend resumes
+// the flow if the node hits a breakpoint.
+func (d *Debugger) pause(ctx context.Context, f *Flow, node actuator.Node) DebugAction {
+	d.Lock()
+	hit := d.stepping
+	for _, bp := range d.breakpoints {
+		if bp.match(node) {
+			hit = true
+		}
+	}
+	if !hit {
+		d.Unlock()
+		return DebugContinue
+	}
+	frame := &DebugFrame{
+		flow:   f,
+		node:   node,
+		resume: make(chan DebugAction, 1),
+	}
+	d.current = frame
+	d.Unlock()
+
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+
+	select {
+	case action := <-frame.resume:
+		return action
+	case <-ctx.Done():
+		d.Lock()
+		d.current = nil
+		d.Unlock()
+		return DebugContinue
+	}
+}
+
+// Command executes one debug command and returns its output, the following commands are supported:
+//
+//	break <bp>       add a breakpoint, e.g. 'break seq:1001', 'break name:print', 'break line:12'
+//	delete <bp>      remove a breakpoint
+//	breakpoints      list all breakpoints
+//	where            show the paused node
+//	vars             list all variables that can be seen by the paused node
+//	set <var> <val>  overwrite the value of a variable
+//	eval <expr>      evaluate an expression, e.g. 'eval $(a) + 1'
+//	returns          list the last return values of the executed nodes
+//	step             execute the paused node and pause before the next node
+//	skip             skip the paused node and pause before the next node
+//	continue         execute the paused node and run until the next breakpoint
+func (d *Debugger) Command(line string) (string, error) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch cmd {
+	case "break", "b":
+		bp, err := ParseBreakpoint(arg)
+		if err != nil {
+			return "", err
+		}
+		d.AddBreakpoint(bp)
+		return "breakpoint " + bp.String(), nil
+	case "delete", "d":
+		bp, err := ParseBreakpoint(arg)
+		if err != nil {
+			return "", err
+		}
+		if !d.RemoveBreakpoint(bp) {
+			return "", fmt.Errorf("%w: not found '%s'", ErrInvalidBreakpoint, bp)
+		}
+		return "deleted " + bp.String(), nil
+	case "breakpoints", "bl":
+		var lines []string
+		for _, bp := range d.Breakpoints() {
+			lines = append(lines, bp.String())
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	frame := d.Paused()
+	if frame == nil {
+		if _, ok := frameCommands[cmd]; ok {
+			return "", ErrDebuggerNotPaused
+		}
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownDebugCmd, cmd)
+	}
+
+	switch cmd {
+	case "continue", "c":
+		return "", d.Resume(DebugContinue)
+	case "step", "s", "next", "n":
+		return "", d.Resume(DebugStep)
+	case "skip":
+		return "", d.Resume(DebugSkip)
+	case "where", "w":
+		return frame.Where(), nil
+	case "vars", "v":
+		return formatKvs(frame.Vars(), " = "), nil
+	case "set":
+		name, value, found := strings.Cut(arg, " ")
+		if !found || name == "" {
+			return "", fmt.Errorf("%w: set <var> <value>", ErrInvalidDebugCmdArg)
+		}
+		if err := frame.SetVar(name, strings.TrimSpace(value)); err != nil {
+			return "", err
+		}
+		return name + " = " + strings.TrimSpace(value), nil
+	case "eval", "p":
+		if arg == "" {
+			return "", fmt.Errorf("%w: eval <expr>", ErrInvalidDebugCmdArg)
+		}
+		return frame.Eval(arg)
+	case "returns", "r":
+		var lines []string
+		for name, rets := range frame.Returns() {
+			for k, v := range rets {
+				lines = append(lines, name+"."+k+" = "+v)
+			}
+		}
+		sort.Strings(lines)
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", fmt.Errorf("%w: '%s'", ErrUnknownDebugCmd, cmd)
+}
+
+// frameCommands are the commands that can only be executed when the flow is paused.
+var frameCommands = map[string]struct{}{
+	"continue": {}, "c": {},
+	"step": {}, "s": {}, "next": {}, "n": {},
+	"skip":  {},
+	"where": {}, "w": {},
+	"vars": {}, "v": {},
+	"set":  {},
+	"eval": {}, "p": {},
+	"returns": {}, "r": {},
+}
+
+// Serve reads debug commands line by line from 'rd' and writes the output into 'w', it's the
+// headless frontend of the debugger. The commands that need a paused flow will wait for the flow
+// to be paused. Empty lines and lines started with '#' are ignored. When 'rd' reaches the end,
+// the debugger is released and the flow runs to the end.
+func (d *Debugger) Serve(ctx context.Context, rd io.Reader, w io.Writer) error {
+	defer d.Release()
+
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cmd, _, _ := strings.Cut(line, " ")
+		if _, ok := frameCommands[cmd]; ok {
+			if _, err := d.Wait(ctx); err != nil {
+				if errors.Is(err, ErrDebuggerDetached) {
+					return nil
+				}
+				return err
+			}
+		}
+		fmt.Fprintf(w, "(debug) %s\n", line)
+		out, err := d.Command(line)
+		if err != nil {
+			fmt.Fprintf(w, "error: %s\n", err)
+			continue
+		}
+		if out != "" {
+			fmt.Fprintln(w, out)
+		}
+	}
+	return scanner.Err()
+}
+
+func formatKvs(kvs map[string]string, sep string) string {
+	var lines []string
+	for k, v := range kvs {
+		lines = append(lines, k+sep+v)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
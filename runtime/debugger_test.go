@@ -0,0 +1,165 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBreakpoint(t *testing.T) {
+	cases := []struct {
+		s      string
+		expect Breakpoint
+		err    bool
+	}{
+		{"seq:1001", Breakpoint{"seq", "1001"}, false},
+		{"1001", Breakpoint{"seq", "1001"}, false},
+		{"name:print", Breakpoint{"name", "print"}, false},
+		{"print", Breakpoint{"name", "print"}, false},
+		{"line:12", Breakpoint{"line", "12"}, false},
+		{"line:x", Breakpoint{}, true},
+		{"foo:bar", Breakpoint{}, true},
+		{"", Breakpoint{}, true},
+	}
+	for _, c := range cases {
+		bp, err := ParseBreakpoint(c.s)
+		if c.err {
+			assert.Error(t, err, c.s)
+			continue
+		}
+		assert.NoError(t, err, c.s)
+		assert.Equal(t, c.expect, bp)
+	}
+}
+
+func TestDebuggerServe(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+var s = "hello"
+var out
+
+co print -> out {
+	"_": "$(s)"
+}
+co print {
+	"_": "$(s) again"
+}
+	`
+	const script string = `
+# paused before the first node
+where
+vars
+set s world
+eval 1 + 2
+step
+where
+returns
+skip
+`
+	var (
+		out    bytes.Buffer
+		result bytes.Buffer
+	)
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	debugger := NewDebugger(true)
+	err := rt.InitFlow(ctx, id,
+		WithCreateLogwriter(func(string) (io.Writer, error) { return &out, nil }),
+		WithCopyResources(func() resource.Resources { return resource.Resources{} }),
+		WithDebugger(debugger),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	wait := make(chan error, 1)
+	go func() {
+		wait <- rt.ExecFlow(ctx, id)
+	}()
+	err = debugger.Serve(ctx, strings.NewReader(script), &result)
+	assert.NoError(t, err)
+	assert.NoError(t, <-wait)
+
+	// the second node is skipped, and the first node printed the new value of 's'
+	assert.Equal(t, "world", strings.TrimSpace(out.String()))
+
+	s := result.String()
+	assert.Contains(t, s, "seq 1000, step 1, line 7")
+	assert.Contains(t, s, "s = hello")
+	assert.Contains(t, s, "s = world")
+	assert.Contains(t, s, "(debug) eval 1 + 2\n3\n")
+	assert.Contains(t, s, "seq 1001, step 2, line 10")
+	assert.NotContains(t, s, "error:")
+
+	err = rt.FetchFlow(ctx, id, func(b *FlowBody) error {
+		assert.Equal(t, 1, b.statistics[1000].runs)
+		assert.Equal(t, 0, b.statistics[1001].runs)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestDebuggerBreakpoint(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+co print {
+	"_": "first"
+}
+co print {
+	"_": "second"
+}
+	`
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	var out bytes.Buffer
+	debugger := NewDebugger(false, Breakpoint{"seq", "1001"})
+	err := rt.InitFlow(ctx, id,
+		WithCreateLogwriter(func(string) (io.Writer, error) { return &out, nil }),
+		WithDebugger(debugger),
+	)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	wait := make(chan error, 1)
+	go func() {
+		wait <- rt.ExecFlow(ctx, id)
+	}()
+
+	frame, err := debugger.Wait(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "print", frame.Node().Name())
+	assert.Equal(t, "first", strings.TrimSpace(out.String()))
+
+	_, err = debugger.Command("vars")
+	assert.NoError(t, err)
+	_, err = debugger.Command("continue")
+	assert.NoError(t, err)
+	_, err = debugger.Command("vars")
+	assert.ErrorIs(t, err, ErrDebuggerNotPaused)
+
+	assert.NoError(t, <-wait)
+	_, err = debugger.Wait(ctx)
+	assert.ErrorIs(t, err, ErrDebuggerDetached)
+	assert.Equal(t, "first\nsecond", strings.TrimSpace(out.String()))
+}
@@ -0,0 +1,341 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/skoowoo/cofx/runtime/actuator"
+)
+
+// The policies of handling the events that arrive when the flow is running, the policy is set by the
+// 'policy' option in the 'event' block, e.g.:
+//
+//	event {
+//	    var policy = "skip"
+//	    co event_cron { "expr": "*/5 * * * * *" }
+//	}
+const (
+	// PolicyQueue queues the events until the running flow finished, at most 'max_queue' events.
+	PolicyQueue = "queue"
+	// PolicySkip drops the events.
+	PolicySkip = "skip"
+	// PolicyReplace cancels the running flow and runs it again with the new event.
+	PolicyReplace = "replace"
+	// PolicyParallel runs at most 'max_parallel' instances of the flow at the same time, the events beyond
+	// it are queued like PolicyQueue.
+	PolicyParallel = "parallel"
+)
+
+const (
+	defaultMaxQueue    = 64
+	defaultMaxParallel = 4
+)
+
+var (
+	ErrInvalidEventOption = errors.New("invalid event option")
+	ErrEventDropped       = errors.New("event dropped")
+	ErrTriggersNotStarted = errors.New("triggers not started")
+)
+
+// ManualTrigger is the name of the trigger of the events sent by Runtime.TriggerFlow.
+const ManualTrigger = "manual"
+
+// triggerPolicy is parsed from the options of the 'event' block.
+type triggerPolicy struct {
+	policy      string
+	maxQueue    int
+	maxParallel int
+	// debounce delays the event until no new event arrives in the window, the events in the window are
+	// merged into the last one.
+	debounce time.Duration
+	// coalesce delays the event until the window started from the first event ends, the events in the
+	// window are merged into one.
+	coalesce time.Duration
+}
+
+func parseTriggerPolicy(rq *actuator.RunQueue) (triggerPolicy, error) {
+	p := triggerPolicy{
+		policy:      PolicyQueue,
+		maxQueue:    defaultMaxQueue,
+		maxParallel: 1,
+	}
+	if v := rq.EventOption("policy"); v != "" {
+		switch v {
+		case PolicyQueue, PolicySkip, PolicyReplace:
+		case PolicyParallel:
+			p.maxParallel = defaultMaxParallel
+		default:
+			return p, fmt.Errorf("%w: policy '%s'", ErrInvalidEventOption, v)
+		}
+		p.policy = v
+	}
+
+	number := func(name string, min int, n *int) error {
+		v := rq.EventOption(name)
+		if v == "" {
+			return nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil || i < min {
+			return fmt.Errorf("%w: %s '%s'", ErrInvalidEventOption, name, v)
+		}
+		*n = i
+		return nil
+	}
+	if err := number("max_queue", 0, &p.maxQueue); err != nil {
+		return p, err
+	}
+	if err := number("max_parallel", 1, &p.maxParallel); err != nil {
+		return p, err
+	}
+	if p.policy != PolicyParallel {
+		p.maxParallel = 1
+	}
+
+	duration := func(name string, d *time.Duration) error {
+		v := rq.EventOption(name)
+		if v == "" {
+			return nil
+		}
+		i, err := time.ParseDuration(v)
+		if err != nil || i < 0 {
+			return fmt.Errorf("%w: %s '%s'", ErrInvalidEventOption, name, v)
+		}
+		*d = i
+		return nil
+	}
+	if err := duration("debounce", &p.debounce); err != nil {
+		return p, err
+	}
+	if err := duration("coalesce", &p.coalesce); err != nil {
+		return p, err
+	}
+	if p.debounce > 0 && p.coalesce > 0 {
+		return p, fmt.Errorf("%w: debounce and coalesce can't be used together", ErrInvalidEventOption)
+	}
+	for _, tg := range rq.GetTriggers() {
+		if _, _, err := tg.(actuator.Task).Backoff(); err != nil {
+			return p, fmt.Errorf("%w: %s", ErrInvalidEventOption, err.Error())
+		}
+	}
+	return p, nil
+}
+
+// triggerEvent is sent to the dispatcher when a trigger fires.
+type triggerEvent struct {
+	seq  int
+	name string
+	// returns are the return values of the trigger.
+	returns map[string]string
+}
+
+// flowRun is a running instance of the flow started by the dispatcher.
+type flowRun struct {
+	replica bool
+	cancel  context.CancelFunc
+}
+
+// dispatcher receives the events from all triggers of a flow, and runs the flow based on the policy.
+type dispatcher struct {
+	rt     *Runtime
+	flow   *Flow
+	policy triggerPolicy
+	events chan triggerEvent
+
+	running map[*flowRun]struct{}
+	pending []triggerEvent
+	done    chan *flowRun
+	// nrunning and npending mirror the length of 'running' and 'pending' for the readers out of the main loop.
+	nrunning int32
+	npending int32
+	// stopped is closed after the main loop returned.
+	stopped chan struct{}
+}
+
+func newDispatcher(rt *Runtime, flow *Flow) *dispatcher {
+	var policy triggerPolicy
+	flow.WithLock(func(fb *FlowBody) error {
+		policy = fb.policy
+		return nil
+	})
+	return &dispatcher{
+		rt:      rt,
+		flow:    flow,
+		policy:  policy,
+		events:  make(chan triggerEvent),
+		running: make(map[*flowRun]struct{}),
+		done:    make(chan *flowRun),
+		stopped: make(chan struct{}),
+	}
+}
+
+// run is the main loop of the dispatcher, it returns after the 'ctx' is done and all runs finished.
+func (d *dispatcher) run(ctx context.Context) {
+	defer close(d.stopped)
+	var (
+		held   *triggerEvent
+		timer  *time.Timer
+		window <-chan time.Time
+	)
+	for {
+		atomic.StoreInt32(&d.nrunning, int32(len(d.running)))
+		atomic.StoreInt32(&d.npending, int32(len(d.pending)))
+		select {
+		case ev := <-d.events:
+			if d.policy.debounce == 0 && d.policy.coalesce == 0 {
+				d.accept(ctx, ev)
+				continue
+			}
+			if held != nil {
+				d.drop(*held, "merged into the later event")
+			}
+			held = &ev
+			if d.policy.debounce > 0 {
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.NewTimer(d.policy.debounce)
+				window = timer.C
+			} else if window == nil {
+				timer = time.NewTimer(d.policy.coalesce)
+				window = timer.C
+			}
+		case <-window:
+			window = nil
+			d.accept(ctx, *held)
+			held = nil
+		case r := <-d.done:
+			delete(d.running, r)
+			if len(d.pending) > 0 && ctx.Err() == nil {
+				ev := d.pending[0]
+				d.pending = d.pending[1:]
+				d.start(ctx, ev)
+			}
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			// The runs are canceled by the 'ctx', wait for them to finish.
+			for len(d.running) > 0 {
+				delete(d.running, <-d.done)
+			}
+			return
+		}
+	}
+}
+
+// counts returns the number of the running instances of the flow and the queued events.
+func (d *dispatcher) counts() (int, int) {
+	return int(atomic.LoadInt32(&d.nrunning)), int(atomic.LoadInt32(&d.npending))
+}
+
+// accept starts the flow with the event, or queues/drops it if the flow is running.
+func (d *dispatcher) accept(ctx context.Context, ev triggerEvent) {
+	if len(d.running) < d.policy.maxParallel {
+		d.start(ctx, ev)
+		return
+	}
+	switch d.policy.policy {
+	case PolicySkip:
+		d.drop(ev, "flow is running")
+	case PolicyReplace:
+		for r := range d.running {
+			r.cancel()
+		}
+		for _, p := range d.pending {
+			d.drop(p, "replaced by the later event")
+		}
+		d.pending = []triggerEvent{ev}
+	default:
+		if len(d.pending) >= d.policy.maxQueue {
+			d.drop(ev, "queue is full")
+			return
+		}
+		d.pending = append(d.pending, ev)
+	}
+}
+
+// start runs the flow in a goroutine, the flow itself is used if it's not running, otherwise a replica
+// of the flow is used.
+func (d *dispatcher) start(ctx context.Context, ev triggerEvent) {
+	r := &flowRun{replica: false}
+	for p := range d.running {
+		if !p.replica {
+			r.replica = true
+			break
+		}
+	}
+	runctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	d.running[r] = struct{}{}
+
+	go func() {
+		defer func() {
+			cancel()
+			d.done <- r
+		}()
+		if r.replica {
+			d.execReplica(runctx, ev)
+		} else {
+			d.exec(runctx, ev)
+		}
+	}()
+}
+
+// exec runs the flow itself, the returns of the event are bound to the variables of the triggers first.
+// The error of the running is published by the stopped event of the flow, and the error before running
+// is published by the dropped event.
+func (d *dispatcher) exec(ctx context.Context, ev triggerEvent) {
+	if err := d.rt.MustReady(ctx, d.flow.id); err != nil {
+		d.fail(ev, err)
+		return
+	}
+	if err := d.flow.RunQ().BindEvent(ev.seq, ev.returns); err != nil {
+		d.fail(ev, err)
+		return
+	}
+	d.rt.execFlow(ctx, d.flow)
+}
+
+// execReplica runs a replica of the flow, the returns of the event are bound to the variables of the
+// triggers in the replica.
+func (d *dispatcher) execReplica(ctx context.Context, ev triggerEvent) {
+	replica, err := d.rt.replicate(ctx, d.flow)
+	if err != nil {
+		d.fail(ev, err)
+		return
+	}
+	defer replica.release(context.Background())
+	if err := replica.RunQ().BindEvent(ev.seq, ev.returns); err != nil {
+		d.fail(ev, err)
+		return
+	}
+	d.rt.execFlow(ctx, replica)
+}
+
+// fail records the event that can't make the flow run because of the error, e.g. the replica of the flow
+// can't be created.
+func (d *dispatcher) fail(ev triggerEvent, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	d.drop(ev, "flow can't run: "+err.Error())
+}
+
+// drop records the event that doesn't make the flow run.
+func (d *dispatcher) drop(ev triggerEvent, reason string) {
+	d.flow.WithLock(func(fb *FlowBody) error {
+		fb.dropped++
+		return nil
+	})
+	d.rt.bus.publish(d.flow.id, BusEvent{
+		Type: BusEventDropped,
+		Seq:  ev.seq,
+		Node: ev.name,
+		Err:  fmt.Errorf("%w: %s", ErrEventDropped, reason),
+	})
+}
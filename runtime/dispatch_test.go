@@ -0,0 +1,644 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/service/exported"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/crontrigger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTriggerPolicy(t *testing.T) {
+	cases := []struct {
+		options string
+		expect  triggerPolicy
+		err     bool
+	}{
+		{``, triggerPolicy{policy: PolicyQueue, maxQueue: defaultMaxQueue, maxParallel: 1}, false},
+		{`var policy = "skip"`, triggerPolicy{policy: PolicySkip, maxQueue: defaultMaxQueue, maxParallel: 1}, false},
+		{`var policy = "parallel"`, triggerPolicy{policy: PolicyParallel, maxQueue: defaultMaxQueue, maxParallel: defaultMaxParallel}, false},
+		{"var policy = \"parallel\"\nvar max_parallel = 2\nvar max_queue = 0", triggerPolicy{policy: PolicyParallel, maxQueue: 0, maxParallel: 2}, false},
+		{"var policy = \"queue\"\nvar max_parallel = 2", triggerPolicy{policy: PolicyQueue, maxQueue: defaultMaxQueue, maxParallel: 1}, false},
+		{`var debounce = "1s"`, triggerPolicy{policy: PolicyQueue, maxQueue: defaultMaxQueue, maxParallel: 1, debounce: time.Second}, false},
+		{`var coalesce = "2s"`, triggerPolicy{policy: PolicyQueue, maxQueue: defaultMaxQueue, maxParallel: 1, coalesce: 2 * time.Second}, false},
+		{`var policy = "foo"`, triggerPolicy{}, true},
+		{`var max_queue = -1`, triggerPolicy{}, true},
+		{`var max_parallel = 0`, triggerPolicy{}, true},
+		{`var debounce = "x"`, triggerPolicy{}, true},
+		{"var debounce = \"1s\"\nvar coalesce = \"1s\"", triggerPolicy{}, true},
+		{`var backoff = "x"`, triggerPolicy{}, true},
+		{`var max_backoff = "-1s"`, triggerPolicy{}, true},
+	}
+	for _, c := range cases {
+		testingdata := fmt.Sprintf(`
+load "go:event_tick"
+load "go:print"
+
+event {
+	%s
+	co event_tick {
+		"duration": "1s"
+	}
+}
+co print
+`, c.options)
+		rt := New()
+		id := nameid.New("testingdata.flowl")
+		err := rt.ParseFlow(context.Background(), id, strings.NewReader(testingdata))
+		if c.err {
+			assert.ErrorIs(t, err, ErrInvalidEventOption, c.options)
+			continue
+		}
+		if !assert.NoError(t, err, c.options) {
+			continue
+		}
+		rt.FetchFlow(context.Background(), id, func(fb *FlowBody) error {
+			assert.Equal(t, c.expect, fb.policy, c.options)
+			return nil
+		})
+	}
+}
+
+// runTriggerPolicy runs the flow with a fast ticker until the received events satisfy 'until', the flow is
+// slower than the ticker. It returns the max number of the flow instances running at the same time, the
+// number of runs and the events received.
+func runTriggerPolicy(t *testing.T, options string, until func(events []BusEvent) bool) (int, int, []BusEvent) {
+	testingdata := fmt.Sprintf(`
+load "go:event_tick"
+load "go:print"
+
+event {
+	%s
+	co event_tick {
+		"duration": "20ms"
+	}
+}
+co print {
+	"_": "run"
+}
+sleep "150ms"
+`, options)
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var (
+		mu  sync.Mutex
+		out bytes.Buffer
+	)
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return writerFunc(func(p []byte) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return out.Write(p)
+		}), nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(ctx, id)
+	}()
+	var events []BusEvent
+	timeout := time.After(5 * time.Second)
+	for !until(events) {
+		select {
+		case ev := <-sub.C():
+			events = append(events, ev)
+		case <-timeout:
+			assert.FailNow(t, "the expected events aren't received")
+		}
+	}
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "triggers aren't stopped")
+	}
+	events = append(events, drainEvents(t, rt, sub, id)...)
+
+	max, runs := countRuns(events)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, runs, strings.Count(out.String(), "run"))
+	return max, runs, events
+}
+
+// drainEvents returns the rest events published before it's called, a warning is published as the end
+// of them.
+func drainEvents(t *testing.T, rt *Runtime, sub *Subscription, id nameid.ID) []BusEvent {
+	end := errors.New("end of the testing events")
+	rt.Warn(id, end)
+
+	var events []BusEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-sub.C():
+			if ev.Type == BusFlowWarning && errors.Is(ev.Err, end) {
+				return events
+			}
+			events = append(events, ev)
+		case <-timeout:
+			assert.FailNow(t, "the events aren't drained")
+		}
+	}
+}
+
+// countRuns returns the max number of the flow instances running at the same time, and the number of runs.
+func countRuns(events []BusEvent) (int, int) {
+	var current, max, runs int
+	for _, ev := range events {
+		switch ev.Type {
+		case BusFlowStarted:
+			current++
+			runs++
+			if current > max {
+				max = current
+			}
+		case BusFlowStopped:
+			current--
+		}
+	}
+	return max, runs
+}
+
+func countCanceled(events []BusEvent) int {
+	n := 0
+	for _, ev := range events {
+		if ev.Type == BusFlowStopped && ev.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func countDropped(events []BusEvent, reason string) int {
+	n := 0
+	for _, ev := range events {
+		if ev.Type == BusEventDropped {
+			if strings.Contains(ev.Err.Error(), reason) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestTriggerPolicySkip(t *testing.T) {
+	max, runs, events := runTriggerPolicy(t, `var policy = "skip"`, func(events []BusEvent) bool {
+		_, runs := countRuns(events)
+		return runs >= 2 && countDropped(events, "flow is running") > 0
+	})
+	assert.Equal(t, 1, max)
+	assert.GreaterOrEqual(t, runs, 2)
+	assert.Greater(t, countDropped(events, "flow is running"), 0)
+}
+
+func TestTriggerPolicyQueue(t *testing.T) {
+	max, runs, events := runTriggerPolicy(t, "var policy = \"queue\"\nvar max_queue = 1", func(events []BusEvent) bool {
+		_, runs := countRuns(events)
+		return runs >= 2 && countDropped(events, "queue is full") > 0
+	})
+	assert.Equal(t, 1, max)
+	assert.GreaterOrEqual(t, runs, 2)
+	assert.Greater(t, countDropped(events, "queue is full"), 0)
+}
+
+func TestTriggerPolicyReplace(t *testing.T) {
+	_, runs, events := runTriggerPolicy(t, `var policy = "replace"`, func(events []BusEvent) bool {
+		_, runs := countRuns(events)
+		return runs > 2 && countCanceled(events) > 0
+	})
+	assert.Greater(t, runs, 2)
+	// the running flows are canceled by the later events
+	assert.Greater(t, countCanceled(events), 0)
+}
+
+func TestTriggerPolicyParallel(t *testing.T) {
+	max, runs, events := runTriggerPolicy(t, "var policy = \"parallel\"\nvar max_parallel = 3\nvar max_queue = 0", func(events []BusEvent) bool {
+		max, runs := countRuns(events)
+		return max == 3 && runs > 3 && countDropped(events, "queue is full") > 0
+	})
+	assert.Equal(t, 3, max)
+	assert.Greater(t, runs, 3)
+	assert.Greater(t, countDropped(events, "queue is full"), 0)
+}
+
+func TestTriggerPolicyDebounce(t *testing.T) {
+	// the ticker is faster than the debounce window, so the flow never runs
+	_, runs, events := runTriggerPolicy(t, `var debounce = "50ms"`, func(events []BusEvent) bool {
+		return countDropped(events, "merged into the later event") >= 5
+	})
+	assert.Equal(t, 0, runs)
+	assert.Greater(t, countDropped(events, "merged into the later event"), 0)
+}
+
+func TestTriggerPolicyCoalesce(t *testing.T) {
+	_, runs, events := runTriggerPolicy(t, "var coalesce = \"100ms\"\nvar policy = \"skip\"", func(events []BusEvent) bool {
+		_, runs := countRuns(events)
+		return runs >= 1 && countDropped(events, "merged into the later event") > 0
+	})
+	assert.GreaterOrEqual(t, runs, 1)
+	assert.Greater(t, countDropped(events, "merged into the later event"), 0)
+}
+
+func TestTriggerState(t *testing.T) {
+	const testingdata string = `
+load "go:event_tick"
+load "go:print"
+
+var invalid = "x"
+
+fn broken = event_tick {
+	args = {
+		"duration": "$(invalid)"
+	}
+	var backoff = "10ms"
+	var max_backoff = "40ms"
+}
+
+event {
+	co event_tick {
+		"duration": "20ms"
+	}
+	co broken
+}
+co print
+`
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(ctx, id)
+	}()
+
+	insight := func() exported.FlowRunningInsight {
+		var fi exported.FlowRunningInsight
+		rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+			fi = fb.Export()
+			return nil
+		})
+		return fi
+	}
+	// The broken trigger backs off to the max after some failures
+	var fi exported.FlowRunningInsight
+	assert.Eventually(t, func() bool {
+		fi = insight()
+		return len(fi.Triggers) == 2 && fi.Triggers[0].Fires > 0 && fi.Triggers[1].Errors > 3 &&
+			fi.Triggers[1].Backoff == 40 && fi.Triggers[1].Status == string(StatusBackoff)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, fi.Triggers, 2)
+
+	tick := fi.Triggers[0]
+	assert.Equal(t, 10000, tick.Seq)
+	assert.Equal(t, string(StatusWaiting), tick.Status)
+	assert.Greater(t, tick.Fires, 0)
+	assert.False(t, tick.LastFire.IsZero())
+	assert.True(t, tick.NextFire.After(tick.LastFire))
+	assert.Equal(t, 0, tick.Errors)
+	assert.NoError(t, tick.LastError)
+
+	broken := fi.Triggers[1]
+	assert.Equal(t, "broken", broken.Name)
+	assert.Equal(t, string(StatusBackoff), broken.Status)
+	assert.Equal(t, 0, broken.Fires)
+	assert.Greater(t, broken.Errors, 3)
+	assert.Error(t, broken.LastError)
+	assert.Equal(t, int64(40), broken.Backoff)
+
+	cancel()
+	assert.NoError(t, <-done)
+	for _, tg := range insight().Triggers {
+		assert.Equal(t, string(StatusStopped), tg.Status)
+	}
+
+	var failed int
+	for _, ev := range drainEvents(t, rt, sub, id) {
+		if ev.Type == BusTriggerFailed {
+			assert.Equal(t, "broken", ev.Node)
+			assert.Error(t, ev.Err)
+			failed++
+		}
+	}
+	assert.GreaterOrEqual(t, failed, broken.Errors)
+}
+
+func TestTriggerFlow(t *testing.T) {
+	const testingdata string = `
+load "go:event_cron"
+load "go:print"
+
+var ev
+
+event {
+	co event_cron -> ev when $(ev.k) == "never" {
+		"expr": "0 0 1 1 *"
+	}
+}
+co print {
+	"_": "run $(ev.k)"
+}
+`
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var (
+		mu  sync.Mutex
+		out bytes.Buffer
+	)
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return writerFunc(func(p []byte) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return out.Write(p)
+		}), nil
+	}), WithCopyResources(func() resource.Resources {
+		return resource.Resources{CronTrigger: crontrigger.New()}
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	// The flow can't be triggered before its triggers are started
+	err = rt.TriggerFlow(ctx, id, nil)
+	assert.ErrorIs(t, err, ErrTriggersNotStarted)
+
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(ctx, id)
+	}()
+	assert.Eventually(t, func() bool {
+		return rt.TriggerFlow(ctx, id, map[string]string{"k": "v"}) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	var fired, stopped bool
+	for !stopped {
+		select {
+		case ev := <-sub.C():
+			switch ev.Type {
+			case BusTriggerFired:
+				assert.Equal(t, ManualTrigger, ev.Node)
+				fired = true
+			case BusFlowStopped:
+				assert.NoError(t, ev.Err)
+				stopped = true
+			}
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "the flow isn't triggered")
+		}
+	}
+	assert.True(t, fired)
+	// The data of the manual event are bound to the variables of the triggers, the 'when' filter isn't applied
+	mu.Lock()
+	assert.Contains(t, out.String(), "run v")
+	mu.Unlock()
+
+	cancel()
+	assert.NoError(t, <-done)
+	err = rt.TriggerFlow(context.Background(), id, nil)
+	assert.ErrorIs(t, err, ErrTriggersNotStarted)
+}
+
+func TestTriggerEventBinding(t *testing.T) {
+	const testingdata string = `
+load "go:event_tick"
+load "go:print"
+
+var tick
+var other
+
+event {
+	co event_tick -> tick when $(tick.which) == "event_tick" {
+		"duration": "30ms"
+	}
+	co event_tick -> other when $(other.which) == "nothing" {
+		"duration": "20ms"
+	}
+}
+co print {
+	"_": "fired $(tick.which) other=$(other.which);"
+}
+`
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var (
+		mu  sync.Mutex
+		out bytes.Buffer
+	)
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return writerFunc(func(p []byte) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return out.Write(p)
+		}), nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(ctx, id)
+	}()
+	// Wait for an event filtered and a running of the flow
+	var filtered, stopped int
+	timeout := time.After(5 * time.Second)
+	for filtered == 0 || stopped == 0 {
+		select {
+		case ev := <-sub.C():
+			switch {
+			case ev.Type == BusEventDropped && strings.Contains(ev.Err.Error(), "filtered by when"):
+				assert.Equal(t, 10001, ev.Seq)
+				filtered++
+			case ev.Type == BusFlowStopped:
+				stopped++
+			}
+		case <-timeout:
+			assert.FailNow(t, "the expected events aren't received")
+		}
+	}
+	cancel()
+	assert.NoError(t, <-done)
+	for _, ev := range drainEvents(t, rt, sub, id) {
+		if ev.Type == BusEventDropped && strings.Contains(ev.Err.Error(), "filtered by when") {
+			assert.Equal(t, 10001, ev.Seq)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, out.String(), "fired event_tick other=;")
+	assert.NotContains(t, out.String(), "other=event_tick")
+}
+
+func TestListFlows(t *testing.T) {
+	const eventflow string = `
+load "go:event_tick"
+load "go:print"
+
+event {
+	var policy = "queue"
+	co event_tick {
+		"duration": "10ms"
+	}
+}
+co print {
+	"_": "run"
+}
+sleep "200ms"
+`
+	rt := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ids := []nameid.ID{nameid.New("b.flowl"), nameid.New("a.flowl")}
+	for _, id := range ids {
+		if err := rt.ParseFlow(ctx, id, strings.NewReader(eventflow)); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+			return io.Discard, nil
+		}))
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+	flows := rt.ListFlows(ctx)
+	if assert.Len(t, flows, 2) {
+		assert.Equal(t, "a", flows[0].Name)
+		assert.Equal(t, "b", flows[1].Name)
+		assert.False(t, flows[0].TriggersStarted)
+		assert.Equal(t, 0, flows[0].ActiveRuns)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(ctx, ids[0])
+	}()
+	// The events are queued while the flow is running
+	assert.Eventually(t, func() bool {
+		flows := rt.ListFlows(ctx)
+		return flows[1].TriggersStarted && flows[1].ActiveRuns == 1 && flows[1].QueuedEvents > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.False(t, rt.ListFlows(ctx)[0].TriggersStarted)
+
+	cancel()
+	assert.NoError(t, <-done)
+	flows = rt.ListFlows(context.Background())
+	assert.False(t, flows[1].TriggersStarted)
+	assert.Equal(t, 0, flows[1].ActiveRuns)
+}
+
+func TestDispatchFailure(t *testing.T) {
+	const testingdata string = `
+load "go:event_tick"
+load "go:print"
+
+event {
+	var policy = "parallel"
+	co event_tick {
+		"duration": "20ms"
+	}
+}
+co print {
+	"_": "run"
+}
+sleep "150ms"
+`
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	// The replicas of the flow can't be created from the broken source
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		fb.source = []byte("co")
+		return nil
+	})
+
+	sub := rt.Subscribe(0, id)
+	defer sub.Close()
+	go rt.StartEventTrigger(ctx, id)
+
+	var failed BusEvent
+	assert.Eventually(t, func() bool {
+		select {
+		case ev := <-sub.C():
+			if ev.Type == BusEventDropped && strings.Contains(ev.Err.Error(), "flow can't run") {
+				failed = ev
+				return true
+			}
+		default:
+		}
+		return false
+	}, 2*time.Second, time.Millisecond)
+	assert.ErrorIs(t, failed.Err, ErrEventDropped)
+	assert.Greater(t, rt.ListFlows(ctx)[0].Dropped, 0)
+}
@@ -3,6 +3,7 @@ package runtime
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -24,6 +25,12 @@ const (
 	StatusStopped  = StatusType("STOPPED")
 	StatusKilled   = StatusType("KILLED")
 	StatusCanceled = StatusType("CANCELED")
+	// StatusWaiting and StatusBackoff are only used by the triggers, a trigger is waiting for the event,
+	// or waiting to retry after it failed.
+	StatusWaiting = StatusType("WAITING")
+	StatusBackoff = StatusType("BACKOFF")
+	// StatusCached is only used by the nodes, the node is stopped and its return values are from the cache.
+	StatusCached = StatusType("CACHED")
 )
 
 type FlowOption func(*FlowBody)
@@ -32,6 +39,12 @@ type FlowOption func(*FlowBody)
 type Flow struct {
 	sync.RWMutex
 	FlowBody
+	// active is the number of goroutines that are executing the flow or its triggers, idle is closed when
+	// the number drops to zero, and the idleFuncs are called then.
+	active    int
+	idle      chan struct{}
+	idleFuncs []func()
+	activeMu  sync.Mutex
 }
 
 func newflow(id nameid.ID, runq *actuator.RunQueue, ast *parser.AST) *Flow {
@@ -39,13 +52,15 @@ func newflow(id nameid.ID, runq *actuator.RunQueue, ast *parser.AST) *Flow {
 		FlowBody: FlowBody{
 			id:         id,
 			statistics: make(map[int]*functionStatistics),
+			triggers:   make(map[int]*triggerStatistics),
+			logwriters: make(map[string]io.Writer),
 			status:     StatusAdded,
 			runq:       runq,
 			ast:        ast,
-			beforeFunc: func(id nameid.ID) error {
+			beforeFunc: func(id nameid.ID, runid string) error {
 				return nil
 			},
-			afterFunc: func(id nameid.ID) error {
+			afterFunc: func(id nameid.ID, fi exported.FlowRunningInsight, err error) error {
 				return nil
 			},
 			createLogwriter: func(fileid string) (io.Writer, error) {
@@ -58,15 +73,17 @@ func newflow(id nameid.ID, runq *actuator.RunQueue, ast *parser.AST) *Flow {
 	}
 }
 
-// WithBeforeFunc initializes the before call-back.
-func WithBeforeFunc(_func func(nameid.ID) error) FlowOption {
+// WithBeforeFunc initializes the before call-back, it receives the run id of the running, the id is also
+// the RunID of the insight received by the after call-back.
+func WithBeforeFunc(_func func(nameid.ID, string) error) FlowOption {
 	return func(fb *FlowBody) {
 		fb.beforeFunc = _func
 	}
 }
 
-// WithAfterFunc initializes the after call-back.
-func WithAfterFunc(_func func(nameid.ID) error) FlowOption {
+// WithAfterFunc initializes the after call-back, it receives the insight of the stopped flow, e.g. the
+// timing of the nodes, and the error of the running, nil means the flow is successful.
+func WithAfterFunc(_func func(nameid.ID, exported.FlowRunningInsight, error) error) FlowOption {
 	return func(fb *FlowBody) {
 		fb.afterFunc = _func
 	}
@@ -86,6 +103,14 @@ func WithCopyResources(copy func() resource.Resources) FlowOption {
 	}
 }
 
+// WithDebugger attaches a debugger to the flow, the flow will be paused before the nodes that
+// hit the breakpoints of the debugger.
+func WithDebugger(d *Debugger) FlowOption {
+	return func(fb *FlowBody) {
+		fb.debugger = d
+	}
+}
+
 // WithLock read/write the fields of the flow with the lock.
 func (f *Flow) WithLock(exec func(body *FlowBody) error) error {
 	f.Lock()
@@ -109,7 +134,7 @@ func (f *Flow) Refresh() error {
 				isready = false
 			}
 			switch s.status {
-			case StatusStopped:
+			case StatusStopped, StatusCached:
 				f.progress.PutDone(seq)
 			case StatusRunning:
 				f.progress.PutRunning(seq)
@@ -158,7 +183,8 @@ func (f *Flow) ToReady() error {
 		defer f.Unlock()
 
 		for _, s := range f.statistics {
-			if !s.IsStatus(StatusStopped) {
+			// The nodes after the aborted step are still ready, they're never executed.
+			if !s.IsStatus(StatusStopped) && !s.IsStatus(StatusCached) && !s.IsStatus(StatusReady) {
 				return errors.New("not stopped")
 			}
 		}
@@ -216,6 +242,95 @@ func (f *Flow) AST() *parser.AST {
 	return f.ast
 }
 
+// Debugger returns the debugger attached to the flow, returns nil if not attached.
+func (f *Flow) Debugger() *Debugger {
+	f.Lock()
+	defer f.Unlock()
+	return f.debugger
+}
+
+// enter marks a goroutine starts to execute the flow or its triggers, it must be paired with leave.
+func (f *Flow) enter() {
+	f.activeMu.Lock()
+	defer f.activeMu.Unlock()
+	if f.active == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.active++
+}
+
+func (f *Flow) leave() {
+	f.activeMu.Lock()
+	f.active--
+	var funcs []func()
+	if f.active == 0 {
+		close(f.idle)
+		funcs, f.idleFuncs = f.idleFuncs, nil
+	}
+	f.activeMu.Unlock()
+	for _, fn := range funcs {
+		fn()
+	}
+}
+
+// onIdle calls the 'fn' when all goroutines that are executing the flow or its triggers finish, it's called
+// at once if the flow is idle.
+func (f *Flow) onIdle(fn func()) {
+	f.activeMu.Lock()
+	if f.active != 0 {
+		f.idleFuncs = append(f.idleFuncs, fn)
+		f.activeMu.Unlock()
+		return
+	}
+	f.activeMu.Unlock()
+	fn()
+}
+
+// waitIdle waits for all goroutines that are executing the flow or its triggers to finish.
+func (f *Flow) waitIdle(ctx context.Context) error {
+	f.activeMu.Lock()
+	if f.active == 0 {
+		f.activeMu.Unlock()
+		return nil
+	}
+	idle := f.idle
+	f.activeMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release stops and releases the drivers of all nodes and triggers of the flow.
+func (f *Flow) release(ctx context.Context) error {
+	var errs []error
+	release := func(n actuator.Node) error {
+		if err := n.(actuator.Task).Driver().StopAndRelease(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%w: release node '%s'", err, n.Name()))
+		}
+		return nil
+	}
+	runq := f.RunQ()
+	runq.WalkNode(release)
+	for _, tg := range runq.GetTriggers() {
+		release(tg)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// GetTriggerStatistics returns the state of the trigger, 'seq' is the sequence id of the trigger.
+func (f *Flow) GetTriggerStatistics(seq int) *triggerStatistics {
+	f.Lock()
+	defer f.Unlock()
+	return f.triggers[seq]
+}
+
 // GetStatistics returns the statistics of the function node, 'seq' is the sequence id of the function node.
 func (f *Flow) GetStatistics(seq int) *functionStatistics {
 	f.Lock()
@@ -233,6 +348,8 @@ type FlowBody struct {
 	// Save the result statistics of function execution
 	// the map is seq->functionStatistics
 	statistics map[int]*functionStatistics
+	// Save the state of the triggers, the map is seq->triggerStatistics
+	triggers map[int]*triggerStatistics
 	// Saved the execution progress of all nodes
 	progress progress
 	// The status of the flow, the value is one of the following:
@@ -242,17 +359,36 @@ type FlowBody struct {
 	// Running: The flow is running.
 	// Stopped: The flow is stopped, if you need to start again, it must be changed to Ready first.
 	status StatusType
-
-	// beforeFunc will be invoked beforeFunc the flow is started.
-	beforeFunc func(id nameid.ID) error
-	// afterFunc will be invoked afterFunc the flow is stopped.
-	afterFunc func(id nameid.ID) error
+	// runID identifies the current or the last running of the flow.
+	runID string
+	// labels are the labels of the task nodes, their 'run_id' is set when the flow starts to run.
+	labels []resource.LabelManger
+
+	// beforeFunc will be invoked beforeFunc the flow is started, runid identifies the running.
+	beforeFunc func(id nameid.ID, runid string) error
+	// afterFunc will be invoked afterFunc the flow is stopped, fi is the insight of the stopped flow and err is
+	// the error of the running.
+	afterFunc func(id nameid.ID, fi exported.FlowRunningInsight, err error) error
 	// createLogwriter creates a log writer for the function node.
 	createLogwriter func(fileid string) (io.Writer, error)
 	// copyResources copy the resources to every function node.
 	copyResources func() resource.Resources
 	// cancel is used to cancel the flow through the context.
 	cancel context.CancelFunc
+	// debugger is used to pause the flow before executing nodes, it's nil if not in debug mode.
+	debugger *Debugger
+	// opts are the options used to initialize the flow, they're saved to initialize the replicas.
+	opts []FlowOption
+	// logwriters saves the log writer of every node and trigger, the key is the seq of the node.
+	logwriters map[string]io.Writer
+	// source is the flowl source of the flow, it's used to create the replicas for parallel running.
+	source []byte
+	// policy controls how to handle the events from the triggers when the flow is running.
+	policy triggerPolicy
+	// dropped is the number of events from the triggers that didn't make the flow run.
+	dropped int
+	// dispatcher receives the events of the flow, it's nil if the event triggers aren't started.
+	dispatcher *dispatcher
 
 	runq *actuator.RunQueue
 	ast  *parser.AST
@@ -269,11 +405,19 @@ func (b *FlowBody) Export() exported.FlowRunningInsight {
 		Name:     b.id.Name(),
 		ID:       b.id.ID(),
 		Status:   string(b.status),
+		RunID:    b.runID,
 		Begin:    b.begin,
 		Duration: b.duration,
 		Total:    len(b.progress.nodes),
 		Running:  len(b.progress.running),
 		Done:     len(b.progress.done),
+		Dropped:  b.dropped,
+	}
+	if b.dispatcher != nil {
+		insight.TriggersStarted = true
+		insight.ActiveRuns, insight.QueuedEvents = b.dispatcher.counts()
+	} else if b.status == StatusRunning {
+		insight.ActiveRuns = 1
 	}
 	for _, seq := range b.progress.nodes {
 		fm := b.statistics[seq]
@@ -287,13 +431,116 @@ func (b *FlowBody) Export() exported.FlowRunningInsight {
 				Status:    string(mb.status),
 				LastError: mb.err,
 				Runs:      mb.runs,
+				Begin:     mb.begin,
 				Duration:  mb.duration,
 			})
 		})
 	}
+	for _, tg := range b.runq.GetTriggers() {
+		ts, ok := b.triggers[tg.(actuator.Task).Seq()]
+		if !ok {
+			continue
+		}
+		ts.WithLock(func(tb *triggerStatisticsBody) {
+			insight.Triggers = append(insight.Triggers, exported.TriggerRunningInsight{
+				Seq:       tg.(actuator.Task).Seq(),
+				Name:      tg.Name(),
+				Function:  tg.(actuator.Task).Driver().FunctionName(),
+				Driver:    tg.(actuator.Task).Driver().Name(),
+				Status:    string(tb.status),
+				Fires:     tb.fires,
+				LastFire:  tb.lastFire,
+				NextFire:  tb.nextFire,
+				Errors:    tb.errors,
+				LastError: tb.err,
+				Backoff:   tb.backoff.Milliseconds(),
+			})
+		})
+	}
 	return insight
 }
 
+type triggerStatisticsBody struct {
+	status StatusType
+	// Number of the events fired
+	fires int
+	// The time of the last event fired
+	lastFire time.Time
+	// The time of the next event expected, it's reported by the trigger function, or the time to retry
+	// after the trigger failed. It's zero if unknown.
+	nextFire time.Time
+	// Number of the consecutive failures
+	errors int
+	// The last error of the trigger, it's kept after the trigger recovered
+	err error
+	// The delay before retrying, it's zero if the trigger isn't failed
+	backoff time.Duration
+}
+
+// triggerStatistics saves the state of a trigger, it also implements resource.TriggerReporter.
+type triggerStatistics struct {
+	sync.Mutex
+	triggerStatisticsBody
+}
+
+func (ts *triggerStatistics) WithLock(exec func(body *triggerStatisticsBody)) {
+	ts.Lock()
+	defer ts.Unlock()
+	exec(&ts.triggerStatisticsBody)
+}
+
+// ExpectNext is invoked by the trigger function to report the time of the next event.
+func (ts *triggerStatistics) ExpectNext(t time.Time) {
+	ts.WithLock(func(body *triggerStatisticsBody) {
+		body.nextFire = t
+	})
+}
+
+func (ts *triggerStatistics) ToWaiting() {
+	ts.WithLock(func(body *triggerStatisticsBody) {
+		body.status = StatusWaiting
+	})
+}
+
+func (ts *triggerStatistics) ToStopped() {
+	ts.WithLock(func(body *triggerStatisticsBody) {
+		body.status = StatusStopped
+		body.nextFire = time.Time{}
+	})
+}
+
+// Fired records an event is fired, the failures are reset.
+func (ts *triggerStatistics) Fired() {
+	ts.WithLock(func(body *triggerStatisticsBody) {
+		body.fires++
+		body.lastFire = time.Now()
+		body.nextFire = time.Time{}
+		body.errors = 0
+		body.backoff = 0
+	})
+}
+
+// Failed records a failure of the trigger, and returns the delay before retrying. The delay starts from
+// 'initial' and is doubled after every consecutive failure, until reaching 'max'.
+func (ts *triggerStatistics) Failed(err error, initial, max time.Duration) time.Duration {
+	var delay time.Duration
+	ts.WithLock(func(body *triggerStatisticsBody) {
+		body.errors++
+		body.err = err
+		delay = initial
+		for i := 1; i < body.errors && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		body.backoff = delay
+		body.status = StatusBackoff
+		body.nextFire = time.Now().Add(delay)
+	})
+	return delay
+}
+
 type functionStatisticsBody struct {
 	// Flow id
 	fid nameid.ID
@@ -343,6 +590,9 @@ func (fs *functionStatistics) ToStopped(err error) {
 		body.duration = body.end.Sub(body.begin).Milliseconds()
 		body.status = StatusStopped
 		body.runs += 1
+		if body.err == nil && body.node.(actuator.Task).Cached() {
+			body.status = StatusCached
+		}
 
 		if body.err != nil {
 			if body.err == actuator.ErrConditionIsFalse {
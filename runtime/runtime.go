@@ -1,42 +1,37 @@
 package runtime
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/skoowoo/cofx/pkg/nameid"
 	"github.com/skoowoo/cofx/runtime/actuator"
+	"github.com/skoowoo/cofx/service/exported"
+	"github.com/skoowoo/cofx/service/resource"
 )
 
-// Event is from the event trigger, it will be used to make the flow run
-type Event struct {
-	id      nameid.ID
-	result  chan error
-	execute func(id nameid.ID) error
-}
-
 type Runtime struct {
-	store  *flowstore
-	events chan Event
+	store *flowstore
+	bus   *eventbus
+	// runs is used to generate the run id of the flows
+	runs uint64
 }
 
 func New() *Runtime {
 	r := &Runtime{
-		events: make(chan Event, 64),
+		bus: newEventbus(),
 	}
 	r.store = &flowstore{
 		entity: make(map[string]*Flow),
 	}
-
-	// start a watcher goroutine to waiting and handling event from triggers. The watcher goroutine
-	// don't finished forever.
-	go r.startEventWatcher()
 	return r
 }
 
@@ -44,11 +39,21 @@ func New() *Runtime {
 // a flow source file.
 // After invoking this method, the flow's status is ADDED.
 func (rt *Runtime) ParseFlow(ctx context.Context, id nameid.ID, rd io.Reader) error {
-	rq, ast, err := actuator.New(rd)
+	source, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	rq, ast, err := actuator.New(bytes.NewReader(source))
+	if err != nil {
+		return err
+	}
+	policy, err := parseTriggerPolicy(rq)
 	if err != nil {
 		return err
 	}
 	flow := newflow(id, rq, ast)
+	flow.source = source
+	flow.policy = policy
 	if err := rt.store.store(id.ID(), flow); err != nil {
 		return err
 	}
@@ -67,72 +72,134 @@ func (rt *Runtime) InitFlow(ctx context.Context, id nameid.ID, opts ...FlowOptio
 
 	ready := func(fb *FlowBody) error {
 		// Initialize options of the flow
+		fb.opts = opts
 		for _, opt := range opts {
 			opt(fb)
 		}
+		return rt.initFlowBody(ctx, fb, true)
+	}
 
-		// Initialize all task nodes
-		err := fb.runq.WalkNode(func(node actuator.Node) error {
-			seq := node.(actuator.Task).Seq()
-
-			fb.statistics[seq] = &functionStatistics{
-				functionStatisticsBody: functionStatisticsBody{
-					fid:    fb.id,
-					node:   node,
-					status: StatusReady,
-				},
-			}
-			fb.progress.nodes = append(fb.progress.nodes, seq)
+	if err := flow.WithLock(ready); err != nil {
+		return err
+	}
+	if err := flow.Refresh(); err != nil {
+		return err
+	}
+	rt.publishNodes(flow, BusNodeReady)
+	return nil
+}
 
-			// Initialize the function node, it will Load&Init the function driver
-			logwriter, err := fb.createLogwriter(strconv.Itoa(seq))
+// initFlowBody initializes all task nodes of the flow, and the triggers if 'triggers' is true, then makes
+// the flow into READY status.
+func (rt *Runtime) initFlowBody(ctx context.Context, fb *FlowBody, triggers bool) error {
+	init := func(node actuator.Node, with ...func(*resource.Resources)) error {
+		seq := strconv.Itoa(node.(actuator.Task).Seq())
+		logwriter, ok := fb.logwriters[seq]
+		if !ok {
+			w, err := fb.createLogwriter(seq)
 			if err != nil {
 				return err
 			}
-			resources := fb.copyResources()
-			resources.Logwriter = logwriter
-			if resources.Labels != nil {
-				resources.Labels.Set("node_seq", strconv.Itoa(seq))
-				resources.Labels.Set("node_name", node.Name())
-				resources.Labels.Set("flow_id", fb.id.ID())
-			}
-			return node.Init(ctx, actuator.WithResources(resources))
-		})
-		if err != nil {
-			return err
+			logwriter = rt.tapLogwriter(fb.id, node, w)
+			fb.logwriters[seq] = logwriter
+		}
+		resources := fb.copyResources()
+		resources.Logwriter = logwriter
+		if resources.Labels != nil {
+			resources.Labels.Set("node_seq", seq)
+			resources.Labels.Set("node_name", node.Name())
+			resources.Labels.Set("flow_id", fb.id.ID())
+			resources.Labels.Set("flow_name", fb.id.Name())
+		}
+		for _, f := range with {
+			f(&resources)
 		}
+		resources.Functions = newFunctionCaller(fb.runq, resources)
+		return node.Init(ctx, actuator.WithResources(resources))
+	}
 
-		// Initialize all triggers
-		triggers := fb.runq.GetTriggers()
-		for _, tg := range triggers {
-			seq := tg.(actuator.Task).Seq()
-			logwriter, err := fb.createLogwriter(strconv.Itoa(seq))
-			if err != nil {
-				return err
+	// Initialize all task nodes
+	fb.labels = nil
+	err := fb.runq.WalkNode(func(node actuator.Node) error {
+		seq := node.(actuator.Task).Seq()
+
+		fb.statistics[seq] = &functionStatistics{
+			functionStatisticsBody: functionStatisticsBody{
+				fid:    fb.id,
+				node:   node,
+				status: StatusReady,
+			},
+		}
+		fb.progress.nodes = append(fb.progress.nodes, seq)
+
+		// Initialize the function node, it will Load&Init the function driver
+		return init(node, func(r *resource.Resources) {
+			if r.Labels != nil {
+				fb.labels = append(fb.labels, r.Labels)
 			}
-			resources := fb.copyResources()
-			resources.Logwriter = logwriter
-			if resources.Labels != nil {
-				resources.Labels.Set("node_seq", strconv.Itoa(seq))
-				resources.Labels.Set("node_name", tg.Name())
-				resources.Labels.Set("flow_id", fb.id.ID())
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// Initialize all triggers
+	if triggers {
+		for _, tg := range fb.runq.GetTriggers() {
+			ts := &triggerStatistics{
+				triggerStatisticsBody: triggerStatisticsBody{
+					status: StatusReady,
+				},
 			}
-			if err := tg.Init(ctx, actuator.WithResources(resources)); err != nil {
+			fb.triggers[tg.(actuator.Task).Seq()] = ts
+			// The trigger reports the time of the next event through the resource
+			err := init(tg, func(r *resource.Resources) {
+				r.Trigger = ts
+			})
+			if err != nil {
 				return err
 			}
 		}
+	}
 
-		fb.status = StatusReady
+	fb.status = StatusReady
+	return nil
+}
+
+// replicate creates and initializes a new instance of the flow from its source, the replica shares the
+// options and log writers with the flow, but has its own nodes and drivers, so they can run at the same
+// time. The triggers of the flow aren't initialized in the replica.
+func (rt *Runtime) replicate(ctx context.Context, flow *Flow) (*Flow, error) {
+	var (
+		id         nameid.ID
+		source     []byte
+		opts       []FlowOption
+		logwriters map[string]io.Writer
+	)
+	flow.WithLock(func(fb *FlowBody) error {
+		id, source, opts, logwriters = fb.id, fb.source, fb.opts, fb.logwriters
 		return nil
+	})
+	rq, ast, err := actuator.New(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
 	}
-
-	if err := flow.WithLock(ready); err != nil {
-		return err
+	replica := newflow(id, rq, ast)
+	err = replica.WithLock(func(fb *FlowBody) error {
+		for _, opt := range opts {
+			opt(fb)
+		}
+		fb.logwriters = logwriters
+		return rt.initFlowBody(ctx, fb, false)
+	})
+	if err != nil {
+		replica.release(context.Background())
+		return nil, err
 	}
-	if err := flow.Refresh(); err != nil {
-		return err
+	if err := replica.Refresh(); err != nil {
+		return nil, err
 	}
-	return nil
+	return replica, nil
 }
 
 // Stopped2Ready will reset the status of the flow and all nodes to ready, but only when all nodes are stopped
@@ -142,7 +209,14 @@ func (rt *Runtime) Stopped2Ready(ctx context.Context, id nameid.ID) error {
 	if err != nil {
 		return err
 	}
-	return flow.ToReady()
+	if flow.IsReady() {
+		return nil
+	}
+	if err := flow.ToReady(); err != nil {
+		return err
+	}
+	rt.publishNodes(flow, BusNodeReady)
+	return nil
 }
 
 // MustReay is a thin wrapper of Stopped2Ready
@@ -159,6 +233,24 @@ func (rt *Runtime) FetchFlow(ctx context.Context, id nameid.ID, do func(*FlowBod
 	return flow.WithLock(do)
 }
 
+// ListFlows exports the statistics of all flows in runtime, they are sorted by the name.
+func (rt *Runtime) ListFlows(ctx context.Context) []exported.FlowRunningInsight {
+	var insights []exported.FlowRunningInsight
+	for _, flow := range rt.store.list() {
+		flow.WithLock(func(fb *FlowBody) error {
+			insights = append(insights, fb.Export())
+			return nil
+		})
+	}
+	sort.Slice(insights, func(i, j int) bool {
+		if insights[i].Name != insights[j].Name {
+			return insights[i].Name < insights[j].Name
+		}
+		return insights[i].ID < insights[j].ID
+	})
+	return insights
+}
+
 // CancelFlow cancel the flow and make it into CANCELED status.
 func (rt *Runtime) CancelFlow(ctx context.Context, id nameid.ID) error {
 	flow, err := rt.store.get(id.ID())
@@ -174,7 +266,58 @@ func (rt *Runtime) CancelFlow(ctx context.Context, id nameid.ID) error {
 	})
 }
 
+// DeleteFlow unloads the flow from runtime, it cancels the flow and waits for the running nodes and
+// triggers of the flow to finish until the 'ctx' is done, then stops and releases all drivers of the flow.
+// If the 'ctx' is done first, the drivers are released after the nodes and triggers finished.
 func (rt *Runtime) DeleteFlow(ctx context.Context, id nameid.ID) error {
+	flow, err := rt.store.get(id.ID())
+	if err != nil {
+		return err
+	}
+	// Remove the flow first to avoid it being executed again by the event triggers.
+	rt.store.remove(id.ID())
+	flow.WithLock(func(fb *FlowBody) error {
+		if fb.cancel != nil {
+			fb.cancel()
+		}
+		fb.cancel = nil
+		return nil
+	})
+	if err := flow.waitIdle(ctx); err != nil {
+		// The triggers may be still in their entrypoints, so the drivers are released after the last of them
+		// finished instead.
+		flow.onIdle(func() {
+			if err := flow.release(context.Background()); err != nil {
+				rt.Warn(id, fmt.Errorf("%w: release flow %s", err, id.ID()))
+			}
+		})
+		return fmt.Errorf("%w: wait flow %s to stop", err, id.ID())
+	}
+	return flow.release(context.Background())
+}
+
+// Shutdown cancels all flows in runtime at once, then deletes them one by one, the 'ctx' is used to
+// control how long to wait for the running flows to finish.
+func (rt *Runtime) Shutdown(ctx context.Context) error {
+	keys := rt.store.keys()
+	for _, k := range keys {
+		if flow, err := rt.store.get(k); err == nil {
+			rt.CancelFlow(ctx, flow.id)
+		}
+	}
+	var errs []error
+	for _, k := range keys {
+		flow, err := rt.store.get(k)
+		if err != nil {
+			continue
+		}
+		if err := rt.DeleteFlow(ctx, flow.id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
 	return nil
 }
 
@@ -189,7 +332,8 @@ func (rt *Runtime) HasTrigger(id nameid.ID) (bool, error) {
 }
 
 // StartEventTrigger start the event trigger of a flow, every event trigger function will run in a goroutine
-// When a event trigger returned without an error, will create and send a event to runtime
+// When a event trigger returned without an error, will create and send a event to the dispatcher of the flow,
+// the dispatcher decides to run the flow or not based on the policy of the 'event' block.
 func (rt *Runtime) StartEventTrigger(ctx context.Context, id nameid.ID) error {
 	flow, err := rt.store.get(id.ID())
 	if err != nil {
@@ -200,56 +344,102 @@ func (rt *Runtime) StartEventTrigger(ctx context.Context, id nameid.ID) error {
 	if n == 0 {
 		return nil
 	}
+	flow.enter()
+	defer flow.leave()
+
+	// The 'when' filters of the triggers are evaluated in a copy of the flow, so the variables of the running
+	// flow aren't changed by the events that are dropped.
+	var (
+		filter   *actuator.RunQueue
+		filterMu sync.Mutex
+	)
+	for _, tg := range triggers {
+		if b := tg.(actuator.Task).Block(); b != nil && b.HasCondition() {
+			var source []byte
+			flow.WithLock(func(fb *FlowBody) error {
+				source = fb.source
+				return nil
+			})
+			if filter, _, err = actuator.New(bytes.NewReader(source)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	d := newDispatcher(rt, flow)
+	flow.WithLock(func(fb *FlowBody) error {
+		fb.dispatcher = d
+		return nil
+	})
+	defer flow.WithLock(func(fb *FlowBody) error {
+		fb.dispatcher = nil
+		return nil
+	})
+	go d.run(ctx)
+
 	var wg sync.WaitGroup
 	wg.Add(n)
 	for _, tg := range triggers {
 		go func(trigger actuator.Trigger) {
 			defer wg.Done()
-			errNum := 0
+			var (
+				seq  = trigger.(actuator.Task).Seq()
+				name = trigger.Name()
+				ts   = flow.GetTriggerStatistics(seq)
+			)
+			// The backoff options are validated when parsing the flow
+			initial, max, _ := trigger.(actuator.Task).Backoff()
+			defer ts.ToStopped()
 			for {
+				ts.ToWaiting()
 				err := trigger.Exec(ctx)
 				if errors.Is(err, context.Canceled) {
 					return
 				}
 				if err != nil {
-					// TODO: log
-					log.Println(err)
-					errNum++
-					if errNum <= 5 {
-						time.Sleep(time.Second * time.Duration(errNum))
-					} else if errNum <= 10 {
-						time.Sleep(time.Second * time.Duration(errNum*2))
-					} else if errNum <= 15 {
-						time.Sleep(time.Second * time.Duration(errNum*5))
-					} else {
-						time.Sleep(time.Second * 300)
+					delay := ts.Failed(err, initial, max)
+					rt.bus.publish(id, BusEvent{
+						Type: BusTriggerFailed,
+						Seq:  seq,
+						Node: name,
+						Err:  err,
+					})
+					select {
+					case <-time.After(delay):
+					case <-ctx.Done():
+						return
 					}
 					continue
 				}
 				// trigger returns without an error, it's success
-				errNum = 0
-				ev := Event{
-					id:     id,
-					result: make(chan error, 1),
-					execute: func(id nameid.ID) error {
-						if err := rt.MustReady(ctx, id); err != nil {
-							return err
-						}
-						if err := rt.ExecFlow(ctx, id); err != nil {
-							return err
-						}
-						return nil
-					},
+				ts.Fired()
+				ev := triggerEvent{
+					seq:     seq,
+					name:    name,
+					returns: trigger.(actuator.Task).LastReturns(),
 				}
-				select {
-				case rt.events <- ev:
-					// wait for the result of the flow execution, avoid to run flow repeated at
-					// the same time
-					err := <-ev.result
+				rt.bus.publish(id, BusEvent{
+					Type: BusTriggerFired,
+					Seq:  ev.seq,
+					Node: ev.name,
+				})
+				if filter != nil {
+					filterMu.Lock()
+					ok, err := filter.MatchEvent(seq, ev.returns)
+					filterMu.Unlock()
 					if err != nil {
-						// TODO:
-						log.Println(err)
+						d.drop(ev, err.Error())
+						continue
+					}
+					if !ok {
+						d.drop(ev, "filtered by when")
+						continue
 					}
+				}
+				// The dispatcher never blocks for long, the flow is run in another goroutine.
+				select {
+				case d.events <- ev:
 				case <-ctx.Done():
 					return
 				}
@@ -257,42 +447,93 @@ func (rt *Runtime) StartEventTrigger(ctx context.Context, id nameid.ID) error {
 		}(tg)
 	}
 	wg.Wait()
+	<-d.stopped
 	return nil
 }
 
-func (rt *Runtime) startEventWatcher() error {
-	for {
-		ev := <-rt.events
-		// Use a goroutine to execute the flow, avoid to block the event trigger, because
-		// the flow may be run for a long time.
-		go func() {
-			ev.result <- ev.execute(ev.id)
-			close(ev.result)
-		}()
+// TriggerFlow sends an event to the flow whose event triggers are started, it works as if a trigger of the
+// flow fired, the 'data' is the return values of the event. The event is handled by the dispatcher based on
+// the policy of the 'event' block like the others.
+func (rt *Runtime) TriggerFlow(ctx context.Context, id nameid.ID, data map[string]string) error {
+	flow, err := rt.store.get(id.ID())
+	if err != nil {
+		return err
+	}
+	var d *dispatcher
+	flow.WithLock(func(fb *FlowBody) error {
+		d = fb.dispatcher
+		return nil
+	})
+	if d == nil {
+		return fmt.Errorf("%w: flow %s", ErrTriggersNotStarted, id)
+	}
+	ev := triggerEvent{
+		name:    ManualTrigger,
+		returns: data,
 	}
+	select {
+	case d.events <- ev:
+	case <-d.stopped:
+		return fmt.Errorf("%w: flow %s", ErrTriggersNotStarted, id)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	rt.bus.publish(id, BusEvent{
+		Type: BusTriggerFired,
+		Node: ev.name,
+	})
+	return nil
 }
 
 // ExecFlow execute a flow step by step.
-func (rt *Runtime) ExecFlow(ctx context.Context, id nameid.ID) (err0 error) {
+func (rt *Runtime) ExecFlow(ctx context.Context, id nameid.ID) error {
 	flow, err := rt.store.get(id.ID())
 	if err != nil {
 		return err
 	}
+	return rt.execFlow(ctx, flow)
+}
+
+func (rt *Runtime) execFlow(ctx context.Context, flow *Flow) (err0 error) {
+	id := flow.id
+	flow.enter()
+	defer flow.leave()
 	if !flow.IsReady() {
 		return fmt.Errorf("not ready: flow %s", id.ID())
 	}
 
+	runid := fmt.Sprintf("%s.%d", id.ID(), atomic.AddUint64(&rt.runs, 1))
+	flow.WithLock(func(fb *FlowBody) error {
+		fb.runID = runid
+		// The nodes don't run now, so their labels can be changed.
+		for _, l := range fb.labels {
+			l.Set("run_id", runid)
+		}
+		return nil
+	})
 	flow.ToRunning()
-	if err := flow.beforeFunc(id); err != nil {
+	rt.bus.publish(id, BusEvent{Type: BusFlowStarted, RunID: runid})
+	if err := flow.beforeFunc(id, runid); err != nil {
+		flow.ToStopped()
+		rt.bus.publish(id, BusEvent{Type: BusFlowStopped, RunID: runid, Err: err})
 		return err
 	}
 	defer func() {
 		flow.ToStopped()
-		if err := flow.afterFunc(id); err != nil {
+		if d := flow.Debugger(); d != nil {
+			d.Detach()
+		}
+		var fi exported.FlowRunningInsight
+		flow.WithLock(func(fb *FlowBody) error {
+			fi = fb.Export()
+			return nil
+		})
+		if err := flow.afterFunc(id, fi, err0); err != nil {
 			err0 = err
 		}
+		rt.bus.publish(id, BusEvent{Type: BusFlowStopped, RunID: runid, Err: err0})
 	}()
-	err = flow.RunQ().WalkAndExec(ctx, rt.execStepFunc(ctx, flow))
+	err := flow.RunQ().WalkAndExec(ctx, rt.execStepFunc(ctx, flow))
 	if err != nil {
 		return err
 	}
@@ -306,7 +547,20 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 
 		// parallel run functions at the step
 		for _, n := range batch {
-			f.GetStatistics(n.(actuator.Task).Seq()).ToRunning()
+			// In debug mode, the debugger may pause the flow before the node
+			if d := f.Debugger(); d != nil {
+				if action := d.pause(ctx, f, n); action == DebugSkip {
+					fs := f.GetStatistics(n.(actuator.Task).Seq())
+					fs.ToRunning()
+					fs.ToStopped(actuator.ErrConditionIsFalse)
+					rt.publishNode(f, BusNodeStopped, fs)
+					ch <- fs
+					continue
+				}
+			}
+			fs := f.GetStatistics(n.(actuator.Task).Seq())
+			fs.ToRunning()
+			rt.publishNode(f, BusNodeRunning, fs)
 			f.Refresh()
 
 			go func(node actuator.Node) {
@@ -314,6 +568,9 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 				retries := node.(actuator.Task).RetryOnFailure() + 1
 				// Start to execute the function node, it will call the function driver to execute the function code
 				for i := 0; i < retries; i++ {
+					if i > 0 {
+						rt.publishNode(f, BusNodeRetrying, fs)
+					}
 					err := node.Exec(ctx)
 					fs.ToStopped(err)
 					if err == nil {
@@ -326,6 +583,7 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 						break
 					}
 				}
+				rt.publishNode(f, BusNodeStopped, fs)
 				// Send the result of the function execution to make it stopped really
 				ch <- fs
 			}(n)
@@ -352,3 +610,58 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 		return nil
 	}
 }
+
+// Subscribe returns a subscription to receive the state change events of the flows, if no flow id is
+// given, the events of all flows will be received. 'capacity' is the max number of events buffered for
+// the subscriber, '<= 0' means using the default capacity. The subscription must be closed after using.
+func (rt *Runtime) Subscribe(capacity int, ids ...nameid.ID) *Subscription {
+	return rt.bus.subscribe(capacity, ids...)
+}
+
+// Warn publishes the error that doesn't fail the flow, e.g. the outputs of the flow can't be delivered to
+// the other flows, so the subscribers can see it.
+func (rt *Runtime) Warn(id nameid.ID, err error) {
+	rt.bus.publish(id, BusEvent{Type: BusFlowWarning, Err: err})
+}
+
+// publishNode publishes a node event based on the statistics of the node.
+func (rt *Runtime) publishNode(f *Flow, typ BusEventType, fs *functionStatistics) {
+	ev := BusEvent{Type: typ}
+	var id nameid.ID
+	fs.WithLock(func(body *functionStatisticsBody) {
+		id = body.fid
+		ev.Seq = body.node.(actuator.Task).Seq()
+		ev.Node = body.node.Name()
+		ev.Runs = body.runs
+		if typ == BusNodeStopped {
+			ev.Err = body.err
+		}
+	})
+	rt.bus.publish(id, ev)
+}
+
+// publishNodes publishes a node event for every node of the flow in order.
+func (rt *Runtime) publishNodes(f *Flow, typ BusEventType) {
+	var nodes []int
+	f.WithLock(func(body *FlowBody) error {
+		nodes = append(nodes, body.progress.nodes...)
+		return nil
+	})
+	for _, seq := range nodes {
+		rt.publishNode(f, typ, f.GetStatistics(seq))
+	}
+}
+
+// tapLogwriter wraps the log writer of the node, every line written into it will be published as a log event.
+func (rt *Runtime) tapLogwriter(id nameid.ID, node actuator.Node, w io.Writer) io.Writer {
+	seq := node.(actuator.Task).Seq()
+	name := node.Name()
+	return newLogTap(w, func(line string) {
+		rt.bus.publish(id, BusEvent{
+			Type: BusLogLine,
+			Seq:  seq,
+			Node: name,
+			Line: line,
+		})
+	})
+}
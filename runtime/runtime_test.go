@@ -3,14 +3,21 @@ package runtime
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/cache"
 	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/service/exported"
 	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/labels"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,6 +73,129 @@ co print {
 	newTestingFlowCase(t, rt, testingdata, id, e)
 }
 
+func TestInlineScript(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+fn reshape = script {
+	var code = "
+def main(args):
+    call('print', {'_': 'called ' + args['name']})
+    return {'upper': args['name'].upper()}
+"
+	args = {
+		"name": "cofx"
+	}
+}
+
+var out
+co reshape -> out
+co print {
+	"_": "$(out.upper)"
+}
+	`
+
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	e := expect{
+		nodes:  2,
+		output: "called cofx\nCOFX",
+	}
+	newTestingFlowCase(t, rt, testingdata, id, e)
+}
+
+func TestInitFlowVersionNotFound(t *testing.T) {
+	const testingdata string = `
+load "go:print@^2.0"
+
+co print
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	assert.ErrorIs(t, err, manifest.ErrVersionNotFound)
+	assert.Contains(t, err.Error(), "the installed versions are 1.0.0")
+}
+
+func TestInitFlowInvalidArg(t *testing.T) {
+	const testingdata string = `
+load "go:http_get"
+
+co http_get {
+	"url": "example.com"
+	"query_json_path": "a"
+}
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	assert.ErrorIs(t, err, manifest.ErrInvalidArg)
+	assert.Contains(t, err.Error(), "'url'")
+	assert.Contains(t, err.Error(), "node 'http_get'")
+	assert.Contains(t, err.Error(), "line 5")
+}
+
+func TestExecFlowInvalidArg(t *testing.T) {
+	const testingdata string = `
+load "go:http_get"
+
+var url = "example.com"
+
+co http_get {
+	"url": "$(url)"
+	"query_json_path": "a"
+}
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+
+	// The interpolated value is unknown when initializing, it's validated right before running
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	assert.NoError(t, err)
+	err = rt.ExecFlow(ctx, id)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid argument: 'url'")
+	assert.Contains(t, err.Error(), "line 7")
+}
+
+func TestInitFlowMissingArg(t *testing.T) {
+	const testingdata string = `
+load "go:http_get"
+
+co http_get {
+	"url": "https://example.com"
+}
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	assert.ErrorIs(t, err, manifest.ErrMissingArg)
+	assert.Contains(t, err.Error(), "'query_json_path' of node 'http_get', line 4")
+}
+
 func TestAddReadyStartFlow(t *testing.T) {
 	const testingdata string = `
 	load "go:print"
@@ -161,10 +291,10 @@ func newTestingFlowCase(t *testing.T, rt *Runtime, testingdata string, id nameid
 		createLogWriter := func(writerid string) (io.Writer, error) {
 			return &out, nil
 		}
-		beforeExec := func(id nameid.ID) error {
+		beforeExec := func(id nameid.ID, runid string) error {
 			return nil
 		}
-		afterExec := func(id nameid.ID) error {
+		afterExec := func(id nameid.ID, fi exported.FlowRunningInsight, err error) error {
 			return nil
 		}
 		copy := func() resource.Resources {
@@ -231,3 +361,262 @@ func newTestingFlowCase(t *testing.T, rt *Runtime, testingdata string, id nameid
 		}
 	}
 }
+
+func TestDeleteFlow(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+for {
+	sleep "100ms"
+	co print {
+		"_": "sleep 100ms"
+	}
+}
+	`
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	var out bytes.Buffer
+	err := rt.InitFlow(ctx, id, WithCreateLogwriter(func(string) (io.Writer, error) {
+		return &out, nil
+	}))
+	assert.NoError(t, err)
+
+	execCtx, cancel := context.WithCancel(ctx)
+	rt.FetchFlow(ctx, id, func(b *FlowBody) error {
+		b.SetCancel(cancel)
+		return nil
+	})
+	wait := make(chan error, 1)
+	go func() {
+		wait <- rt.ExecFlow(execCtx, id)
+	}()
+	time.Sleep(300 * time.Millisecond)
+
+	deleteCtx, deleteCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer deleteCancel()
+	err = rt.DeleteFlow(deleteCtx, id)
+	assert.NoError(t, err)
+
+	// The flow has been stopped and removed from runtime when DeleteFlow returned.
+	select {
+	case err := <-wait:
+		assert.Error(t, err)
+	default:
+		assert.FailNow(t, "flow is still running after deleted")
+	}
+	assert.Len(t, rt.store.entity, 0)
+	err = rt.DeleteFlow(deleteCtx, id)
+	assert.Error(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	rt := New()
+	ctx := context.Background()
+	ids := []nameid.ID{nameid.New("testingdata1.flowl"), nameid.New("testingdata2.flowl")}
+	for _, id := range ids {
+		readyBusTestingFlow(t, rt, id)
+	}
+	assert.NoError(t, rt.ExecFlow(ctx, ids[0]))
+
+	assert.NoError(t, rt.Shutdown(ctx))
+	assert.Len(t, rt.store.entity, 0)
+}
+
+func TestCachedNode(t *testing.T) {
+	dir := t.TempDir()
+	keyfile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keyfile, []byte("v1"), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	testingdata := `
+load "go:print"
+
+fn p = print {
+	var cache = "1h"
+	var cache_key_files = "` + keyfile + `"
+	args = {
+		"_": "hello cache"
+	}
+}
+
+co p
+	`
+	c := cache.New(filepath.Join(dir, "cache"))
+	run := func() (string, StatusType) {
+		rt := New()
+		ctx := context.Background()
+		id := nameid.New("testingdata.flowl")
+		var out bytes.Buffer
+		assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+		err := rt.InitFlow(ctx, id,
+			WithCreateLogwriter(func(string) (io.Writer, error) {
+				return &out, nil
+			}),
+			WithCopyResources(func() resource.Resources {
+				return resource.Resources{Cache: c}
+			}))
+		assert.NoError(t, err)
+		assert.NoError(t, rt.ExecFlow(ctx, id))
+
+		var status StatusType
+		rt.FetchFlow(ctx, id, func(b *FlowBody) error {
+			for _, s := range b.statistics {
+				status = s.status
+			}
+			assert.Len(t, b.progress.done, 1)
+			return nil
+		})
+		return strings.TrimSpace(out.String()), status
+	}
+
+	out, status := run()
+	assert.Equal(t, "hello cache", out)
+	assert.Equal(t, StatusStopped, status)
+
+	out, status = run()
+	assert.Equal(t, "", out)
+	assert.Equal(t, StatusCached, status)
+
+	entries, err := c.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "go:print@1.0.0", entries[0].Function)
+
+	// The content of the key file is changed
+	if err := os.WriteFile(keyfile, []byte("v2"), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	out, status = run()
+	assert.Equal(t, "hello cache", out)
+	assert.Equal(t, StatusStopped, status)
+}
+
+func TestInvalidCache(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+fn p = print {
+	var cache = "x"
+}
+
+co p
+	`
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+	err := rt.InitFlow(ctx, id,
+		WithCreateLogwriter(func(string) (io.Writer, error) {
+			return io.Discard, nil
+		}),
+		WithCopyResources(func() resource.Resources {
+			return resource.Resources{Cache: cache.New(t.TempDir())}
+		}))
+	assert.NoError(t, err)
+	err = rt.ExecFlow(ctx, id)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid cache: cache 'x' of node 'p'")
+}
+
+type failedCache struct{}
+
+func (failedCache) Get(key string) (map[string]string, bool) { return nil, false }
+
+func (failedCache) Put(key, function string, ttl time.Duration, returns map[string]string) error {
+	return errors.New("disk is full")
+}
+
+func TestCacheFailure(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+fn p = print {
+	var cache = "1h"
+	args = {
+		"_": "hello cache"
+	}
+}
+
+co p
+	`
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	var out bytes.Buffer
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+	err := rt.InitFlow(ctx, id,
+		WithCreateLogwriter(func(string) (io.Writer, error) {
+			return &out, nil
+		}),
+		WithCopyResources(func() resource.Resources {
+			return resource.Resources{Cache: failedCache{}}
+		}))
+	assert.NoError(t, err)
+	// The node doesn't fail because its returns can't be cached
+	assert.NoError(t, rt.ExecFlow(ctx, id))
+	assert.Contains(t, out.String(), "hello cache")
+	assert.Contains(t, out.String(), "warning: disk is full: cache the returns of node 'p'")
+}
+
+func TestFlowOnIdle(t *testing.T) {
+	f := &Flow{}
+	f.enter()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, f.waitIdle(ctx), context.Canceled)
+
+	var called int
+	f.onIdle(func() { called++ })
+	assert.Equal(t, 0, called)
+	// The last goroutine calls it when leaving
+	f.leave()
+	assert.Equal(t, 1, called)
+	assert.NoError(t, f.waitIdle(ctx))
+
+	// It's called at once if the flow is idle
+	f.onIdle(func() { called++ })
+	assert.Equal(t, 2, called)
+}
+
+func TestRunIDLabel(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+co print {
+    "_" : "hello"
+}
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	assert.NoError(t, rt.ParseFlow(ctx, id, strings.NewReader(testingdata)))
+
+	var created []labels.Labels
+	var runid string
+	err := rt.InitFlow(ctx, id,
+		WithCreateLogwriter(func(string) (io.Writer, error) {
+			return io.Discard, nil
+		}),
+		WithCopyResources(func() resource.Resources {
+			l := make(labels.Labels)
+			created = append(created, l)
+			return resource.Resources{Labels: l}
+		}),
+		WithBeforeFunc(func(_ nameid.ID, s string) error {
+			runid = s
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+	assert.Len(t, created, 1)
+
+	assert.NoError(t, rt.ExecFlow(ctx, id))
+	assert.NotEmpty(t, runid)
+	assert.Equal(t, runid, created[0].GetRunID())
+	assert.Equal(t, id.ID(), created[0].GetFlowID())
+}
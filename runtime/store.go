@@ -33,3 +33,32 @@ func (s *flowstore) get(k string) (*Flow, error) {
 	}
 	return v, nil
 }
+
+// remove removes the flow from flowstore
+func (s *flowstore) remove(k string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.entity, k)
+}
+
+// keys returns the keys of all flows in flowstore
+func (s *flowstore) keys() []string {
+	s.RLock()
+	defer s.RUnlock()
+	var keys []string
+	for k := range s.entity {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// list returns all flows in flowstore
+func (s *flowstore) list() []*Flow {
+	s.RLock()
+	defer s.RUnlock()
+	var flows []*Flow
+	for _, f := range s.entity {
+		flows = append(flows, f)
+	}
+	return flows
+}
@@ -0,0 +1,401 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/runtime"
+	"github.com/skoowoo/cofx/service"
+	"github.com/skoowoo/cofx/service/exported"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerFlow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	path := filepath.Join(home, "manual.flowl")
+	err := os.WriteFile(path, []byte(`
+load "go:event_cron"
+load "go:print"
+
+var ev
+
+event {
+	co event_cron -> ev {
+		"expr": "0 0 1 1 *"
+	}
+}
+co print {
+	"_": "manual $(ev.k)"
+}
+`), 0644)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	socket := filepath.Join(home, "cofx.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	server := NewServer(svc)
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	_, err = Listen(socket)
+	assert.ErrorIs(t, err, ErrSocketInUse)
+
+	ctx := context.Background()
+	client := NewClient(socket)
+	assert.Error(t, client.TriggerFlow(ctx, "notfound", nil))
+
+	_, id, err := svc.LookupFlowl(ctx, "manual.flowl")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NoError(t, svc.AddFlow(ctx, id, f))
+	_, err = svc.ReadyFlow(ctx, id, nil)
+	assert.NoError(t, err)
+
+	// The triggers of the flow aren't started
+	err = client.TriggerFlow(ctx, "manual.flowl", nil)
+	assert.ErrorContains(t, err, "triggers not started")
+
+	sub := svc.Subscribe(ctx, id)
+	defer sub.Close()
+	svc.StartEventFlow(ctx, id)
+	assert.Eventually(t, func() bool {
+		return client.TriggerFlow(ctx, "manual.flowl", map[string]string{"k": "v"}) == nil
+	}, time.Second, 10*time.Millisecond)
+	// The data are bound to the variable of the trigger
+	assert.Eventually(t, func() bool {
+		for {
+			select {
+			case ev := <-sub.C():
+				if ev.Type == runtime.BusLogLine && strings.Contains(ev.Line, "manual v") {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = client.DaemonInsight(ctx)
+	assert.ErrorIs(t, err, ErrNotDaemon)
+
+	// No process is serving after the server is shutdown
+	assert.NoError(t, server.Shutdown(context.Background()))
+	err = NewClient(socket).TriggerFlow(ctx, "manual.flowl", nil)
+	assert.ErrorIs(t, err, ErrNotServing)
+}
+
+func TestDaemonInsight(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	socket := filepath.Join(home, "cofx.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	insight := exported.DaemonInsight{
+		Pid:   100,
+		Flows: []exported.DaemonFlowInsight{{Name: "build", Status: "running", Runs: 2}},
+	}
+	server := NewServer(svc, WithDaemon(func() exported.DaemonInsight {
+		return insight
+	}))
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	di, err := NewClient(socket).DaemonInsight(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, insight.Flows, di.Flows)
+	assert.Equal(t, 100, di.Pid)
+}
+
+func TestFlowLifecycle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	if err := config.Init(); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := os.WriteFile(filepath.Join(config.PrivateFlowlDir(), "hello.flowl"), []byte(`
+// Say hello
+load "go:print"
+
+co print {
+	"_": "hello"
+}
+`), 0644)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	socket := filepath.Join(home, "cofx.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	server := NewServer(svc)
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	ctx := context.Background()
+	client := NewClient(socket)
+
+	flows, err := client.ListFlows(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, flows, 1) {
+		assert.Equal(t, "hello", flows[0].Name)
+	}
+	meta, err := client.GetFlow(ctx, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "Say hello", meta.Desc)
+
+	std, err := client.ListStdFunctions(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, std)
+	m, err := client.InspectStdFunction(ctx, "print")
+	assert.NoError(t, err)
+	assert.Equal(t, "print", m.Name)
+	_, err = client.InspectStdFunction(ctx, "notfound")
+	assert.Error(t, err)
+	drivers, err := client.ListDrivers(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, drivers)
+
+	// add -> ready -> start
+	insight, err := client.AddFlow(ctx, "hello", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "ADDED", insight.Status)
+	assert.Error(t, client.StartFlow(ctx, "hello"))
+	insight, err = client.ReadyFlow(ctx, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "READY", insight.Status)
+	seq := insight.Nodes[0].Seq
+	assert.NoError(t, client.StartFlow(ctx, "hello"))
+
+	var history []exported.FlowRunRecord
+	assert.Eventually(t, func() bool {
+		history, err = client.History(ctx, "hello")
+		return err == nil && len(history) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "success", history[0].Status)
+	assert.Equal(t, "hello", history[0].Name)
+
+	insight, err = client.InsightFlow(ctx, "hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "STOPPED", insight.Status)
+	loaded, err := client.ListLoadedFlows(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, loaded, 1) {
+		assert.Equal(t, "hello", loaded[0].Insight.Name)
+		assert.Equal(t, history[0], *loaded[0].LastRun)
+	}
+	var log bytes.Buffer
+	assert.NoError(t, client.ViewLog(ctx, "hello", seq, false, &log))
+	assert.Contains(t, log.String(), "hello")
+
+	assert.NoError(t, client.DeleteFlow(ctx, "hello"))
+	_, err = client.InsightFlow(ctx, "hello")
+	assert.Error(t, err)
+}
+
+func TestFollowLog(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	socket := filepath.Join(home, "cofx.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	server := NewServer(svc)
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	ctx := context.Background()
+	client := NewClient(socket)
+	// The source is sent by the client
+	_, err = client.AddFlow(ctx, "tick.flowl", `
+load "go:event_tick"
+load "go:print"
+
+event {
+	co event_tick {
+		"duration": "20ms"
+	}
+}
+co print {
+	"_": "tick"
+}
+`)
+	assert.NoError(t, err)
+	insight, err := client.ReadyFlow(ctx, "tick.flowl")
+	assert.NoError(t, err)
+	assert.NoError(t, client.StartFlow(ctx, "tick.flowl"))
+
+	var log bytes.Buffer
+	followCtx, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, client.ViewLog(followCtx, "tick.flowl", insight.Nodes[0].Seq, true, &log))
+	assert.Greater(t, strings.Count(log.String(), "tick\n"), 1)
+
+	assert.NoError(t, client.CancelFlow(ctx, "tick.flowl"))
+}
+
+func TestTokenAuth(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	_, err := ListenTCP("127.0.0.1:0", "")
+	assert.ErrorIs(t, err, ErrTokenRequired)
+	l, err := ListenTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	server := NewServer(svc, WithToken("secret"))
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	ctx := context.Background()
+	_, err = NewTCPClient(l.Addr().String()).ListFlows(ctx)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = NewTCPClient(l.Addr().String(), WithClientToken("wrong")).ListFlows(ctx)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = NewTCPClient(l.Addr().String(), WithClientToken("secret")).ListFlows(ctx)
+	assert.NoError(t, err)
+
+	// The browsers carry the token by the cookie
+	req, _ := http.NewRequest(http.MethodGet, "http://"+l.Addr().String()+"/v1/flows", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookie, Value: "secret"})
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	l, err := ListenTCP("127.0.0.1:0", "secret")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	pages := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "page "+r.URL.Path)
+	})
+	server := NewServer(svc, WithToken("secret"), WithDashboard(pages))
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	// The pages are served without the token, but the api isn't
+	for path, code := range map[string]int{"/": http.StatusOK, "/app.js": http.StatusOK, "/v1/flows": http.StatusUnauthorized} {
+		resp, err := http.Get("http://" + l.Addr().String() + path)
+		if assert.NoError(t, err) {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, code, resp.StatusCode, path)
+			if code == http.StatusOK {
+				assert.Equal(t, "page "+path, string(body))
+			}
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+
+	socket := filepath.Join(home, "cofx.sock")
+	l, err := Listen(socket)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	server := NewServer(svc)
+	go server.Serve(l)
+	defer server.Shutdown(context.Background())
+
+	ctx := context.Background()
+	client := NewClient(socket)
+	_, err = client.AddFlow(ctx, "hello.flowl", `
+load "go:print"
+
+co print {
+	"_": "hello"
+}
+`)
+	assert.NoError(t, err)
+	_, err = client.ReadyFlow(ctx, "hello.flowl")
+	assert.NoError(t, err)
+	assert.Error(t, client.Events(ctx, "notfound", func(Event) error { return nil }))
+
+	// The events are received until the flow is stopped
+	evctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	done := make(chan []Event)
+	go func() {
+		var events []Event
+		client.Events(evctx, "hello.flowl", func(ev Event) error {
+			events = append(events, ev)
+			if ev.Type == "FLOW_STOPPED" {
+				return io.EOF
+			}
+			return nil
+		})
+		done <- events
+	}()
+	// Wait for the subscription before starting the flow
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, client.StartFlow(ctx, "hello.flowl"))
+
+	var types []string
+	for _, ev := range <-done {
+		assert.Equal(t, "hello", ev.FlowName)
+		types = append(types, ev.Type)
+	}
+	assert.Equal(t, []string{"FLOW_STARTED", "NODE_RUNNING", "LOG_LINE", "NODE_STOPPED", "FLOW_STOPPED"}, types)
+
+	// The history keeps the timeline of the nodes
+	assert.Eventually(t, func() bool {
+		history, err := client.History(ctx, "hello.flowl")
+		return err == nil && len(history) == 1
+	}, time.Second, 10*time.Millisecond)
+	history, _ := client.History(ctx, "hello.flowl")
+	if assert.Len(t, history[0].Nodes, 1) {
+		node := history[0].Nodes[0]
+		assert.Equal(t, "print", node.Name)
+		assert.False(t, node.Begin.Before(history[0].Begin))
+	}
+}
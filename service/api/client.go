@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/skoowoo/cofx/service/exported"
+)
+
+// Client talks to the cofx process serving the api on the unix socket or tcp.
+type Client struct {
+	http  *http.Client
+	base  string
+	token string
+}
+
+type ClientOption func(*Client)
+
+// WithClientToken sends the token with every request, it's required by the api served on tcp.
+func WithClientToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+func NewClient(socket string, opts ...ClientOption) *Client {
+	dialer := &net.Dialer{}
+	c := &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		// The host is ignored, the connection is always dialed to the unix socket.
+		base: "http://cofx",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewTCPClient creates a client talking to the api served on the tcp address, e.g. 127.0.0.1:8089.
+func NewTCPClient(addr string, opts ...ClientOption) *Client {
+	c := &Client{
+		http: &http.Client{},
+		base: "http://" + addr,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ListFlows returns the flows in the flow source directories of the serving process.
+func (c *Client) ListFlows(ctx context.Context) ([]exported.FlowMetaInsight, error) {
+	var flows []exported.FlowMetaInsight
+	err := c.do(ctx, http.MethodGet, "/flows", nil, &flows)
+	return flows, err
+}
+
+// ListLoadedFlows returns the state of the flows loaded in the runtime of the serving process.
+func (c *Client) ListLoadedFlows(ctx context.Context) ([]exported.FlowProcessInsight, error) {
+	var flows []exported.FlowProcessInsight
+	err := c.do(ctx, http.MethodGet, "/runtime/flows", nil, &flows)
+	return flows, err
+}
+
+// GetFlow returns the meta of the flow.
+func (c *Client) GetFlow(ctx context.Context, nameorid string) (exported.FlowMetaInsight, error) {
+	var meta exported.FlowMetaInsight
+	err := c.do(ctx, http.MethodGet, flowPath(nameorid, ""), nil, &meta)
+	return meta, err
+}
+
+// AddFlow parses the flow and adds it into the runtime of the serving process, the flowl source file is read by
+// the serving process if the 'source' is empty.
+func (c *Client) AddFlow(ctx context.Context, nameorid, source string) (exported.FlowRunningInsight, error) {
+	var insight exported.FlowRunningInsight
+	err := c.do(ctx, http.MethodPost, flowPath(nameorid, "add"), AddRequest{Source: source}, &insight)
+	return insight, err
+}
+
+// ReadyFlow makes the added flow ready to run, the outputs of the flow are written into the log files.
+func (c *Client) ReadyFlow(ctx context.Context, nameorid string) (exported.FlowRunningInsight, error) {
+	var insight exported.FlowRunningInsight
+	err := c.do(ctx, http.MethodPost, flowPath(nameorid, "ready"), nil, &insight)
+	return insight, err
+}
+
+// StartFlow runs the ready flow, or starts its triggers if it's an event flow, it returns without waiting
+// for the flow to finish.
+func (c *Client) StartFlow(ctx context.Context, nameorid string) error {
+	return c.do(ctx, http.MethodPost, flowPath(nameorid, "start"), nil, nil)
+}
+
+// CancelFlow cancels the running flow.
+func (c *Client) CancelFlow(ctx context.Context, nameorid string) error {
+	return c.do(ctx, http.MethodPost, flowPath(nameorid, "cancel"), nil, nil)
+}
+
+// DeleteFlow cancels the flow and unloads it from the runtime of the serving process.
+func (c *Client) DeleteFlow(ctx context.Context, nameorid string) error {
+	return c.do(ctx, http.MethodDelete, flowPath(nameorid, ""), nil, nil)
+}
+
+// InsightFlow returns the running insight of the flow.
+func (c *Client) InsightFlow(ctx context.Context, nameorid string) (exported.FlowRunningInsight, error) {
+	var insight exported.FlowRunningInsight
+	err := c.do(ctx, http.MethodGet, flowPath(nameorid, "insight"), nil, &insight)
+	return insight, err
+}
+
+// History returns the last runnings of the flow, the latest one is the first.
+func (c *Client) History(ctx context.Context, nameorid string) ([]exported.FlowRunRecord, error) {
+	var history []exported.FlowRunRecord
+	err := c.do(ctx, http.MethodGet, flowPath(nameorid, "history"), nil, &history)
+	return history, err
+}
+
+// TriggerFlow sends an event with the 'data' to the flow, the flow must be hosted by the serving process and
+// its event triggers must be started.
+func (c *Client) TriggerFlow(ctx context.Context, nameorid string, data map[string]string) error {
+	return c.do(ctx, http.MethodPost, flowPath(nameorid, "trigger"), TriggerRequest{Data: data}, nil)
+}
+
+// ViewLog writes the log of the function into 'w', if 'follow' is true, the new lines of the log are written
+// until the 'ctx' is done.
+func (c *Client) ViewLog(ctx context.Context, nameorid string, seq int, follow bool, w io.Writer) error {
+	path := fmt.Sprintf("%s/%d", flowPath(nameorid, "logs"), seq)
+	if follow {
+		path += "?follow=true"
+	}
+	resp, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// Events receives the events of the flows until the 'ctx' is done or 'fn' returns an error, only the events of
+// the flow are received if 'nameorid' isn't empty.
+func (c *Client) Events(ctx context.Context, nameorid string, fn func(Event) error) error {
+	path := "/events"
+	if nameorid != "" {
+		path += "?flow=" + url.QueryEscape(nameorid)
+	}
+	resp, err := c.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == scanner.Text() {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// ListStdFunctions returns the standard functions.
+func (c *Client) ListStdFunctions(ctx context.Context) ([]exported.ListStdFunctions, error) {
+	var list []exported.ListStdFunctions
+	err := c.do(ctx, http.MethodGet, "/std", nil, &list)
+	return list, err
+}
+
+// InspectStdFunction returns the manifest of the standard function, the name can be followed by a version
+// constraint, e.g. 'print@1.0'.
+func (c *Client) InspectStdFunction(ctx context.Context, name string) (exported.InspectStdFunction, error) {
+	var m exported.InspectStdFunction
+	err := c.do(ctx, http.MethodGet, "/std/"+url.PathEscape(name), nil, &m)
+	return m, err
+}
+
+// ListDrivers returns the function drivers.
+func (c *Client) ListDrivers(ctx context.Context) ([]exported.ListDrivers, error) {
+	var list []exported.ListDrivers
+	err := c.do(ctx, http.MethodGet, "/drivers", nil, &list)
+	return list, err
+}
+
+// DaemonInsight returns the state of the daemon, it returns ErrNotDaemon if the serving process isn't a daemon.
+func (c *Client) DaemonInsight(ctx context.Context) (exported.DaemonInsight, error) {
+	var di exported.DaemonInsight
+	err := c.do(ctx, http.MethodGet, "/daemon", nil, &di)
+	return di, err
+}
+
+// do sends the request with the json body, the response is decoded into 'out' if it's not nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request sends the request to the path under the version, the error response is converted into an error.
+func (c *Client) request(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+"/"+Version+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, fmt.Errorf("%w: %s", ErrNotServing, opErr.Err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e exported.SimpleError
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	// The sentinel errors of the api are kept, so they can be checked by errors.Is.
+	for _, sentinel := range []error{ErrNotDaemon, ErrUnauthorized} {
+		if e.Error == sentinel.Error() {
+			return nil, sentinel
+		}
+	}
+	return nil, errors.New(strings.TrimSpace(e.Error))
+}
+
+func flowPath(nameorid, action string) string {
+	path := "/flows/" + url.PathEscape(nameorid)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
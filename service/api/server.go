@@ -0,0 +1,544 @@
+// Package api exposes the service layer by http/json on a unix socket, the cofx process hosting the event
+// flows serves it, and the other cofx commands talk to the process by the Client. The api can be served on
+// tcp too, the requests on tcp must carry the token.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/runtime"
+	"github.com/skoowoo/cofx/service"
+	"github.com/skoowoo/cofx/service/exported"
+)
+
+// Version is the version of the api, it's the prefix of all paths, e.g. /v1/flows/helloworld/trigger
+const Version = "v1"
+
+var (
+	ErrSocketInUse   = errors.New("socket in use")
+	ErrNotServing    = errors.New("no cofx process is serving")
+	ErrNotDaemon     = errors.New("the serving process isn't a daemon")
+	ErrTokenRequired = errors.New("token required")
+	ErrUnauthorized  = errors.New("unauthorized")
+)
+
+// TriggerRequest is the body of the request to trigger a flow.
+type TriggerRequest struct {
+	Data map[string]string `json:"data"`
+}
+
+// Event is a state change of a flow in the runtime, it's streamed by the server-sent events of /v1/events.
+type Event struct {
+	Type     string    `json:"type"`
+	FlowID   string    `json:"flow_id"`
+	FlowName string    `json:"flow_name"`
+	Serial   uint64    `json:"serial"`
+	Time     time.Time `json:"time"`
+	RunID    string    `json:"run_id"`
+	Seq      int       `json:"seq"`
+	Node     string    `json:"node"`
+	Runs     int       `json:"runs"`
+	Error    string    `json:"error"`
+	Line     string    `json:"line"`
+}
+
+// TokenCookie is the cookie carrying the token, it's used by the browsers which can't set the header of the
+// server-sent events.
+const TokenCookie = "cofx_token"
+
+// AddRequest is the body of the request to add a flow, the flowl source file of the flow is read by the
+// serving process if the source is empty.
+type AddRequest struct {
+	Source string `json:"source"`
+}
+
+// Server serves the api of the service on a listener.
+type Server struct {
+	svc    *service.SVC
+	server *http.Server
+	// daemon exports the state of the daemon, it's nil if the serving process isn't a daemon.
+	daemon func() exported.DaemonInsight
+	// token authenticates the requests, the requests aren't authenticated if it's empty.
+	token string
+	// dashboard serves the requests out of the api, e.g. the pages of the web dashboard.
+	dashboard http.Handler
+}
+
+type ServerOption func(*Server)
+
+// WithDaemon serves the state of the daemon by the insight function.
+func WithDaemon(insight func() exported.DaemonInsight) ServerOption {
+	return func(s *Server) {
+		s.daemon = insight
+	}
+}
+
+// WithToken requires the requests to carry the token by the header 'Authorization: Bearer <token>', or by the
+// cookie TokenCookie for the browsers.
+func WithToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithDashboard serves the web dashboard by the handler, it receives all requests out of the api, they
+// aren't authenticated because the dashboard only has the static assets.
+func WithDashboard(h http.Handler) ServerOption {
+	return func(s *Server) {
+		s.dashboard = h
+	}
+}
+
+func NewServer(svc *service.SVC, opts ...ServerOption) *Server {
+	s := &Server{svc: svc}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.server = &http.Server{Handler: s}
+	return s
+}
+
+// Listen listens on the unix socket, the socket file left by an exited process is removed, but it returns
+// ErrSocketInUse if another process is serving on the socket.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// ListenTCP listens on the tcp address, the token is required because the address can be accessed by the
+// other users.
+func ListenTCP(addr, token string) (net.Listener, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: serve the api on %s", ErrTokenRequired, addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Serve serves the api on the listener until Shutdown is invoked.
+func (s *Server) Serve(l net.Listener) error {
+	err := s.server.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops the server gracefully, the unix socket file is removed by closing the listener.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// ServeHTTP dispatches the requests by the path, the path is /<version>/<resource>/<name or id>/<action>.
+//
+//	GET    /v1/flows                       list the flows in the flow source directories
+//	GET    /v1/flows/<flow>                the meta of the flow
+//	DELETE /v1/flows/<flow>                cancel the flow and unload it
+//	POST   /v1/flows/<flow>/add            parse the flow and add it into the runtime
+//	POST   /v1/flows/<flow>/ready          make the added flow ready to run
+//	POST   /v1/flows/<flow>/start          run the flow, or start its triggers if it's an event flow
+//	POST   /v1/flows/<flow>/cancel         cancel the running flow
+//	POST   /v1/flows/<flow>/trigger        send an event to the event flow
+//	GET    /v1/flows/<flow>/insight        the running insight of the flow
+//	GET    /v1/flows/<flow>/history        the last runnings of the flow
+//	GET    /v1/flows/<flow>/logs/<seq>     the log of the function, ?follow=true streams the new lines
+//	GET    /v1/runtime/flows               list the flows loaded in the runtime
+//	GET    /v1/std                         list the standard functions
+//	GET    /v1/std/<name[@version]>        the manifest of the standard function
+//	GET    /v1/drivers                     list the function drivers
+//	GET    /v1/daemon                      the state of the daemon
+//	GET    /v1/events                      stream the events of the flows, ?flow=<flow> selects a flow
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.dashboard != nil && r.URL.Path != "/"+Version && !strings.HasPrefix(r.URL.Path, "/"+Version+"/") {
+		s.dashboard.ServeHTTP(w, r)
+		return
+	}
+	if !s.authenticate(r) {
+		writeError(w, http.StatusUnauthorized, ErrUnauthorized)
+		return
+	}
+	// The name of the flow can contain '/', so the escaped path is split.
+	parts := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, p := range parts {
+		if v, err := url.PathUnescape(p); err == nil {
+			parts[i] = v
+		}
+	}
+	if len(parts) < 2 || parts[0] != Version {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+		return
+	}
+	parts = parts[1:]
+	switch {
+	case len(parts) == 1 && parts[0] == "daemon":
+		if allow(w, r, http.MethodGet) {
+			s.daemonInsight(w, r)
+		}
+	case len(parts) == 1 && parts[0] == "events":
+		if allow(w, r, http.MethodGet) {
+			s.streamEvents(w, r)
+		}
+	case len(parts) == 1 && parts[0] == "std":
+		if allow(w, r, http.MethodGet) {
+			writeJson(w, http.StatusOK, s.svc.ListStdFunctions(r.Context()))
+		}
+	case len(parts) == 2 && parts[0] == "std":
+		if allow(w, r, http.MethodGet) {
+			s.inspectStd(w, r, parts[1])
+		}
+	case len(parts) == 1 && parts[0] == "drivers":
+		if allow(w, r, http.MethodGet) {
+			writeJson(w, http.StatusOK, s.svc.ListDrivers(r.Context()))
+		}
+	case len(parts) == 1 && parts[0] == "flows":
+		if allow(w, r, http.MethodGet) {
+			writeJson(w, http.StatusOK, s.svc.ListAvailables(r.Context()))
+		}
+	case len(parts) == 2 && parts[0] == "runtime" && parts[1] == "flows":
+		if allow(w, r, http.MethodGet) {
+			writeJson(w, http.StatusOK, s.svc.ListLoadedFlows(r.Context()))
+		}
+	case len(parts) >= 2 && parts[0] == "flows":
+		s.serveFlow(w, r, nameid.NameOrID(parts[1]), parts[2:])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+	}
+}
+
+func (s *Server) serveFlow(w http.ResponseWriter, r *http.Request, nameorid nameid.NameOrID, parts []string) {
+	path, id, err := s.svc.LookupFlowl(r.Context(), nameorid)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var action string
+	if len(parts) != 0 {
+		action = parts[0]
+	}
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodDelete:
+		if err := s.svc.DeleteFlow(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, http.StatusOK, exported.SimpleSucceed{Message: "deleted: flow " + id.String()})
+	case len(parts) == 0:
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		meta, err := s.svc.GetAvailableMeta(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, http.StatusOK, meta)
+	case len(parts) == 1 && action == "add":
+		if allow(w, r, http.MethodPost) {
+			s.addFlow(w, r, path, id)
+		}
+	case len(parts) == 1 && action == "ready":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		// The outputs of the flow are written into the log files.
+		insight, err := s.svc.ReadyFlow(r.Context(), id, nil)
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJson(w, http.StatusOK, insight)
+	case len(parts) == 1 && action == "start":
+		if allow(w, r, http.MethodPost) {
+			s.startFlow(w, r, id)
+		}
+	case len(parts) == 1 && action == "cancel":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		if err := s.svc.CancelRunningFlow(r.Context(), id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, http.StatusOK, exported.SimpleSucceed{Message: "canceled: flow " + id.String()})
+	case len(parts) == 1 && action == "trigger":
+		if allow(w, r, http.MethodPost) {
+			s.triggerFlow(w, r, id)
+		}
+	case len(parts) == 1 && action == "insight":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		insight, err := s.svc.InsightFlow(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJson(w, http.StatusOK, insight)
+	case len(parts) == 1 && action == "history":
+		if allow(w, r, http.MethodGet) {
+			writeJson(w, http.StatusOK, s.svc.History(r.Context(), id))
+		}
+	case len(parts) == 2 && action == "logs":
+		if allow(w, r, http.MethodGet) {
+			s.viewLog(w, r, id, parts[1])
+		}
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: %s", r.URL.Path))
+	}
+}
+
+func (s *Server) addFlow(w http.ResponseWriter, r *http.Request, path string, id nameid.ID) {
+	var req AddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var rd io.ReadCloser
+	if req.Source != "" {
+		rd = io.NopCloser(strings.NewReader(req.Source))
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		rd = f
+	}
+	if err := s.svc.AddFlow(r.Context(), id, rd); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	insight, err := s.svc.InsightFlow(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJson(w, http.StatusCreated, insight)
+}
+
+func (s *Server) startFlow(w http.ResponseWriter, r *http.Request, id nameid.ID) {
+	insight, err := s.svc.InsightFlow(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if insight.Status != string(runtime.StatusReady) {
+		writeError(w, http.StatusConflict, fmt.Errorf("not ready: flow %s", id))
+		return
+	}
+	// The flow keeps running after the request, its state is watched by the insight.
+	go s.svc.StartFlowOrEventFlow(context.Background(), id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	exported.SimpleSucceed{Message: "started: flow " + id.String()}.JsonWrite(w)
+}
+
+func (s *Server) triggerFlow(w http.ResponseWriter, r *http.Request, id nameid.ID) {
+	var req TriggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := s.svc.TriggerFlow(r.Context(), id, req.Data); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, runtime.ErrTriggersNotStarted) {
+			code = http.StatusConflict
+		}
+		writeError(w, code, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	exported.SimpleSucceed{Message: "triggered: flow " + id.String()}.JsonWrite(w)
+}
+
+func (s *Server) viewLog(w http.ResponseWriter, r *http.Request, id nameid.ID, seqstr string) {
+	seq, err := strconv.Atoi(seqstr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: seq '%s'", err, seqstr))
+		return
+	}
+	if follow, _ := strconv.ParseBool(r.URL.Query().Get("follow")); !follow {
+		var buf strings.Builder
+		if err := s.svc.ViewLog(r.Context(), id, seq, &buf); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, buf.String())
+		return
+	}
+	if _, err := s.svc.InsightFlow(r.Context(), id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	// The lines are streamed until the client closes the connection, the header is flushed first so the client
+	// doesn't wait for the first line.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fw := &flushWriter{w: w}
+	if f, ok := w.(http.Flusher); ok {
+		fw.f = f
+		f.Flush()
+	}
+	s.svc.FollowLog(r.Context(), id, seq, fw)
+}
+
+// streamEvents streams the events of the flows as the server-sent events until the client closes the
+// connection, every event is a json object of Event.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	var ids []nameid.ID
+	if nameorid := r.URL.Query().Get("flow"); nameorid != "" {
+		_, id, err := s.svc.LookupFlowl(r.Context(), nameid.NameOrID(nameorid))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	sub := s.svc.Subscribe(r.Context(), ids...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f, _ := w.(http.Flusher)
+	if f != nil {
+		f.Flush()
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(Event{
+				Type:     string(ev.Type),
+				FlowID:   ev.FlowID,
+				FlowName: ev.FlowName,
+				Serial:   ev.Serial,
+				Time:     ev.Time,
+				RunID:    ev.RunID,
+				Seq:      ev.Seq,
+				Node:     ev.Node,
+				Runs:     ev.Runs,
+				Error:    exportedError(ev.Err),
+				Line:     ev.Line,
+			})
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			if f != nil {
+				f.Flush()
+			}
+		}
+	}
+}
+
+func (s *Server) inspectStd(w http.ResponseWriter, r *http.Request, name string) {
+	m := s.svc.InspectStdFunction(r.Context(), name)
+	if m.Name == "" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found: function '%s'", name))
+		return
+	}
+	writeJson(w, http.StatusOK, m)
+}
+
+func (s *Server) daemonInsight(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil {
+		writeError(w, http.StatusNotFound, ErrNotDaemon)
+		return
+	}
+	writeJson(w, http.StatusOK, s.daemon())
+}
+
+func (s *Server) authenticate(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		if c, err := r.Cookie(TokenCookie); err == nil {
+			token, _ = url.QueryUnescape(c.Value)
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// allow checks the method of the request, it writes the error and returns false if the method isn't allowed.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+	return false
+}
+
+// flushWriter flushes every write to the client, so the streamed lines arrive without delay.
+type flushWriter struct {
+	w io.Writer
+	f http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.f != nil {
+		fw.f.Flush()
+	}
+	return n, err
+}
+
+func exportedError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	exported.SimpleError{Error: err.Error()}.JsonWrite(w)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+
+	"github.com/skoowoo/cofx/service/exported"
+)
+
+// ListCache returns all entries of the cached return values, including the expired ones.
+func (s *SVC) ListCache(ctx context.Context) ([]exported.ListCache, error) {
+	entries, err := s.cache.List()
+	if err != nil {
+		return nil, err
+	}
+	var list []exported.ListCache
+	for _, e := range entries {
+		list = append(list, exported.ListCache{
+			Key:      e.Key,
+			Function: e.Function,
+			Created:  e.Created,
+			Expires:  e.Expires,
+			Expired:  e.Expired(),
+			Returns:  len(e.Returns),
+		})
+	}
+	return list, nil
+}
+
+// ClearCache removes the cached return values of the function, e.g. go:http_get or go:http_get@1.0.0, all
+// entries are removed if the function is empty. Only the expired entries are removed if expired is true.
+func (s *SVC) ClearCache(ctx context.Context, function string, expired bool) (int, error) {
+	return s.cache.Clear(function, expired)
+}
@@ -0,0 +1,308 @@
+// Package daemon hosts the event flows in a long-running process, it starts the triggers of the selected
+// flows, keeps them running when a flow fails, and writes the structured logs of the flows.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/runtime"
+	"github.com/skoowoo/cofx/service"
+	"github.com/skoowoo/cofx/service/exported"
+)
+
+// The status of the hosted flows.
+const (
+	StatusStarting = "starting"
+	StatusRunning  = "running"
+	StatusStopped  = "stopped"
+)
+
+var (
+	ErrNoFlowSelected = errors.New("no flow selected")
+	ErrNotEventFlow   = errors.New("not an event flow")
+)
+
+// Config is the config file of the daemon, the flows are selected by the names or ids and the tags, e.g.
+// {"flows": ["go-auto-build"], "tags": ["ci"]}
+type Config struct {
+	Flows []string `json:"flows"`
+	Tags  []string `json:"tags"`
+}
+
+// LoadConfig reads the config file, an empty config is returned if the file doesn't exist.
+func LoadConfig(path string) (Config, error) {
+	var c Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%w: parse config '%s'", err, path)
+	}
+	return c, nil
+}
+
+type hostedFlow struct {
+	id       nameid.ID
+	status   string
+	runs     int
+	failures int
+	lastRun  time.Time
+	lastErr  error
+}
+
+// Daemon hosts the event flows by the service.
+type Daemon struct {
+	sync.Mutex
+	svc     *service.SVC
+	log     *logger
+	started time.Time
+	flows   map[string]*hostedFlow
+	wg      sync.WaitGroup
+}
+
+// New creates a daemon, the structured logs are written to 'logw'.
+func New(svc *service.SVC, logw io.Writer) *Daemon {
+	return &Daemon{
+		svc:     svc,
+		log:     newLogger(logw),
+		started: time.Now(),
+		flows:   make(map[string]*hostedFlow),
+	}
+}
+
+// Select returns the flows selected by the config, the flows in the flow source directories are selected by
+// the names or ids, and all flows having one of the tags are selected too.
+func (d *Daemon) Select(ctx context.Context, c Config) ([]nameid.ID, error) {
+	var (
+		ids  []nameid.ID
+		seen = make(map[string]bool)
+	)
+	add := func(id nameid.ID) {
+		if !seen[id.ID()] {
+			seen[id.ID()] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, nameorid := range c.Flows {
+		id, err := d.svc.LookupID(ctx, nameid.NameOrID(nameorid))
+		if err != nil {
+			return nil, err
+		}
+		add(id)
+	}
+	if len(c.Tags) != 0 {
+		availables := d.svc.ListAvailables(ctx)
+		sort.Slice(availables, func(i, j int) bool { return availables[i].Name < availables[j].Name })
+		for _, meta := range availables {
+			if hasTag(meta.Tags, c.Tags) {
+				add(nameid.Wrap(meta.Name, meta.ID))
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, ErrNoFlowSelected
+	}
+	return ids, nil
+}
+
+// Start loads the flows and starts their triggers, the flow failed to load is logged and skipped, so one
+// broken flow doesn't stop the others. It returns an error only if no flow is started. The triggers are kept
+// running until the 'ctx' is done or the flow is canceled.
+func (d *Daemon) Start(ctx context.Context, ids []nameid.ID) error {
+	sub := d.svc.Subscribe(ctx)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer sub.Close()
+		d.watch(ctx, sub)
+	}()
+
+	var started int
+	for _, id := range ids {
+		if err := d.load(ctx, id); err != nil {
+			d.log.error("load flow failed", "flow", id.Name(), "flow_id", id.ID(), "error", err)
+			continue
+		}
+		hf := &hostedFlow{id: id, status: StatusStarting}
+		d.Lock()
+		d.flows[id.ID()] = hf
+		d.Unlock()
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.keep(ctx, hf)
+		}()
+		started++
+	}
+	if started == 0 {
+		return fmt.Errorf("%w: all flows failed to load", ErrNoFlowSelected)
+	}
+	return nil
+}
+
+// Wait waits for all goroutines of the daemon to exit after the 'ctx' passed to Start is done.
+func (d *Daemon) Wait() {
+	d.wg.Wait()
+}
+
+// Insight exports the state of the daemon and its hosted flows.
+func (d *Daemon) Insight() exported.DaemonInsight {
+	d.Lock()
+	defer d.Unlock()
+	di := exported.DaemonInsight{
+		Pid:     os.Getpid(),
+		Started: d.started,
+	}
+	for _, hf := range d.flows {
+		fi := exported.DaemonFlowInsight{
+			Name:     hf.id.Name(),
+			ID:       hf.id.ID(),
+			Status:   hf.status,
+			Runs:     hf.runs,
+			Failures: hf.failures,
+			LastRun:  hf.lastRun,
+		}
+		if hf.lastErr != nil {
+			fi.LastError = hf.lastErr.Error()
+		}
+		di.Flows = append(di.Flows, fi)
+	}
+	sort.Slice(di.Flows, func(i, j int) bool { return di.Flows[i].Name < di.Flows[j].Name })
+	return di
+}
+
+func (d *Daemon) load(ctx context.Context, id nameid.ID) error {
+	meta, err := d.svc.GetAvailableMeta(ctx, id)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(meta.Source)
+	if err != nil {
+		return err
+	}
+	if err := d.svc.AddFlow(ctx, id, f); err != nil {
+		return err
+	}
+	// The outputs of the flows are written into the log files, they can be viewed by 'cofx log'.
+	if _, err := d.svc.ReadyFlow(ctx, id, nil); err != nil {
+		d.svc.DeleteFlow(ctx, id)
+		return err
+	}
+	if has, err := d.svc.HasTrigger(ctx, id); err != nil || !has {
+		d.svc.DeleteFlow(ctx, id)
+		if err != nil {
+			return err
+		}
+		return ErrNotEventFlow
+	}
+	return nil
+}
+
+// keep starts the triggers of the flow and waits for them to exit. The failed triggers are retried by the
+// runtime with their backoff, so an error here means the triggers can't start at all, e.g. the flow is
+// deleted, and the flow is stopped with it.
+func (d *Daemon) keep(ctx context.Context, hf *hostedFlow) {
+	d.setStatus(hf, StatusRunning, nil)
+	d.log.info("flow triggers started", "flow", hf.id.Name(), "flow_id", hf.id.ID())
+
+	wait := d.svc.StartEventFlow(ctx, hf.id)
+	var err error
+	select {
+	case err = <-wait:
+	case <-ctx.Done():
+		d.svc.CancelRunningFlow(context.Background(), hf.id)
+		// The triggers and the runnings of the flow exit soon after canceled, wait for them, so Wait doesn't
+		// return while they're still running.
+		err = <-wait
+	}
+	d.setStatus(hf, StatusStopped, err)
+	if err != nil {
+		d.log.error("flow triggers exited", "flow", hf.id.Name(), "flow_id", hf.id.ID(), "error", err)
+		return
+	}
+	d.log.info("flow triggers stopped", "flow", hf.id.Name(), "flow_id", hf.id.ID())
+}
+
+func (d *Daemon) setStatus(hf *hostedFlow, status string, err error) {
+	d.Lock()
+	defer d.Unlock()
+	hf.status = status
+	if err != nil {
+		hf.lastErr = err
+	}
+}
+
+// watch writes the logs of the flow events and counts the runnings of the hosted flows.
+func (d *Daemon) watch(ctx context.Context, sub *runtime.Subscription) {
+	for {
+		var ev runtime.BusEvent
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C():
+			if !ok {
+				return
+			}
+			ev = e
+		}
+		d.Lock()
+		hf := d.flows[ev.FlowID]
+		d.Unlock()
+		if hf == nil {
+			continue
+		}
+
+		switch ev.Type {
+		case runtime.BusFlowStarted:
+			d.Lock()
+			hf.runs++
+			hf.lastRun = ev.Time
+			d.Unlock()
+			d.log.info("flow started", "flow", ev.FlowName, "flow_id", ev.FlowID, "run_id", ev.RunID)
+		case runtime.BusFlowStopped:
+			if ev.Err != nil {
+				d.Lock()
+				hf.failures++
+				hf.lastErr = ev.Err
+				d.Unlock()
+				d.log.error("flow failed", "flow", ev.FlowName, "flow_id", ev.FlowID, "run_id", ev.RunID, "error", ev.Err)
+			} else {
+				d.log.info("flow succeeded", "flow", ev.FlowName, "flow_id", ev.FlowID, "run_id", ev.RunID)
+			}
+		case runtime.BusFlowWarning:
+			d.log.warn("flow warning", "flow", ev.FlowName, "flow_id", ev.FlowID, "error", ev.Err)
+		case runtime.BusTriggerFired:
+			d.log.info("trigger fired", "flow", ev.FlowName, "flow_id", ev.FlowID, "trigger", ev.Node, "seq", ev.Seq)
+		case runtime.BusTriggerFailed:
+			d.log.warn("trigger failed", "flow", ev.FlowName, "flow_id", ev.FlowID, "trigger", ev.Node, "seq", ev.Seq,
+				"error", ev.Err)
+		case runtime.BusEventDropped:
+			d.log.info("event dropped", "flow", ev.FlowName, "flow_id", ev.FlowID, "seq", ev.Seq, "reason", ev.Err)
+		}
+	}
+}
+
+func hasTag(tags, want []string) bool {
+	for _, t := range tags {
+		for _, w := range want {
+			if t == w {
+				return true
+			}
+		}
+	}
+	return false
+}
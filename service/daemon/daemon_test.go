@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/service"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the messages of the log records.
+func (b *syncBuffer) records(t *testing.T) []string {
+	b.Lock()
+	defer b.Unlock()
+	var msgs []string
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		msgs = append(msgs, record["msg"].(string))
+	}
+	return msgs
+}
+
+func TestDaemon(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFX_HOME", home)
+	if err := config.Init(); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	flowls := map[string]string{
+		"tick.flowl": `
+// tags: ci
+load "go:event_tick"
+load "go:print"
+
+event {
+	co event_tick {
+		"duration": "20ms"
+	}
+}
+co print
+`,
+		"plain.flowl": `
+// tags: ci
+load "go:print"
+
+co print
+`,
+		"other.flowl": `
+load "go:print"
+
+co print
+`,
+	}
+	for name, source := range flowls {
+		if err := os.WriteFile(filepath.Join(config.PrivateFlowlDir(), name), []byte(source), 0644); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+	cfgPath := filepath.Join(home, "daemon.json")
+	if err := os.WriteFile(cfgPath, []byte(`{"tags": ["ci"]}`), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logw := &syncBuffer{}
+	d := New(svc, logw)
+
+	_, err := d.Select(ctx, Config{})
+	assert.ErrorIs(t, err, ErrNoFlowSelected)
+	_, err = d.Select(ctx, Config{Flows: []string{"notfound"}})
+	assert.Error(t, err)
+
+	cfg, err := LoadConfig(cfgPath)
+	assert.NoError(t, err)
+	ids, err := d.Select(ctx, cfg)
+	assert.NoError(t, err)
+	if assert.Len(t, ids, 2) {
+		assert.Equal(t, "plain", ids[0].Name())
+		assert.Equal(t, "tick", ids[1].Name())
+	}
+
+	// The flow without triggers is skipped, the others are still hosted
+	assert.NoError(t, d.Start(ctx, ids))
+	assert.Eventually(t, func() bool {
+		di := d.Insight()
+		return len(di.Flows) == 1 && di.Flows[0].Status == StatusRunning && di.Flows[0].Runs > 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	d.Wait()
+	di := d.Insight()
+	assert.Equal(t, "tick", di.Flows[0].Name)
+	assert.Equal(t, StatusStopped, di.Flows[0].Status)
+
+	msgs := logw.records(t)
+	assert.Contains(t, msgs, "load flow failed")
+	assert.Contains(t, msgs, "trigger fired")
+	assert.Contains(t, msgs, "flow succeeded")
+	// The triggers have exited when Wait returns
+	assert.Contains(t, msgs, "flow triggers stopped")
+}
+
+func TestLoadConfig(t *testing.T) {
+	c, err := LoadConfig(filepath.Join(t.TempDir(), "notfound.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, c.Flows)
+
+	path := filepath.Join(t.TempDir(), "daemon.json")
+	os.WriteFile(path, []byte(`{"flows": ["build"`), 0644)
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
+
+func TestWriteUnit(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteUnit(&buf, "/opt/my apps/cofx", []string{"daemon", "--tag", "ci"}, map[string]string{
+		"COFX_SOCKET": "/tmp/cofx.sock",
+		"COFX_HOME":   "/home/cofx/.cofx",
+	})
+	assert.NoError(t, err)
+	unit := buf.String()
+	assert.Contains(t, unit, `ExecStart="/opt/my apps/cofx" daemon --tag ci`+"\n")
+	assert.Contains(t, unit, "Environment=COFX_HOME=/home/cofx/.cofx\nEnvironment=COFX_SOCKET=/tmp/cofx.sock\n")
+	assert.True(t, strings.HasSuffix(unit, "WantedBy=default.target\n"))
+}
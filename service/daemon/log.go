@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// The levels of the log records.
+const (
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// logger writes the structured log records, a record is a json object in one line, e.g.
+// {"time":"2022-10-01T10:00:00Z","level":"info","msg":"flow started","flow":"build","flow_id":"..."}
+type logger struct {
+	sync.Mutex
+	enc *json.Encoder
+}
+
+func newLogger(w io.Writer) *logger {
+	return &logger{enc: json.NewEncoder(w)}
+}
+
+// log writes a record, the 'kvs' are the pairs of the key and value of the fields, the error value is
+// written as its message.
+func (l *logger) log(level, msg string, kvs ...interface{}) {
+	record := map[string]interface{}{
+		"time":  time.Now().Format(time.RFC3339Nano),
+		"level": level,
+		"msg":   msg,
+	}
+	for i := 0; i+1 < len(kvs); i += 2 {
+		k, ok := kvs[i].(string)
+		if !ok {
+			continue
+		}
+		v := kvs[i+1]
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		record[k] = v
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.enc.Encode(record)
+}
+
+func (l *logger) info(msg string, kvs ...interface{}) {
+	l.log(LevelInfo, msg, kvs...)
+}
+
+func (l *logger) warn(msg string, kvs ...interface{}) {
+	l.log(LevelWarn, msg, kvs...)
+}
+
+func (l *logger) error(msg string, kvs ...interface{}) {
+	l.log(LevelError, msg, kvs...)
+}
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=cofx daemon hosting the event flows
+After=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{.ExecStart}}
+{{- range .Env}}
+Environment={{.}}
+{{- end}}
+Restart=on-failure
+RestartSec=5s
+
+[Install]
+WantedBy=default.target
+`))
+
+// WriteUnit writes the systemd unit that runs the daemon, 'exe' and 'args' are the command line of the daemon,
+// and 'env' is the environment of the daemon, e.g. COFX_HOME.
+func WriteUnit(w io.Writer, exe string, args []string, env map[string]string) error {
+	cmd := []string{quote(exe)}
+	for _, a := range args {
+		cmd = append(cmd, quote(a))
+	}
+	var envs []string
+	for k, v := range env {
+		envs = append(envs, quote(k+"="+v))
+	}
+	sort.Strings(envs)
+	return unitTemplate.Execute(w, struct {
+		ExecStart string
+		Env       []string
+	}{
+		ExecStart: strings.Join(cmd, " "),
+		Env:       envs,
+	})
+}
+
+// quote quotes the word of the unit file if it has the spaces or quotes.
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t\"'\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,336 @@
+// The dashboard talks to the api under /v1 of the same address, the pages are rendered by the hash:
+//   #/              the flows
+//   #/flows/<id>    the flow, its runnings, the live progress and the logs
+'use strict';
+
+const app = document.getElementById('app');
+const live = document.getElementById('live');
+const login = document.getElementById('login');
+
+// h creates an element, the strings of the children are added as text, so they are never parsed as html.
+function h(tag, attrs, ...children) {
+  const el = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (v === undefined || v === null || v === false) continue;
+    if (k.startsWith('on')) el.addEventListener(k.slice(2), v);
+    else if (k === 'style') Object.assign(el.style, v);
+    else el.setAttribute(k, v);
+  }
+  for (const c of children.flat()) {
+    if (c === undefined || c === null || c === false) continue;
+    el.append(c instanceof Node ? c : String(c));
+  }
+  return el;
+}
+
+async function api(method, path, body) {
+  const opts = { method, credentials: 'same-origin', headers: {} };
+  if (body !== undefined) {
+    opts.headers['Content-Type'] = 'application/json';
+    opts.body = JSON.stringify(body);
+  }
+  const resp = await fetch('/v1' + path, opts);
+  if (resp.status === 401) {
+    showLogin();
+    throw new Error('unauthorized');
+  }
+  const json = (resp.headers.get('Content-Type') || '').includes('json');
+  const data = json ? await resp.json() : await resp.text();
+  if (!resp.ok) {
+    throw new Error(json && data.error ? data.error : resp.status + ' ' + resp.statusText);
+  }
+  return data;
+}
+
+function flowPath(id, action) {
+  return '/flows/' + encodeURIComponent(id) + (action ? '/' + action : '');
+}
+
+// The zero time of go is encoded as 0001-01-01T00:00:00Z.
+function isZero(t) {
+  return !t || t.startsWith('0001-');
+}
+
+function clock(t) {
+  return isZero(t) ? '-' : new Date(t).toLocaleTimeString();
+}
+
+function datetime(t) {
+  return isZero(t) ? '-' : new Date(t).toLocaleString();
+}
+
+function ms(n) {
+  return n >= 1000 ? (n / 1000).toFixed(1) + 's' : n + 'ms';
+}
+
+function nodeStatus(n) {
+  return n.status === 'STOPPED' && n.last_error ? 'failed' : n.status;
+}
+
+function status(s) {
+  return h('span', { class: 'status ' + s }, s);
+}
+
+function showLogin() {
+  app.replaceChildren();
+  login.hidden = false;
+}
+
+login.addEventListener('submit', (e) => {
+  e.preventDefault();
+  const token = document.getElementById('token').value;
+  document.cookie = 'cofx_token=' + encodeURIComponent(token) + '; path=/; SameSite=Strict';
+  login.hidden = true;
+  route();
+});
+
+// The events of the runtime are received by the server-sent events, the page is refreshed by them.
+let source = null;
+
+function subscribe(flow, onevent) {
+  if (source) source.close();
+  source = new EventSource('/v1/events' + (flow ? '?flow=' + encodeURIComponent(flow) : ''));
+  source.onopen = () => { live.textContent = 'live'; live.classList.add('on'); };
+  source.onerror = () => { live.textContent = 'offline'; live.classList.remove('on'); };
+  source.onmessage = (e) => onevent(JSON.parse(e.data));
+}
+
+// debounce merges the calls in a short time into one, the events can come in bursts.
+function debounce(fn, wait) {
+  let timer = null;
+  return () => {
+    clearTimeout(timer);
+    timer = setTimeout(fn, wait);
+  };
+}
+
+async function action(method, path, body) {
+  try {
+    await api(method, path, body);
+  } catch (err) {
+    alert(err.message);
+  }
+}
+
+function actions(id, insight) {
+  const buttons = [];
+  if (insight && insight.triggers_started) {
+    buttons.push(h('button', { onclick: () => action('POST', flowPath(id, 'trigger'), { data: {} }) }, 'Trigger'));
+  }
+  if (insight && (insight.triggers_started || insight.status === 'RUNNING')) {
+    buttons.push(h('button', { class: 'danger', onclick: () => action('POST', flowPath(id, 'cancel')) }, 'Cancel'));
+  }
+  return buttons;
+}
+
+// renderFlows renders the available flows with the state of the loaded ones.
+async function renderFlows() {
+  const [flows, loaded] = await Promise.all([api('GET', '/flows'), api('GET', '/runtime/flows')]);
+  const byID = {};
+  for (const f of loaded || []) byID[f.insight.id] = f;
+  (flows || []).sort((a, b) => a.name.localeCompare(b.name));
+
+  const rows = (flows || []).map((f) => {
+    const p = byID[f.id];
+    const fi = p && p.insight;
+    const last = p && p.last_run;
+    return h('tr', {},
+      h('td', {}, h('a', { href: '#/flows/' + encodeURIComponent(f.id) }, f.name), h('div', { class: 'muted' }, f.desc)),
+      h('td', {}, (f.tags || []).map((t) => h('span', { class: 'tag' }, t))),
+      h('td', {}, fi ? status(fi.triggers_started ? 'SERVING' : fi.status) : h('span', { class: 'muted' }, 'not loaded')),
+      h('td', {}, fi ? fi.active_runs + (fi.queued_events ? ' +' + fi.queued_events + ' queued' : '') : '-'),
+      h('td', {}, p ? clock(p.next_fire) : '-'),
+      h('td', {}, last ? [status(last.status), ' ', clock(last.begin_time)] : '-'),
+      h('td', {}, actions(f.id, fi)));
+  });
+  app.replaceChildren(
+    h('h1', {}, 'Flows'),
+    h('table', {},
+      h('tr', {}, ['Flow', 'Tags', 'Status', 'Active runs', 'Next fire', 'Last result', ''].map((s) => h('th', {}, s))),
+      rows));
+}
+
+// The state of the flow page, it's kept between the refreshes.
+const page = { id: '', run: '', seq: 0 };
+
+async function renderFlow(id) {
+  if (page.id !== id) {
+    Object.assign(page, { id, run: '', seq: 0 });
+  }
+  // The flow may be unloaded or not in the flow source directories, but the token is always required.
+  const optional = (p, v) => p.catch((err) => {
+    if (err.message === 'unauthorized') throw err;
+    return v;
+  });
+  const [meta, insight, history] = await Promise.all([
+    optional(api('GET', flowPath(id)), null),
+    optional(api('GET', flowPath(id, 'insight')), null),
+    optional(api('GET', flowPath(id, 'history')), []),
+  ]);
+  const name = meta ? meta.name : insight ? insight.name : id;
+
+  const children = [
+    h('h1', {}, name, ' ', h('span', { class: 'muted mono' }, id)),
+    meta && meta.desc ? h('p', {}, meta.desc) : null,
+    h('div', {}, actions(id, insight)),
+  ];
+
+  if (insight) {
+    const percent = insight.total ? Math.round((insight.done * 100) / insight.total) : 0;
+    children.push(
+      h('h2', {}, 'Live'),
+      h('div', { class: 'kvs' },
+        h('div', {}, h('span', {}, 'status'), status(insight.triggers_started ? 'SERVING' : insight.status)),
+        h('div', {}, h('span', {}, 'progress'), insight.done + '/' + insight.total),
+        h('div', {}, h('span', {}, 'duration'), ms(insight.duration)),
+        h('div', {}, h('span', {}, 'active runs'), insight.active_runs),
+        h('div', {}, h('span', {}, 'queued events'), insight.queued_events),
+        h('div', {}, h('span', {}, 'dropped events'), insight.dropped_events)),
+      h('div', { class: 'progress' }, h('div', { style: { width: percent + '%' } })),
+      nodeTable(insight.nodes || []));
+    if ((insight.triggers || []).length) {
+      children.push(h('h2', {}, 'Triggers'), triggerTable(insight.triggers));
+    }
+    if (insight.last_error) {
+      children.push(h('p', { class: 'error' }, insight.last_error));
+    }
+  } else {
+    children.push(h('p', { class: 'muted' }, 'The flow isn\'t loaded in the daemon.'));
+  }
+
+  children.push(h('h2', {}, 'Runnings'), historyTable(history || []));
+  children.push(h('h2', {}, 'Log', page.seq ? ' of node ' + page.seq : ''), logView());
+  app.replaceChildren(...children);
+  if (page.seq) loadLog();
+}
+
+function nodeTable(nodes) {
+  return h('table', {},
+    h('tr', {}, ['Seq', 'Step', 'Node', 'Driver', 'Status', 'Runs', 'Duration'].map((s) => h('th', {}, s))),
+    nodes.map((n) => h('tr', {
+      class: 'clickable' + (n.seq === page.seq ? ' selected' : ''),
+      title: 'View the log',
+      onclick: () => { page.seq = n.seq; renderFlow(page.id); },
+    },
+      h('td', { class: 'mono' }, n.seq),
+      h('td', {}, n.step),
+      h('td', {}, n.name + ' ➜ ' + n.function),
+      h('td', {}, n.driver),
+      h('td', {}, status(nodeStatus(n)), n.last_error ? h('div', { class: 'error' }, n.last_error) : null),
+      h('td', {}, n.runs),
+      h('td', {}, ms(n.duration)))));
+}
+
+function triggerTable(triggers) {
+  return h('table', {},
+    h('tr', {}, ['Seq', 'Trigger', 'Status', 'Fires', 'Last fire', 'Next fire', 'Errors'].map((s) => h('th', {}, s))),
+    triggers.map((t) => h('tr', {},
+      h('td', { class: 'mono' }, t.seq),
+      h('td', {}, t.name + ' ➜ ' + t.function),
+      h('td', {}, status(t.status)),
+      h('td', {}, t.fires),
+      h('td', {}, clock(t.last_fire)),
+      h('td', {}, clock(t.next_fire)),
+      h('td', {}, t.consecutive_errors, t.last_error ? h('div', { class: 'error' }, t.last_error) : null))));
+}
+
+// historyTable renders the runnings, the selected one, the latest by default, is expanded to the timeline
+// of its nodes.
+function historyTable(history) {
+  if (!history.length) return h('p', { class: 'muted' }, 'No runnings yet.');
+  const selected = history.find((r) => r.run_id === page.run) || history[0];
+  const rows = [];
+  for (const r of history) {
+    rows.push(h('tr', {
+      class: 'clickable' + (r === selected ? ' selected' : ''),
+      onclick: () => { page.run = r.run_id; renderFlow(page.id); },
+    },
+      h('td', { class: 'mono' }, r.run_id),
+      h('td', {}, status(r.status)),
+      h('td', {}, datetime(r.begin_time)),
+      h('td', {}, ms(r.duration)),
+      h('td', { class: 'error' }, r.error)));
+    if (r === selected) {
+      rows.push(h('tr', {}, h('td', { colspan: 5 }, timeline(r))));
+    }
+  }
+  return h('table', {},
+    h('tr', {}, ['Run', 'Result', 'Begin', 'Duration', 'Error'].map((s) => h('th', {}, s))),
+    rows);
+}
+
+// timeline renders a bar for every node by its begin time and duration in the running.
+function timeline(run) {
+  const begin = new Date(run.begin_time).getTime();
+  const total = Math.max(run.duration, 1);
+  const nodes = (run.nodes || []).filter((n) => !isZero(n.begin_time) && new Date(n.begin_time).getTime() >= begin);
+  if (!nodes.length) return h('span', { class: 'muted' }, 'No nodes ran.');
+  return h('table', {}, nodes.map((n) => {
+    const offset = Math.min(100, ((new Date(n.begin_time).getTime() - begin) * 100) / total);
+    const width = Math.min(100 - offset, (n.duration * 100) / total);
+    return h('tr', {},
+      h('td', { style: { width: '30%' } }, h('span', { class: 'mono' }, n.seq), ' ', n.name),
+      h('td', {}, h('div', { class: 'timeline' },
+        h('div', {
+          class: 'bar' + (n.last_error ? ' failed' : ''),
+          style: { left: offset + '%', width: width + '%' },
+          title: ms(n.duration) + (n.last_error ? ': ' + n.last_error : ''),
+        }))),
+      h('td', { style: { width: '10%' } }, ms(n.duration)));
+  }));
+}
+
+function logView() {
+  if (!page.seq) return h('p', { class: 'muted' }, 'Select a node to view its log.');
+  return h('pre', { id: 'log', class: 'log' });
+}
+
+async function loadLog() {
+  const pre = document.getElementById('log');
+  try {
+    pre.textContent = await api('GET', flowPath(page.id, 'logs') + '/' + page.seq);
+  } catch (err) {
+    pre.textContent = err.message;
+  }
+  pre.scrollTop = pre.scrollHeight;
+}
+
+// appendLog appends the log line streamed by the events of the selected node.
+function appendLog(ev) {
+  const pre = document.getElementById('log');
+  if (!pre || ev.seq !== page.seq) return;
+  pre.append(ev.line + '\n');
+  pre.scrollTop = pre.scrollHeight;
+}
+
+async function route() {
+  const m = location.hash.match(/^#\/flows\/(.+)$/);
+  try {
+    if (m) {
+      const id = decodeURIComponent(m[1]);
+      const refresh = debounce(() => renderFlow(id).catch(showError), 300);
+      subscribe(id, (ev) => {
+        if (ev.type === 'LOG_LINE') appendLog(ev);
+        else refresh();
+      });
+      await renderFlow(id);
+    } else {
+      const refresh = debounce(() => renderFlows().catch(showError), 300);
+      subscribe('', (ev) => {
+        if (ev.type !== 'LOG_LINE') refresh();
+      });
+      await renderFlows();
+    }
+  } catch (err) {
+    showError(err);
+  }
+}
+
+function showError(err) {
+  if (err.message !== 'unauthorized') {
+    app.replaceChildren(h('p', { class: 'error' }, err.message));
+  }
+}
+
+window.addEventListener('hashchange', route);
+route();
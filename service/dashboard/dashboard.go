@@ -0,0 +1,22 @@
+// Package dashboard is the web dashboard of the flows, it lists the flows, the runnings with the timeline
+// of the nodes, the live progress and the logs, and triggers or cancels the flows. The static assets are
+// embedded and talk to the api under /v1 of the same address, so it works offline.
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Handler serves the static assets of the dashboard, it's served by the api server with api.WithDashboard.
+func Handler() http.Handler {
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(sub))
+}
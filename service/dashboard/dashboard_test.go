@@ -0,0 +1,46 @@
+package dashboard
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	for path, contains := range map[string]string{
+		"/":          `<script src="app.js">`,
+		"/app.js":    "new EventSource('/v1/events'",
+		"/style.css": ".timeline",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Contains(t, string(body), contains, path)
+	}
+
+	// The dashboard works offline, so the assets can't load anything from the other sites
+	err := fs.WalkDir(assets, "assets", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := assets.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		assert.False(t, strings.Contains(string(data), "http://") || strings.Contains(string(data), "https://"), path)
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,13 @@
+package exported
+
+import "time"
+
+// ListCache is an entry of the cached return values of a function.
+type ListCache struct {
+	Key      string    `json:"key"`
+	Function string    `json:"function"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+	Expired  bool      `json:"expired"`
+	Returns  int       `json:"returns"`
+}
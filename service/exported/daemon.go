@@ -0,0 +1,30 @@
+package exported
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type DaemonFlowInsight struct {
+	Name   string `json:"name"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Runs and Failures count the runnings of the flow since the daemon hosted it.
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	LastRun   time.Time `json:"last_run"`
+	LastError string    `json:"last_error"`
+}
+
+type DaemonInsight struct {
+	Pid     int                 `json:"pid"`
+	Started time.Time           `json:"started"`
+	Flows   []DaemonFlowInsight `json:"flows"`
+}
+
+func (d DaemonInsight) JsonWrite(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
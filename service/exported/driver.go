@@ -0,0 +1,21 @@
+package exported
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ListDrivers is a registered function driver and its capabilities.
+type ListDrivers struct {
+	Name      string `json:"name"`
+	Versions  bool   `json:"versions"`
+	LongLived bool   `json:"long_lived"`
+	Sandboxed bool   `json:"sandboxed"`
+	Inline    bool   `json:"inline"`
+}
+
+func (l ListDrivers) JsonWrite(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(l)
+}
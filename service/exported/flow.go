@@ -15,20 +15,44 @@ type NodeRunningInsight struct {
 	LastError error  `json:"last_error"`
 	Status    string `json:"status"`
 	Runs      int    `json:"runs"`
-	Duration  int64  `json:"duration"`
+	// Begin is the start time of the last running of the node, it's zero if the node hasn't run.
+	Begin    time.Time `json:"begin_time"`
+	Duration int64     `json:"duration"`
+}
+
+type TriggerRunningInsight struct {
+	Seq       int       `json:"seq"`
+	Name      string    `json:"name"`
+	Function  string    `json:"function"`
+	Driver    string    `json:"driver"`
+	Status    string    `json:"status"`
+	Fires     int       `json:"fires"`
+	LastFire  time.Time `json:"last_fire"`
+	NextFire  time.Time `json:"next_fire"`
+	Errors    int       `json:"consecutive_errors"`
+	LastError error     `json:"last_error"`
+	Backoff   int64     `json:"backoff"`
 }
 
 type FlowRunningInsight struct {
-	Name      string               `json:"name"`
-	ID        string               `json:"id"`
-	Status    string               `json:"status"`
-	LastError error                `json:"last_error"`
-	Begin     time.Time            `json:"begin_time"`
-	Duration  int64                `json:"duration"`
-	Total     int                  `json:"total"`
-	Running   int                  `json:"running"`
-	Done      int                  `json:"done"`
-	Nodes     []NodeRunningInsight `json:"nodes"`
+	Name      string    `json:"name"`
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	RunID     string    `json:"run_id"`
+	LastError error     `json:"last_error"`
+	Begin     time.Time `json:"begin_time"`
+	Duration  int64     `json:"duration"`
+	Total     int       `json:"total"`
+	Running   int       `json:"running"`
+	Done      int       `json:"done"`
+	Dropped   int       `json:"dropped_events"`
+	// TriggersStarted is true while the triggers of the flow are started, ActiveRuns counts the running
+	// instances of the flow, and QueuedEvents counts the events waiting for the running flow.
+	TriggersStarted bool                    `json:"triggers_started"`
+	ActiveRuns      int                     `json:"active_runs"`
+	QueuedEvents    int                     `json:"queued_events"`
+	Nodes           []NodeRunningInsight    `json:"nodes"`
+	Triggers        []TriggerRunningInsight `json:"triggers"`
 }
 
 func (f FlowRunningInsight) JsonWrite(w io.Writer) error {
@@ -43,6 +67,10 @@ type FlowMetaInsight struct {
 	Total  int    `json:"total"`
 	Source string `json:"source"`
 	Desc   string `json:"desc"`
+	// Loads are the functions loaded by the flow, e.g. shell:deploy@^1.2
+	Loads []string `json:"loads"`
+	// Tags are declared by the 'tags:' comment of the flowl, e.g. // tags: ci, nightly
+	Tags []string `json:"tags"`
 }
 
 func (f FlowMetaInsight) JsonWrite(w io.Writer) error {
@@ -50,3 +78,24 @@ func (f FlowMetaInsight) JsonWrite(w io.Writer) error {
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(f)
 }
+
+// FlowRunRecord is a finished running of the flow.
+type FlowRunRecord struct {
+	RunID    string    `json:"run_id"`
+	Name     string    `json:"name"`
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error"`
+	Begin    time.Time `json:"begin_time"`
+	Duration int64     `json:"duration"`
+	// Nodes are the state of the nodes when the running finished, they make up the timeline of the running.
+	Nodes []NodeRunningInsight `json:"nodes"`
+}
+
+// FlowProcessInsight is the state of a flow loaded in the runtime, NextFire is the earliest next fire time
+// of its triggers, and LastRun is its last finished running.
+type FlowProcessInsight struct {
+	Insight  FlowRunningInsight `json:"insight"`
+	NextFire time.Time          `json:"next_fire"`
+	LastRun  *FlowRunRecord     `json:"last_run"`
+}
@@ -0,0 +1,39 @@
+package exported
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/skoowoo/cofx/manifest"
+)
+
+// ListFunctions is a function of the standard library or installed in the store.
+type ListFunctions struct {
+	Driver   string   `json:"driver"`
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+	Desc     string   `json:"desc"`
+}
+
+func (l ListFunctions) JsonWrite(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(l)
+}
+
+// InspectFunction is a version of the function, the dir and checksum are empty for the function of the
+// standard library.
+type InspectFunction struct {
+	Driver   string            `json:"driver"`
+	Name     string            `json:"name"`
+	Versions []string          `json:"versions"`
+	Dir      string            `json:"dir"`
+	Checksum string            `json:"checksum"`
+	Manifest manifest.Manifest `json:"manifest"`
+}
+
+func (i InspectFunction) JsonWrite(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(i)
+}
@@ -0,0 +1,86 @@
+package exported
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// The errors in the insights are encoded as their messages, because an error interface can't be decoded,
+// the decoded error only keeps the message.
+
+func (n NodeRunningInsight) MarshalJSON() ([]byte, error) {
+	type alias NodeRunningInsight
+	return json.Marshal(struct {
+		alias
+		LastError string `json:"last_error"`
+	}{alias(n), errorString(n.LastError)})
+}
+
+func (n *NodeRunningInsight) UnmarshalJSON(data []byte) error {
+	type alias NodeRunningInsight
+	v := struct {
+		*alias
+		LastError string `json:"last_error"`
+	}{alias: (*alias)(n)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	n.LastError = stringError(v.LastError)
+	return nil
+}
+
+func (t TriggerRunningInsight) MarshalJSON() ([]byte, error) {
+	type alias TriggerRunningInsight
+	return json.Marshal(struct {
+		alias
+		LastError string `json:"last_error"`
+	}{alias(t), errorString(t.LastError)})
+}
+
+func (t *TriggerRunningInsight) UnmarshalJSON(data []byte) error {
+	type alias TriggerRunningInsight
+	v := struct {
+		*alias
+		LastError string `json:"last_error"`
+	}{alias: (*alias)(t)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.LastError = stringError(v.LastError)
+	return nil
+}
+
+func (f FlowRunningInsight) MarshalJSON() ([]byte, error) {
+	type alias FlowRunningInsight
+	return json.Marshal(struct {
+		alias
+		LastError string `json:"last_error"`
+	}{alias(f), errorString(f.LastError)})
+}
+
+func (f *FlowRunningInsight) UnmarshalJSON(data []byte) error {
+	type alias FlowRunningInsight
+	v := struct {
+		*alias
+		LastError string `json:"last_error"`
+	}{alias: (*alias)(f)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	f.LastError = stringError(v.LastError)
+	return nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func stringError(s string) error {
+	if s == "" {
+		return nil
+	}
+	return errors.New(s)
+}
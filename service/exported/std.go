@@ -11,6 +11,8 @@ type ListStdFunctions struct {
 	Category string `json:"category"`
 	Name     string `json:"name"`
 	Desc     string `json:"desc"`
+	// Versions are all the versions of the function, from the highest to the lowest.
+	Versions []string `json:"versions"`
 }
 
 func (l ListStdFunctions) JsonWrite(w io.Writer) error {
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/service/exported"
+	"github.com/skoowoo/cofx/service/resource/flowtrigger"
+)
+
+// publishFlowEvent sends the completion event of the flow to the event_flow triggers of the other flows,
+// the outcomes saved by the flow are the outputs and returned as 'output.<key>'. The failures of sending
+// don't fail the flow, they are published as the warnings of the flow.
+func (s *SVC) publishFlowEvent(id nameid.ID, fi exported.FlowRunningInsight, err error) {
+	ev := map[string]string{
+		"flow":     id.Name(),
+		"flow_id":  id.ID(),
+		"run_id":   fi.RunID,
+		"status":   flowtrigger.StatusSuccess,
+		"finished": time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		ev["status"] = flowtrigger.StatusFailure
+		ev["error"] = err.Error()
+	}
+	s.endRun(id, fi, ev["status"], err)
+	// The outcomes saved by the running are its outputs, they are removed after being sent.
+	where := fmt.Sprintf("run_id = '%s'", fi.RunID)
+	rows, qerr := s.outcome.Query(context.Background(), []string{"key", "value"}, where)
+	if qerr != nil {
+		s.rt.Warn(id, fmt.Errorf("%w: query the outputs of flow '%s'", qerr, id))
+	}
+	if derr := s.outcome.Delete(context.Background(), where); derr != nil {
+		s.rt.Warn(id, fmt.Errorf("%w: delete the outputs of flow '%s'", derr, id))
+	}
+	outputs := make(map[string][]string)
+	for _, row := range rows {
+		if len(row) == 2 {
+			outputs[row[0]] = append(outputs[row[0]], row[1])
+		}
+	}
+	for k, vs := range outputs {
+		ev["output."+k] = strings.Join(vs, ",")
+	}
+	if n := s.flowtrg.Publish(id.Name(), ev); n != 0 {
+		s.rt.Warn(id, fmt.Errorf("the completion event of flow '%s' is dropped by %d triggers", id, n))
+	}
+}
+
+// maxHistory is the max number of the runnings kept for a flow.
+const maxHistory = 100
+
+// beginRun records the begin time of a running by its run id, the replicas of the flow can run at the same
+// time and finish in any order.
+func (s *SVC) beginRun(runid string) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	s.begins[runid] = time.Now()
+}
+
+// endRun adds the finished running into the history of the flow, the state of the nodes is kept as the
+// timeline of the running.
+func (s *SVC) endRun(id nameid.ID, fi exported.FlowRunningInsight, status string, err error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	now := time.Now()
+	record := exported.FlowRunRecord{
+		RunID:  fi.RunID,
+		Name:   id.Name(),
+		ID:     id.ID(),
+		Status: status,
+		Begin:  now,
+		Nodes:  fi.Nodes,
+	}
+	if begin, ok := s.begins[fi.RunID]; ok {
+		record.Begin = begin
+		delete(s.begins, fi.RunID)
+	}
+	record.Duration = now.Sub(record.Begin).Milliseconds()
+	if err != nil {
+		record.Error = err.Error()
+	}
+	records := append(s.history[id.ID()], record)
+	if len(records) > maxHistory {
+		records = records[len(records)-maxHistory:]
+	}
+	s.history[id.ID()] = records
+}
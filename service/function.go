@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skoowoo/cofx/functiondriver"
+	godriver "github.com/skoowoo/cofx/functiondriver/go"
+	"github.com/skoowoo/cofx/functiondriver/store"
+	"github.com/skoowoo/cofx/pkg/semver"
+	"github.com/skoowoo/cofx/service/exported"
+	"github.com/skoowoo/cofx/std"
+)
+
+var ErrFunctionInUse = errors.New("function in use")
+
+// parseFunction parses the function reference that likes the 'load' statement, e.g. shell:deploy@^1.2
+func parseFunction(ref string) (functiondriver.Location, error) {
+	if fields := strings.Split(ref, ":"); len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return functiondriver.Location{}, fmt.Errorf("invalid function '%s', expect driver:name[@version]", ref)
+	}
+	return functiondriver.NewLocation(ref), nil
+}
+
+// ListFunctions returns all functions of the standard library and the functions installed in the store.
+func (s *SVC) ListFunctions(ctx context.Context) ([]exported.ListFunctions, error) {
+	var list []exported.ListFunctions
+	for _, m := range std.ListAll() {
+		list = append(list, exported.ListFunctions{
+			Driver:   godriver.Name,
+			Name:     m.Name,
+			Versions: std.Versions(m.Name),
+			Desc:     m.Description,
+		})
+	}
+	installed, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range installed {
+		list = append(list, exported.ListFunctions{
+			Driver:   f.Driver,
+			Name:     f.Name,
+			Versions: f.Versions,
+			Desc:     f.Description,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Driver != list[j].Driver {
+			return list[i].Driver < list[j].Driver
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// InspectFunction returns the highest version of the function that satisfies the version constraint, e.g.
+// shell:deploy@^1.2, the latest version is returned by default.
+func (s *SVC) InspectFunction(ctx context.Context, ref string) (exported.InspectFunction, error) {
+	loc, err := parseFunction(ref)
+	if err != nil {
+		return exported.InspectFunction{}, err
+	}
+	if loc.DriverName == godriver.Name {
+		m, _, _, err := std.LookupVersion(loc.FuncName, loc.Version)
+		if err != nil {
+			return exported.InspectFunction{}, err
+		}
+		return exported.InspectFunction{
+			Driver:   godriver.Name,
+			Name:     m.Name,
+			Versions: std.Versions(m.Name),
+			Manifest: *m,
+		}, nil
+	}
+	info, err := store.Inspect(loc.DriverName, loc.FuncPath, loc.Version)
+	if err != nil {
+		return exported.InspectFunction{}, err
+	}
+	return exportFunction(info), nil
+}
+
+// InstallFunction installs the function package from a directory, a tarball or a local git repository.
+func (s *SVC) InstallFunction(ctx context.Context, source string, force bool) (exported.InspectFunction, error) {
+	info, err := store.Install(source, force)
+	if err != nil {
+		return exported.InspectFunction{}, err
+	}
+	return exportFunction(info), nil
+}
+
+// RemoveFunction removes the installed function, e.g. shell:deploy@1.2.0, all versions are removed if
+// no version is specified. It fails if an available flow still loads the version being removed, unless
+// force is true.
+func (s *SVC) RemoveFunction(ctx context.Context, ref string, force bool) error {
+	loc, err := parseFunction(ref)
+	if err != nil {
+		return err
+	}
+	version := loc.Version
+	if version == semver.Latest {
+		version = ""
+	}
+	f, err := store.Lookup(loc.DriverName, loc.FuncPath)
+	if err != nil {
+		return err
+	}
+	if !force {
+		for _, meta := range s.availables {
+			if loadsFunction(meta.Loads, f, version) {
+				return fmt.Errorf("%w: %s is loaded by the flow '%s', use --force to remove it anyway",
+					ErrFunctionInUse, ref, meta.Name)
+			}
+		}
+	}
+	return store.Remove(loc.DriverName, loc.FuncPath, version)
+}
+
+// loadsFunction returns true if one of the 'load' statements resolves to the version of the function,
+// any version is matched if the version is empty.
+func loadsFunction(loads []string, f store.Function, version string) bool {
+	for _, load := range loads {
+		loc, err := parseFunction(load)
+		if err != nil || loc.DriverName != f.Driver || filepath.Clean(loc.FuncPath) != f.Name {
+			continue
+		}
+		if version == "" {
+			return true
+		}
+		c, err := semver.ParseConstraint(loc.Version)
+		if err != nil {
+			continue
+		}
+		if found, ok := semver.Highest(c, f.Versions); ok && found == version {
+			return true
+		}
+	}
+	return false
+}
+
+func exportFunction(info *store.Info) exported.InspectFunction {
+	return exported.InspectFunction{
+		Driver:   info.Driver,
+		Name:     info.Name,
+		Versions: info.Versions,
+		Dir:      info.Path,
+		Checksum: info.Checksum,
+		Manifest: info.Manifest,
+	}
+}
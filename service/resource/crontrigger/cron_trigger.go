@@ -33,6 +33,15 @@ func (ct *CronTrigger) Remove(v interface{}) error {
 	return nil
 }
 
+func (ct *CronTrigger) Next(v interface{}) time.Time {
+	entityid := v.(cron.EntryID)
+	entry := ct.c.Entry(entityid)
+	if entry.Schedule == nil {
+		return time.Time{}
+	}
+	return entry.Schedule.Next(time.Now())
+}
+
 func (ct *CronTrigger) Start() {
 	ct.c.Start()
 }
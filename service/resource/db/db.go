@@ -13,6 +13,7 @@ func StatementCreateOutcomeTable() (string, string) {
 	stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		flow_id 	TEXT NOT NULL,
+		run_id 		TEXT NOT NULL,
 		node_seq    INT  NOT NULL,
 		node_name   TEXT NOT NULL,
 		key 		TEXT NOT NULL,
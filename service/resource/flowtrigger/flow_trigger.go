@@ -0,0 +1,122 @@
+package flowtrigger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// The status of the flow in the completion events.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+var (
+	ErrFlowCycle           = errors.New("flow cycle")
+	ErrSubscriptionInvalid = errors.New("invalid subscription")
+)
+
+type subscription struct {
+	subscriber string
+	target     string
+	ch         chan<- map[string]string
+}
+
+// FlowTrigger delivers the completion events of the flows to the trigger functions that subscribe them, and
+// keeps the graph of the subscriptions to reject the cycles, e.g. flow a triggers b and b triggers a.
+type FlowTrigger struct {
+	sync.Mutex
+	subs map[*subscription]struct{}
+	// edges is the graph of the subscriptions, the map is target->subscriber->number of subscriptions.
+	edges map[string]map[string]int
+}
+
+func New() *FlowTrigger {
+	return &FlowTrigger{
+		subs:  make(map[*subscription]struct{}),
+		edges: make(map[string]map[string]int),
+	}
+}
+
+// Subscribe subscribes the completion events of the target flow, the events are sent to ch without blocking,
+// so an event is dropped if ch is full.
+func (ft *FlowTrigger) Subscribe(subscriber, target string, ch chan<- map[string]string) (interface{}, error) {
+	ft.Lock()
+	defer ft.Unlock()
+	if path := ft.path(subscriber, target); path != nil {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrFlowCycle, strings.Join(path, " -> "), subscriber)
+	}
+	sub := &subscription{subscriber: subscriber, target: target, ch: ch}
+	ft.subs[sub] = struct{}{}
+	if ft.edges[target] == nil {
+		ft.edges[target] = make(map[string]int)
+	}
+	ft.edges[target][subscriber]++
+	return sub, nil
+}
+
+// Unsubscribe removes the subscription returned by Subscribe.
+func (ft *FlowTrigger) Unsubscribe(v interface{}) error {
+	ft.Lock()
+	defer ft.Unlock()
+	sub, ok := v.(*subscription)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrSubscriptionInvalid, v)
+	}
+	if _, ok := ft.subs[sub]; !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrSubscriptionInvalid, sub.target, sub.subscriber)
+	}
+	delete(ft.subs, sub)
+	if ft.edges[sub.target][sub.subscriber]--; ft.edges[sub.target][sub.subscriber] == 0 {
+		delete(ft.edges[sub.target], sub.subscriber)
+	}
+	return nil
+}
+
+// Publish sends the completion event of the flow to all its subscribers, every subscriber gets a copy of
+// the event. It returns the number of the subscribers that the event is dropped for.
+func (ft *FlowTrigger) Publish(flow string, event map[string]string) int {
+	ft.Lock()
+	defer ft.Unlock()
+	var dropped int
+	for sub := range ft.subs {
+		if sub.target != flow {
+			continue
+		}
+		ev := make(map[string]string, len(event))
+		for k, v := range event {
+			ev[k] = v
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// path returns the flows from 'from' to 'to' along the subscriptions, it returns nil if 'to' can't be
+// reached, a flow always reaches itself.
+func (ft *FlowTrigger) path(from, to string) []string {
+	visited := make(map[string]bool)
+	var walk func(flow string) []string
+	walk = func(flow string) []string {
+		if flow == to {
+			return []string{flow}
+		}
+		if visited[flow] {
+			return nil
+		}
+		visited[flow] = true
+		for next := range ft.edges[flow] {
+			if p := walk(next); p != nil {
+				return append([]string{flow}, p...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
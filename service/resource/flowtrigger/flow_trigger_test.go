@@ -0,0 +1,64 @@
+package flowtrigger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowTrigger(t *testing.T) {
+	ft := New()
+
+	ch1 := make(chan map[string]string, 1)
+	ch2 := make(chan map[string]string, 1)
+	s1, err := ft.Subscribe("package", "build", ch1)
+	assert.NoError(t, err)
+	s2, err := ft.Subscribe("notify", "build", ch2)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, ft.Publish("build", map[string]string{"status": "success"}))
+	assert.Equal(t, map[string]string{"status": "success"}, <-ch1)
+	assert.Equal(t, map[string]string{"status": "success"}, <-ch2)
+
+	// The other flows don't fire the subscribers
+	assert.Equal(t, 0, ft.Publish("test", map[string]string{"status": "success"}))
+	assert.Len(t, ch1, 0)
+
+	// The event is dropped if the channel is full
+	ch1 <- map[string]string{}
+	assert.Equal(t, 1, ft.Publish("build", map[string]string{"status": "failure"}))
+	<-ch1
+	<-ch2
+
+	assert.NoError(t, ft.Unsubscribe(s2))
+	assert.ErrorIs(t, ft.Unsubscribe(s2), ErrSubscriptionInvalid)
+	assert.ErrorIs(t, ft.Unsubscribe("s2"), ErrSubscriptionInvalid)
+	assert.Equal(t, 0, ft.Publish("build", map[string]string{}))
+	assert.Len(t, ch2, 0)
+	<-ch1
+
+	assert.NoError(t, ft.Unsubscribe(s1))
+}
+
+func TestFlowCycle(t *testing.T) {
+	ft := New()
+	ch := make(chan map[string]string)
+
+	_, err := ft.Subscribe("build", "build", ch)
+	assert.ErrorIs(t, err, ErrFlowCycle)
+
+	// build -> package -> release
+	_, err = ft.Subscribe("package", "build", ch)
+	assert.NoError(t, err)
+	s, err := ft.Subscribe("release", "package", ch)
+	assert.NoError(t, err)
+
+	_, err = ft.Subscribe("build", "release", ch)
+	assert.ErrorIs(t, err, ErrFlowCycle)
+	assert.Contains(t, err.Error(), "build -> package -> release -> build")
+
+	// The cycle is broken after the subscription is removed
+	assert.NoError(t, ft.Unsubscribe(s))
+	_, err = ft.Subscribe("build", "release", ch)
+	assert.NoError(t, err)
+}
@@ -1 +1,107 @@
 package httptrigger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+var (
+	ErrRouteConflict = errors.New("route conflict")
+	ErrRouteNotFound = errors.New("route not found")
+)
+
+// HttpTrigger is a http server that dispatches the requests to the handlers added by the trigger functions.
+// The server is started when the first route is added, so no port is listened if no flow needs it.
+type HttpTrigger struct {
+	sync.Mutex
+	addr     string
+	routes   map[string]func(w http.ResponseWriter, r *http.Request)
+	server   *http.Server
+	listener net.Listener
+}
+
+func New(addr string) *HttpTrigger {
+	return &HttpTrigger{
+		addr:   addr,
+		routes: make(map[string]func(w http.ResponseWriter, r *http.Request)),
+	}
+}
+
+// AddRoute adds the handler of the path, a path can only be added once.
+func (ht *HttpTrigger) AddRoute(path string, handler func(w http.ResponseWriter, r *http.Request)) error {
+	ht.Lock()
+	defer ht.Unlock()
+	if _, ok := ht.routes[path]; ok {
+		return fmt.Errorf("%w: '%s' has been added", ErrRouteConflict, path)
+	}
+	if err := ht.start(); err != nil {
+		return err
+	}
+	ht.routes[path] = handler
+	return nil
+}
+
+// RemoveRoute removes the handler of the path.
+func (ht *HttpTrigger) RemoveRoute(path string) error {
+	ht.Lock()
+	defer ht.Unlock()
+	if _, ok := ht.routes[path]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrRouteNotFound, path)
+	}
+	delete(ht.routes, path)
+	return nil
+}
+
+// ServeHTTP dispatches the request to the handler of the path.
+func (ht *HttpTrigger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ht.Lock()
+	handler, ok := ht.routes[r.URL.Path]
+	ht.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+// Addr returns the address that the server is listening on, it's empty if the server isn't started.
+func (ht *HttpTrigger) Addr() string {
+	ht.Lock()
+	defer ht.Unlock()
+	if ht.listener == nil {
+		return ""
+	}
+	return ht.listener.Addr().String()
+}
+
+// Stop stops the server, the routes are kept, the server is started again when adding a new route.
+func (ht *HttpTrigger) Stop(ctx context.Context) error {
+	ht.Lock()
+	defer ht.Unlock()
+	if ht.server == nil {
+		return nil
+	}
+	err := ht.server.Shutdown(ctx)
+	ht.server = nil
+	ht.listener = nil
+	return err
+}
+
+func (ht *HttpTrigger) start() error {
+	if ht.server != nil {
+		return nil
+	}
+	l, err := net.Listen("tcp", ht.addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: ht}
+	go server.Serve(l)
+	ht.server = server
+	ht.listener = l
+	return nil
+}
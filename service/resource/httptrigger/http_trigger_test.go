@@ -0,0 +1,44 @@
+package httptrigger
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpTrigger(t *testing.T) {
+	ht := New("127.0.0.1:0")
+	assert.Equal(t, "", ht.Addr())
+
+	err := ht.AddRoute("/hook", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hooked"))
+	})
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", ht.Addr())
+	defer ht.Stop(context.Background())
+
+	err = ht.AddRoute("/hook", func(w http.ResponseWriter, r *http.Request) {})
+	assert.ErrorIs(t, err, ErrRouteConflict)
+
+	resp, err := http.Get("http://" + ht.Addr() + "/hook")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hooked", string(b))
+
+	assert.NoError(t, ht.RemoveRoute("/hook"))
+	assert.ErrorIs(t, ht.RemoveRoute("/hook"), ErrRouteNotFound)
+
+	resp, err = http.Get("http://" + ht.Addr() + "/hook")
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
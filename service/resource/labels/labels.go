@@ -17,6 +17,11 @@ func (l Labels) GetFlowID() string {
 	return l.Get("flow_id")
 }
 
+// GetFlowName returns the value of the label 'flow_name'.
+func (l Labels) GetFlowName() string {
+	return l.Get("flow_name")
+}
+
 // GetNodeSeq returns the value of the label 'node_seq'.
 func (l Labels) GetNodeSeq() string {
 	return l.Get("node_seq")
@@ -26,3 +31,8 @@ func (l Labels) GetNodeSeq() string {
 func (l Labels) GetNodeName() string {
 	return l.Get("node_name")
 }
+
+// GetRunID returns the value of the label 'run_id', it's the id of the current running of the flow.
+func (l Labels) GetRunID() string {
+	return l.Get("run_id")
+}
@@ -73,6 +73,18 @@ func (s *Logset) Restore() error {
 	return nil
 }
 
+// Close flushes and closes all writers of all buckets.
+func (s *Logset) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	for _, b := range s.buckets {
+		if err := b.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreateBucket create a new bucket object that can be used to write the output content.
 func (s *Logset) CreateBucket(bucketid string) *LogBucket {
 	s.Lock()
@@ -126,6 +138,18 @@ func (b *LogBucket) Reset() error {
 	return nil
 }
 
+// Close flushes and closes all writers of the bucket.
+func (b *LogBucket) Close() error {
+	for _, w := range b.writers {
+		if c, ok := w.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (b *LogBucket) CreateWriter(id string, ws ...io.Writer) (io.Writer, error) {
 	if b.IsFile() {
 		path := filepath.Join(b.set.addr, "buckets", b.id, id, "logfile")
@@ -285,6 +309,14 @@ func (l *logStdout) Reset() error {
 	return nil
 }
 
+// Close flushes the last line that isn't ended with '\n'.
+func (l *logStdout) Close() error {
+	l.Lock()
+	defer l.Unlock()
+	l.out.Close()
+	return nil
+}
+
 func (l *logStdout) WriteTitle(primary, secondary string) {
 	s := pretty.IconCycle.String() + pretty.ColorGrey1.Render(primary+" ➜ "+secondary) + "\n"
 	fmt.Fprint(l.w, s)
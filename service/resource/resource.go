@@ -19,9 +19,13 @@ type Resources struct {
 	Logwriter    io.Writer
 	CronTrigger  CronTrigger
 	HttpTrigger  HttpTrigger
+	FlowTrigger  FlowTrigger
 	OutputParser TableOperation
 	Outcome      TableOperation
 	Labels       LabelManger
+	Trigger      TriggerReporter
+	Functions    FunctionCaller
+	Cache        ResultCache
 }
 
 // LabelManager manage some labels for driver and function, the LabelManager is a resource.
@@ -30,16 +34,34 @@ type LabelManger interface {
 	Set(key, value string)
 	// GetFlowID returns the value of the label 'flow_id'.
 	GetFlowID() string
+	// GetFlowName returns the value of the label 'flow_name'.
+	GetFlowName() string
 	// GetNodeSeq returns the value of the label 'node_seq'.
 	GetNodeSeq() string
 	// GetNodeName returns the value of the label 'node_name'.
 	GetNodeName() string
+	// GetRunID returns the value of the label 'run_id'.
+	GetRunID() string
 }
 
 // CronTrigger add and remove the cron job by trigger function, the CronTrigger is a resource for trigger.
 type CronTrigger interface {
 	Add(format string, ch chan<- time.Time) (interface{}, error)
 	Remove(interface{}) error
+	// Next returns the next time of the cron job, returns zero time if unknown.
+	Next(interface{}) time.Time
+}
+
+// TriggerReporter is used by trigger function to report when the next event is expected, it's only
+// available for the trigger.
+type TriggerReporter interface {
+	ExpectNext(t time.Time)
+}
+
+// FunctionCaller calls the other functions loaded by the flow, e.g. the script function calls them
+// through the builtin 'call'.
+type FunctionCaller interface {
+	Call(ctx context.Context, fname string, args map[string]string) (map[string]string, error)
 }
 
 // HttpTrigger add and remove the http handler by trigger function, the HttpTrigger is a resource for trigger.
@@ -48,9 +70,24 @@ type HttpTrigger interface {
 	RemoveRoute(path string) error
 }
 
+// FlowTrigger subscribes the completion events of the other flows by trigger function, the FlowTrigger is a
+// resource for trigger. The subscriber and the target are the names of the flows, the subscription is
+// rejected if it makes the flows trigger each other in a cycle.
+type FlowTrigger interface {
+	Subscribe(subscriber, target string, ch chan<- map[string]string) (interface{}, error)
+	Unsubscribe(interface{}) error
+}
+
 // TableOperation is the interface for db table's insert, delete and query.
 type TableOperation interface {
 	Insert(ctx context.Context, columns []string, values ...any) error
 	Delete(ctx context.Context, where string) error
 	Query(ctx context.Context, columns []string, where string) ([][]string, error)
 }
+
+// ResultCache stores the return values of the functions, it's used to skip the nodes whose inputs aren't
+// changed, the key is figured out from the inputs of the node.
+type ResultCache interface {
+	Get(key string) (map[string]string, bool)
+	Put(key, function string, ttl time.Duration, returns map[string]string) error
+}
@@ -2,16 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	co "github.com/skoowoo/cofx"
 	"github.com/skoowoo/cofx/config"
+	"github.com/skoowoo/cofx/functiondriver"
+	"github.com/skoowoo/cofx/pkg/cache"
 	"github.com/skoowoo/cofx/pkg/nameid"
+	"github.com/skoowoo/cofx/pkg/semver"
 	"github.com/skoowoo/cofx/pkg/sqlite"
 	"github.com/skoowoo/cofx/runtime"
 	"github.com/skoowoo/cofx/runtime/actuator"
@@ -19,6 +28,8 @@ import (
 	"github.com/skoowoo/cofx/service/resource"
 	"github.com/skoowoo/cofx/service/resource/crontrigger"
 	"github.com/skoowoo/cofx/service/resource/db"
+	"github.com/skoowoo/cofx/service/resource/flowtrigger"
+	"github.com/skoowoo/cofx/service/resource/httptrigger"
 	"github.com/skoowoo/cofx/service/resource/labels"
 	"github.com/skoowoo/cofx/service/resource/logset"
 	"github.com/skoowoo/cofx/std"
@@ -34,10 +45,24 @@ type SVC struct {
 	stdout  *logset.Logset
 	// cron service for flow and function
 	cron *crontrigger.CronTrigger
+	// http trigger service for the webhooks, it listens on the port only after a route is added
+	webhook *httptrigger.HttpTrigger
+	// flow trigger service delivers the completion events of the flows to the event_flow triggers
+	flowtrg *flowtrigger.FlowTrigger
+	// history keeps the last runnings of the flows, the key is the string of flow's id.
+	history map[string][]exported.FlowRunRecord
+	// begins keeps the begin time of the active runnings, the key is the run id.
+	begins    map[string]time.Time
+	historyMu sync.Mutex
 	// mdb and outbl service for parsing the output of commands
 	mdb     *sqlite.DB
 	outbl   *sqlite.Table
 	outcome *sqlite.Table
+	// cache service for the return values of the functions
+	cache *cache.Cache
+	// shutdown makes sure the service only be shutdown once
+	shutdown    sync.Once
+	shutdownErr error
 }
 
 // New create a service layer instance
@@ -58,7 +83,8 @@ func New() *SVC {
 	// Create cron trigger service
 	cron := crontrigger.New()
 	cron.Start()
-	// TODO: Create http trigger service
+	// Create http trigger service
+	webhook := httptrigger.New(config.HttpTriggerAddr())
 
 	// Create mdb service
 	mdb, err := sqlite.NewMemDB()
@@ -80,9 +106,14 @@ func New() *SVC {
 		logfile:    logfile,
 		stdout:     stdout,
 		cron:       cron,
+		webhook:    webhook,
+		flowtrg:    flowtrigger.New(),
+		history:    make(map[string][]exported.FlowRunRecord),
+		begins:     make(map[string]time.Time),
 		mdb:        mdb,
 		outbl:      &tbl,
 		outcome:    &outcome,
+		cache:      cache.New(config.CacheDir()),
 	}
 }
 
@@ -95,15 +126,36 @@ func (s *SVC) ListStdFunctions(ctx context.Context) []exported.ListStdFunctions
 			Category: m.Category,
 			Name:     m.Name,
 			Desc:     m.Description,
+			Versions: std.Versions(m.Name),
 		})
 	}
 	return list
 }
 
-// InspectStdFunction returns the manifest of the standard function
+// ListDrivers returns all registered function drivers and their capabilities.
+func (s *SVC) ListDrivers(ctx context.Context) []exported.ListDrivers {
+	var list []exported.ListDrivers
+	for _, d := range functiondriver.ListAll() {
+		list = append(list, exported.ListDrivers{
+			Name:      d.Name,
+			Versions:  d.Capabilities.Versions,
+			LongLived: d.Capabilities.LongLived,
+			Sandboxed: d.Capabilities.Sandboxed,
+			Inline:    d.Capabilities.Inline,
+		})
+	}
+	return list
+}
+
+// InspectStdFunction returns the manifest of the standard function, the name can be followed by a version
+// constraint, e.g. 'print@1.0', the latest version is returned by default.
 func (s *SVC) InspectStdFunction(ctx context.Context, name string) exported.InspectStdFunction {
-	m, _, _ := std.Lookup(name)
-	if m == nil {
+	version := semver.Latest
+	if i := strings.LastIndex(name, "@"); i > 0 {
+		name, version = name[:i], name[i+1:]
+	}
+	m, _, _, err := std.LookupVersion(name, version)
+	if err != nil || m == nil {
 		return exported.InspectStdFunction{}
 	}
 	return exported.InspectStdFunction(*m)
@@ -175,6 +227,34 @@ func (s *SVC) InsightFlow(ctx context.Context, fid nameid.ID) (exported.FlowRunn
 	return fi, err
 }
 
+// ListLoadedFlows returns the state of all flows loaded in the runtime, with the next fire time of their
+// triggers and their last runnings.
+func (s *SVC) ListLoadedFlows(ctx context.Context) []exported.FlowProcessInsight {
+	var list []exported.FlowProcessInsight
+	for _, fi := range s.rt.ListFlows(ctx) {
+		pi := exported.FlowProcessInsight{Insight: fi}
+		for _, tg := range fi.Triggers {
+			if !tg.NextFire.IsZero() && (pi.NextFire.IsZero() || tg.NextFire.Before(pi.NextFire)) {
+				pi.NextFire = tg.NextFire
+			}
+		}
+		s.historyMu.Lock()
+		if records := s.history[fi.ID]; len(records) != 0 {
+			last := records[len(records)-1]
+			pi.LastRun = &last
+		}
+		s.historyMu.Unlock()
+		list = append(list, pi)
+	}
+	return list
+}
+
+// Subscribe returns a subscription to receive the state change events of the flows, if no flow id
+// is given, it receives the events of all flows. The subscription must be closed after using.
+func (s *SVC) Subscribe(ctx context.Context, ids ...nameid.ID) *runtime.Subscription {
+	return s.rt.Subscribe(0, ids...)
+}
+
 // CancelRunningFlow cancels a running flow, the canceled flow not be started again automatically.
 func (s *SVC) CancelRunningFlow(ctx context.Context, id nameid.ID) error {
 	return s.rt.CancelFlow(ctx, id)
@@ -189,8 +269,9 @@ func (s *SVC) AddFlow(ctx context.Context, id nameid.ID, rd io.ReadCloser) error
 	return nil
 }
 
-// ReadyFlow initialize the flow and make it ready to run
-func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, out io.Writer) (exported.FlowRunningInsight, error) {
+// ReadyFlow initialize the flow and make it ready to run, the argument 'extra' can be used to pass
+// some additional options to the flow, e.g. a debugger.
+func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, out io.Writer, extra ...runtime.FlowOption) (exported.FlowRunningInsight, error) {
 	createLogWriter := func(writerid string) (io.Writer, error) {
 		if out != nil {
 			return s.stdout.CreateBucket(id.ID()).CreateWriter(writerid, out)
@@ -198,20 +279,23 @@ func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, out io.Writer) (expor
 			return s.logfile.CreateBucket(id.ID()).CreateWriter(writerid)
 		}
 	}
-	beforeExec := func(id nameid.ID) error {
-		// TODO:
+	beforeExec := func(id nameid.ID, runid string) error {
+		s.beginRun(runid)
 		return nil
 	}
-	afterExec := func(id nameid.ID) error {
-		// TODO:
+	afterExec := func(id nameid.ID, fi exported.FlowRunningInsight, err error) error {
+		s.publishFlowEvent(id, fi, err)
 		return nil
 	}
 	copy := func() resource.Resources {
 		return resource.Resources{
 			CronTrigger:  s.cron,
+			HttpTrigger:  s.webhook,
+			FlowTrigger:  s.flowtrg,
 			OutputParser: s.outbl,
 			Outcome:      s.outcome,
 			Labels:       make(labels.Labels),
+			Cache:        s.cache,
 		}
 	}
 	var opts = []runtime.FlowOption{
@@ -220,6 +304,7 @@ func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, out io.Writer) (expor
 		runtime.WithCopyResources(copy),
 		runtime.WithCreateLogwriter(createLogWriter),
 	}
+	opts = append(opts, extra...)
 	if err := s.rt.InitFlow(ctx, id, opts...); err != nil {
 		return exported.FlowRunningInsight{}, err
 	}
@@ -253,17 +338,17 @@ func (s *SVC) StartFlowAndWait(ctx context.Context, id nameid.ID) error {
 }
 
 // StartEventFlow starts a flow with event triggers, it will run in a goroutine,
-// so the invoking will return immediately.
+// so the invoking will return immediately. The triggers can be canceled by CancelRunningFlow once it returns.
 func (s *SVC) StartEventFlow(ctx context.Context, id nameid.ID) chan error {
 	wait := make(chan error, 1)
+	// NOTE: here used a new context to avoid the context be canceled by others
+	tctx, cancel := context.WithCancel(context.Background())
+	s.rt.FetchFlow(tctx, id, func(fb *runtime.FlowBody) error {
+		fb.SetCancel(cancel)
+		return nil
+	})
 	go func() {
-		// NOTE: here used a new context to avoid the context be canceled by others
-		ctx, cancel := context.WithCancel(context.Background())
-		s.rt.FetchFlow(ctx, id, func(fb *runtime.FlowBody) error {
-			fb.SetCancel(cancel)
-			return nil
-		})
-		wait <- s.rt.StartEventTrigger(ctx, id)
+		wait <- s.rt.StartEventTrigger(tctx, id)
 	}()
 	return wait
 }
@@ -288,6 +373,29 @@ func (s *SVC) StartFlowOrEventFlow(ctx context.Context, id nameid.ID) error {
 	return s.StartEventFlowAndWait(ctx, id)
 }
 
+// HasTrigger returns true if the flow has event triggers.
+func (s *SVC) HasTrigger(ctx context.Context, id nameid.ID) (bool, error) {
+	return s.rt.HasTrigger(id)
+}
+
+// TriggerFlow sends an event with the 'data' to the flow whose event triggers are started, the flow is run
+// as if one of its triggers fired.
+func (s *SVC) TriggerFlow(ctx context.Context, id nameid.ID, data map[string]string) error {
+	return s.rt.TriggerFlow(ctx, id, data)
+}
+
+// History returns the last runnings of the flow in the service, the latest one is the first.
+func (s *SVC) History(ctx context.Context, id nameid.ID) []exported.FlowRunRecord {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	records := s.history[id.ID()]
+	history := make([]exported.FlowRunRecord, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		history = append(history, records[i])
+	}
+	return history
+}
+
 // ViewLog be used to view the log of a flow or a function, the argument 'id' is the flow's id, the 'seq'
 // is the sequence of the function, the 'w' argument is the output destination of the log.
 func (s *SVC) ViewLog(ctx context.Context, id nameid.ID, seq int, w io.Writer) error {
@@ -307,6 +415,94 @@ func (s *SVC) ViewLog(ctx context.Context, id nameid.ID, seq int, w io.Writer) e
 	return nil
 }
 
+// FollowLog writes the log like ViewLog, then keeps writing the new lines of the log until the 'ctx' is done.
+// The flow must be added into the runtime.
+func (s *SVC) FollowLog(ctx context.Context, id nameid.ID, seq int, w io.Writer) error {
+	if _, err := s.InsightFlow(ctx, id); err != nil {
+		return err
+	}
+	sub := s.rt.Subscribe(0, id)
+	defer sub.Close()
+	// The log doesn't exist before the function runs, so only the new lines are written.
+	s.ViewLog(ctx, id, seq, w)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-sub.C():
+			if !ok {
+				return nil
+			}
+			if ev.Type != runtime.BusLogLine || ev.Seq != seq {
+				continue
+			}
+			if _, err := io.WriteString(w, ev.Line+"\n"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// DeleteFlow cancels the flow and waits for it to finish, then unloads it from runtime and releases
+// all its drivers. It waits at most the grace period that's defined by 'config.ShutdownGracePeriod'.
+func (s *SVC) DeleteFlow(ctx context.Context, id nameid.ID) error {
+	ctx, cancel := context.WithTimeout(ctx, config.ShutdownGracePeriod())
+	defer cancel()
+	return s.rt.DeleteFlow(ctx, id)
+}
+
+// Shutdown gracefully shutdowns the service, it cancels all running flows, waits at most the grace period
+// for the running nodes to finish, releases every loaded driver, stops the triggers and flushes the logs.
+// It's safe to invoke Shutdown repeatedly, only the first invoking takes effect.
+func (s *SVC) Shutdown(ctx context.Context) error {
+	s.shutdown.Do(func() {
+		ctx, cancel := context.WithTimeout(ctx, config.ShutdownGracePeriod())
+		defer cancel()
+
+		var errs []error
+		if err := s.rt.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		s.cron.Stop()
+		if err := s.webhook.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.logfile.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.stdout.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		if len(errs) != 0 {
+			s.shutdownErr = fmt.Errorf("%v", errs)
+		}
+	})
+	return s.shutdownErr
+}
+
+// ErrInterrupted means the service has been shutdown by a signal.
+var ErrInterrupted = errors.New("interrupted by signal")
+
+// WatchSignals shutdowns the service gracefully when receiving SIGINT or SIGTERM, the returned channel
+// will be closed after the shutdown finished. Only the first signal is watched, a second signal terminates
+// the process if the shutdown hangs. Stop watching when the 'ctx' is done.
+func (s *SVC) WatchSignals(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigC:
+			signal.Stop(sigC)
+			s.Shutdown(context.Background())
+			close(done)
+		case <-ctx.Done():
+			signal.Stop(sigC)
+		}
+	}()
+	return done
+}
+
 // restoreAvailableWithMerge restore two directories and merge them into one. baseDir is the default
 // directory, and the privateDir is the user directory, the privateDir will override the baseDir.
 func restoreAvailablesWithMerge(baseDir, privateDir string) (map[string]exported.FlowMetaInsight, error) {
@@ -390,5 +586,10 @@ func parseOneFlowl(name string, meta *exported.FlowMetaInsight) error {
 	})
 	meta.Total = total
 	meta.Desc = ast.Desc()
+	meta.Tags = ast.Tags()
+	loads, _, _ := ast.GetBlocks()
+	for _, b := range loads {
+		meta.Loads = append(meta.Loads, b.Target1().String())
+	}
 	return nil
 }
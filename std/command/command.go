@@ -3,7 +3,6 @@ package command
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,39 +16,15 @@ import (
 	"github.com/skoowoo/cofx/pkg/output"
 )
 
-var cmdArg = manifest.UsageDesc{
-	Name: "cmd",
-	Desc: "Specify a command to run",
-}
-
-var envArg = manifest.UsageDesc{
-	Name: "env",
-	Desc: "Specify environment variables for the command",
-}
-
-var workingDirArg = manifest.UsageDesc{
-	Name: "working_dir",
-	Desc: "Specify working directory for the command",
-}
-
-var splitArg = manifest.UsageDesc{
-	Name: "split",
-	Desc: "Specify a separator to split",
-}
-
-var extractArg = manifest.UsageDesc{
-	Name: "extract_fields",
-	Desc: "Specify one column or more to extract, e.g. 0,1,2",
-}
-
-var queryColumnArg = manifest.UsageDesc{
-	Name: "query_columns",
-	Desc: "Specify column names that you wanted to query",
-}
-
-var queryWhereArg = manifest.UsageDesc{
-	Name: "query_where",
-	Desc: "Specify where clause for query",
+// Args is the arguments of the command function.
+type Args struct {
+	Cmd           string   `arg:"cmd,required" desc:"Specify a command to run"`
+	Env           []string `arg:"env" desc:"Specify environment variables for the command"`
+	WorkingDir    string   `arg:"working_dir,type=path" desc:"Specify working directory for the command"`
+	Split         string   `arg:"split" desc:"Specify a separator to split"`
+	ExtractFields []int    `arg:"extract_fields" desc:"Specify one column or more to extract, e.g. 0,1,2"`
+	QueryColumns  []string `arg:"query_columns" desc:"Specify column names that you wanted to query"`
+	QueryWhere    string   `arg:"query_where" desc:"Specify where clause for query"`
 }
 
 var _manifest = manifest.Manifest{
@@ -61,7 +36,7 @@ var _manifest = manifest.Manifest{
 	RetryOnFailure: 0,
 	IgnoreFailure:  false,
 	Usage: manifest.Usage{
-		Args: []manifest.UsageDesc{cmdArg, envArg, workingDirArg, splitArg, queryColumnArg, queryWhereArg},
+		Args: spec.ArgsUsage(Args{}),
 	},
 }
 
@@ -70,31 +45,18 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 }
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
-	// cmd
-	cmdstr := args.GetString(cmdArg.Name)
-	if cmdstr == "" {
-		return nil, errors.New("command function miss argument: " + cmdArg.Name)
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return nil, fmt.Errorf("%w: in command function", err)
 	}
-	// env
-	env := args.GetStringSlice(envArg.Name)
-	// working dir
-	workingDir := args.GetString(workingDirArg.Name)
-	if workingDir == "" {
+	if a.WorkingDir == "" {
 		dir, err := os.Getwd()
 		if err != nil {
 			return nil, err
 		}
-		workingDir = dir
-	}
-
-	// split -> extract -> query
-	splitSep := args.GetString(splitArg.Name)
-	extractFields, err := args.GetIntSlice(extractArg.Name)
-	if err != nil {
-		return nil, fmt.Errorf("%w: extract %s", err, args.GetString(extractArg.Name))
+		a.WorkingDir = dir
 	}
-	queryColumns := args.GetStringSlice(queryColumnArg.Name)
-	queryWhere := args.GetString(queryWhereArg.Name)
+	splitSep := a.Split
 
 	flowId := bundle.Resources.Labels.GetFlowID()
 	nodeSeq := bundle.Resources.Labels.GetNodeSeq()
@@ -122,13 +84,13 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 			if err := bundle.Resources.OutputParser.Insert(ctx, names, values...); err != nil {
 				log.Println(fmt.Errorf("%w: insert command output", err))
 			}
-		}, extractFields...),
+		}, a.ExtractFields...),
 	}
 
 	// start the command
-	cmd := exec.CommandContext(ctx, "sh", "-c", cmdstr)
-	cmd.Env = append(cmd.Env, env...)
-	cmd.Dir = workingDir
+	cmd := exec.CommandContext(ctx, "sh", "-c", a.Cmd)
+	cmd.Env = append(cmd.Env, a.Env...)
+	cmd.Dir = a.WorkingDir
 
 	fmt.Fprintf(bundle.Resources.Logwriter, "---> %s\n", cmd.String())
 
@@ -161,7 +123,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 	}
 
 	// query outcome
-	rows, err := bundle.Resources.OutputParser.Query(ctx, queryColumns, queryWhere)
+	rows, err := bundle.Resources.OutputParser.Query(ctx, a.QueryColumns, a.QueryWhere)
 	if err != nil {
 		return nil, err
 	}
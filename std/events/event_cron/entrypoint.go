@@ -2,6 +2,7 @@ package eventcron
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/skoowoo/cofx/functiondriver/go/spec"
@@ -36,14 +37,13 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
 	expr := args.GetString(exprArg.Name)
 	custom := bundle.Custom.(*custom)
-	if custom.entity == nil && custom.cron == nil {
-		cron := bundle.Resources.CronTrigger
-		entity, err := cron.Add(expr, custom.waiting)
-		if err != nil {
-			return nil, err
-		}
-		custom.entity = entity
-		custom.cron = cron
+	next, err := custom.add(bundle.Resources.CronTrigger, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if r := bundle.Resources.Trigger; r != nil {
+		r.ExpectNext(next)
 	}
 
 	select {
@@ -56,15 +56,37 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 }
 
 type custom struct {
+	sync.Mutex
 	entity  interface{}
 	cron    resource.CronTrigger
 	waiting chan time.Time
 }
 
+// add adds the cron entry if it isn't added yet, the next time of the entry is returned.
+func (c *custom) add(cron resource.CronTrigger, expr string) (time.Time, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.cron == nil {
+		entity, err := cron.Add(expr, c.waiting)
+		if err != nil {
+			return time.Time{}, err
+		}
+		c.entity = entity
+		c.cron = cron
+	}
+	return c.cron.Next(c.entity), nil
+}
+
+// Close removes the cron entry, it's safe to be invoked repeatedly, e.g. the entrypoint has closed it
+// when the context is canceled, then the driver closes it again when releasing.
 func (c *custom) Close() error {
-	close(c.waiting)
-	c.cron.Remove(c.entity)
+	c.Lock()
+	defer c.Unlock()
+	if c.cron == nil {
+		return nil
+	}
+	err := c.cron.Remove(c.entity)
 	c.entity = nil
 	c.cron = nil
-	return nil
+	return err
 }
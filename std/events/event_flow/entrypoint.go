@@ -0,0 +1,119 @@
+package eventflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+// StatusAny matches both the successful and the failed runnings of the flow.
+const StatusAny = "any"
+
+// Args is the arguments of the event_flow function.
+type Args struct {
+	Flow   string `arg:"flow,required" desc:"The name of the flow to wait for, e.g. go-auto-build"`
+	Status string `arg:"status" enum:"success,failure,any" default:"success" desc:"The status of the flow that fires the event"`
+}
+
+var _manifest = manifest.Manifest{
+	Category:       "event",
+	Name:           "event_flow",
+	Description:    "Cross-flow event trigger, it fires when another flow is finished",
+	Driver:         "go",
+	Args:           map[string]string{},
+	RetryOnFailure: 0,
+	Usage: manifest.Usage{
+		Args: spec.ArgsUsage(Args{}),
+		ReturnValues: []manifest.UsageDesc{
+			{Name: "flow", Desc: "The name of the finished flow"},
+			{Name: "flow_id", Desc: "The id of the finished flow"},
+			{Name: "run_id", Desc: "The id of the running"},
+			{Name: "status", Desc: "The status of the running, success or failure"},
+			{Name: "error", Desc: "The error of the running, it's empty if the running is successful"},
+			{Name: "finished", Desc: "The time when the running is finished"},
+			{Name: "output.<key>", Desc: "The outputs saved by the outcome function of the flow, e.g. output.binary"},
+		},
+	},
+}
+
+func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+	return &_manifest, Entrypoint, func() spec.Customer { return &custom{events: make(chan map[string]string, 16)} }
+}
+
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+	custom := bundle.Custom.(*custom)
+	status, err := custom.subscribe(bundle, args)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case ev := <-custom.events:
+			if status != StatusAny && ev["status"] != status {
+				continue
+			}
+			ev["which"] = _manifest.Name
+			return ev, nil
+		case <-ctx.Done():
+			custom.Close()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type custom struct {
+	sync.Mutex
+	trigger resource.FlowTrigger
+	entity  interface{}
+	status  string
+	events  chan map[string]string
+}
+
+// subscribe subscribes to the completion events of the flow if it isn't subscribed yet, the status of the
+// runnings that fire the event is returned.
+func (c *custom) subscribe(bundle spec.EntrypointBundle, args spec.EntrypointArgs) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.trigger != nil {
+		return c.status, nil
+	}
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return "", err
+	}
+	trigger := bundle.Resources.FlowTrigger
+	if trigger == nil {
+		return "", fmt.Errorf("no flow trigger service for %s", _manifest.Name)
+	}
+	var subscriber string
+	if lbs := bundle.Resources.Labels; lbs != nil {
+		subscriber = lbs.GetFlowName()
+	}
+	entity, err := trigger.Subscribe(subscriber, a.Flow, c.events)
+	if err != nil {
+		return "", err
+	}
+	c.trigger = trigger
+	c.entity = entity
+	c.status = a.Status
+	return c.status, nil
+}
+
+// Close unsubscribes from the flow, the completion events published later aren't queued for the trigger.
+// Closing an unsubscribed trigger does nothing.
+func (c *custom) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.trigger == nil {
+		return nil
+	}
+	err := c.trigger.Unsubscribe(c.entity)
+	c.trigger = nil
+	c.entity = nil
+	return err
+}
@@ -0,0 +1,90 @@
+package eventflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/flowtrigger"
+	"github.com/skoowoo/cofx/service/resource/labels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFlow(t *testing.T) {
+	ft := flowtrigger.New()
+
+	_, ep, create := New()
+	c := create().(*custom)
+	bundle := spec.EntrypointBundle{
+		Version:   "latest",
+		Custom:    c,
+		Resources: resource.Resources{FlowTrigger: ft, Labels: labels.Labels{"flow_name": "package"}},
+	}
+	args := spec.EntrypointArgs{"flow": "build"}
+
+	type result struct {
+		rets map[string]string
+		err  error
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wait := func() chan result {
+		ch := make(chan result, 1)
+		go func() {
+			rets, err := ep(ctx, bundle, args)
+			ch <- result{rets, err}
+		}()
+		return ch
+	}
+
+	ch := wait()
+	// Keep publishing until the subscription is added, the failed running doesn't fire the event
+	var r result
+	assert.Eventually(t, func() bool {
+		ft.Publish("build", map[string]string{"status": "failure", "run_id": "r0"})
+		ft.Publish("build", map[string]string{"status": "success", "run_id": "r1", "output.binary": "bin/cofx"})
+		select {
+		case r = <-ch:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, r.err)
+	assert.Equal(t, "success", r.rets["status"])
+	assert.Equal(t, "r1", r.rets["run_id"])
+	assert.Equal(t, "bin/cofx", r.rets["output.binary"])
+	assert.Equal(t, "event_flow", r.rets["which"])
+
+	// The subscription is removed when the context is canceled
+	for len(c.events) != 0 {
+		<-c.events
+	}
+	ch = wait()
+	cancel()
+	r = <-ch
+	assert.ErrorIs(t, r.err, context.Canceled)
+	_, err := ft.Subscribe("build", "package", make(chan map[string]string))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+}
+
+func TestEventFlowCycle(t *testing.T) {
+	ft := flowtrigger.New()
+	_, err := ft.Subscribe("build", "package", make(chan map[string]string))
+	assert.NoError(t, err)
+
+	_, ep, create := New()
+	bundle := spec.EntrypointBundle{
+		Version:   "latest",
+		Custom:    create(),
+		Resources: resource.Resources{FlowTrigger: ft, Labels: labels.Labels{"flow_name": "package"}},
+	}
+	_, err = ep(context.Background(), bundle, spec.EntrypointArgs{"flow": "build"})
+	assert.ErrorIs(t, err, flowtrigger.ErrFlowCycle)
+
+	_, err = ep(context.Background(), bundle, spec.EntrypointArgs{"flow": "build", "status": "done"})
+	assert.Error(t, err)
+}
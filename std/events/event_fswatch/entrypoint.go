@@ -0,0 +1,315 @@
+package eventfswatch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+)
+
+// Args is the arguments of the event_fswatch function.
+type Args struct {
+	Paths     []string      `arg:"paths,required" desc:"The directories or files to watch, e.g. ./proto, ./api"`
+	Include   []string      `arg:"include" desc:"The glob patterns of the files to watch, matched against the base name or the path relative to the watched directory, e.g. *.proto"`
+	Exclude   []string      `arg:"exclude" default:".git" desc:"The glob patterns of the files and directories to ignore, a directory matched is ignored entirely"`
+	Recursive bool          `arg:"recursive" default:"true" desc:"Watch the sub directories, including the ones created later"`
+	Ops       []string      `arg:"ops" default:"create,write,remove,rename" desc:"The operations to watch, they're create, write, remove, rename and chmod"`
+	Debounce  time.Duration `arg:"debounce" default:"500ms" desc:"The changes in the duration are merged into one event"`
+}
+
+var _manifest = manifest.Manifest{
+	Category:       "event",
+	Name:           "event_fswatch",
+	Description:    "File system event trigger, it fires when the watched files are changed",
+	Driver:         "go",
+	Args:           map[string]string{},
+	RetryOnFailure: 0,
+	Usage: manifest.Usage{
+		Args: spec.ArgsUsage(Args{}),
+		ReturnValues: []manifest.UsageDesc{
+			{Name: "paths", Desc: "The changed paths, separated by ','"},
+			{Name: "ops", Desc: "The operations of the changes, separated by ','"},
+			{Name: "path", Desc: "The first changed path"},
+			{Name: "op", Desc: "The operations of the first changed path, separated by '|'"},
+			{Name: "count", Desc: "The number of the changed paths"},
+		},
+	},
+}
+
+func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+	return &_manifest, Entrypoint, func() spec.Customer {
+		return &custom{events: make(chan map[string]string), errs: make(chan error, 1)}
+	}
+}
+
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+	custom := bundle.Custom.(*custom)
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return nil, err
+	}
+	if err := custom.watch(a); err != nil {
+		return nil, err
+	}
+
+	select {
+	case ev := <-custom.events:
+		ev["which"] = _manifest.Name
+		return ev, nil
+	case err := <-custom.errs:
+		return nil, err
+	case <-ctx.Done():
+		custom.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type custom struct {
+	sync.Mutex
+	watcher *fsnotify.Watcher
+	events  chan map[string]string
+	errs    chan error
+}
+
+// watching is the settings of a watching, it isn't changed after the watching starts, so the loop
+// goroutine reads it without the lock.
+type watching struct {
+	args  Args
+	roots []string
+	ops   fsnotify.Op
+}
+
+// watch starts watching the paths if they aren't watched yet.
+func (c *custom) watch(a Args) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.watcher != nil {
+		return nil
+	}
+	wc := &watching{args: a}
+	for _, op := range a.Ops {
+		o := parseOp(op)
+		if o == 0 {
+			return fmt.Errorf("%w: 'ops' has an unknown operation '%s'", manifest.ErrInvalidArg, op)
+		}
+		wc.ops |= o
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, p := range a.Paths {
+		root, err := filepath.Abs(p)
+		if err != nil {
+			w.Close()
+			return err
+		}
+		wc.roots = append(wc.roots, root)
+		if err := wc.add(w, root); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	c.watcher = w
+	go c.loop(w, wc)
+	return nil
+}
+
+// add watches the path, the sub directories are watched too if it's a recursive watching.
+func (c *watching) add(w *fsnotify.Watcher, path string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() || !c.args.Recursive {
+		return w.Add(path)
+	}
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != path && c.excluded(p) {
+			return filepath.SkipDir
+		}
+		return w.Add(p)
+	})
+}
+
+// loop merges the changes in the debounce window into one event, the changes are kept until the event
+// is received by the entrypoint.
+func (c *custom) loop(w *fsnotify.Watcher, wc *watching) {
+	var (
+		pending = make(map[string]fsnotify.Op)
+		timer   = time.NewTimer(0)
+		ready   bool
+	)
+	<-timer.C
+	defer timer.Stop()
+	for {
+		var (
+			out chan map[string]string
+			ev  map[string]string
+		)
+		if ready {
+			out, ev = c.events, event(pending)
+		}
+		select {
+		case e, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			// Watch the new directory of a recursive watching
+			if e.Op&fsnotify.Create != 0 && wc.args.Recursive && !wc.excluded(e.Name) {
+				if st, err := os.Stat(e.Name); err == nil && st.IsDir() {
+					wc.add(w, e.Name)
+				}
+			}
+			if e.Op&wc.ops == 0 || !wc.matched(e.Name) {
+				continue
+			}
+			pending[e.Name] |= e.Op & wc.ops
+			ready = false
+			// Drain the fired timer, otherwise the stale fire ends the new debounce window at once
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wc.args.Debounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case c.errs <- err:
+			default:
+			}
+		case <-timer.C:
+			ready = len(pending) > 0
+		case out <- ev:
+			pending = make(map[string]fsnotify.Op)
+			ready = false
+		}
+	}
+}
+
+// matched returns true if the path isn't excluded, and it's included if the include patterns are given.
+func (c *watching) matched(path string) bool {
+	if c.excluded(path) {
+		return false
+	}
+	if len(c.args.Include) == 0 {
+		return true
+	}
+	rel := c.rel(path)
+	for _, p := range c.args.Include {
+		if ok, _ := filepath.Match(p, filepath.Base(path)); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded returns true if the path or one of its parent directories under the watched directory matches
+// the exclude patterns.
+func (c *watching) excluded(path string) bool {
+	rel := c.rel(path)
+	for _, p := range c.args.Exclude {
+		if ok, _ := filepath.Match(p, rel); ok {
+			return true
+		}
+		for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+			if ok, _ := filepath.Match(p, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rel returns the path relative to the watched directory that contains it.
+func (c *watching) rel(path string) string {
+	for _, root := range c.roots {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return filepath.Base(path)
+}
+
+// Close closes the watcher, the loop goroutine exits when the channels of the watcher are closed. It does
+// nothing if no path is watched.
+func (c *custom) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.watcher == nil {
+		return nil
+	}
+	err := c.watcher.Close()
+	c.watcher = nil
+	return err
+}
+
+func event(changes map[string]fsnotify.Op) map[string]string {
+	var paths []string
+	var all fsnotify.Op
+	for p, op := range changes {
+		paths = append(paths, p)
+		all |= op
+	}
+	sort.Strings(paths)
+	return map[string]string{
+		"paths": strings.Join(paths, ","),
+		"ops":   strings.Join(opNames(all), ","),
+		"path":  paths[0],
+		"op":    strings.Join(opNames(changes[paths[0]]), "|"),
+		"count": strconv.Itoa(len(paths)),
+	}
+}
+
+var opnames = []struct {
+	name string
+	op   fsnotify.Op
+}{
+	{"create", fsnotify.Create},
+	{"write", fsnotify.Write},
+	{"remove", fsnotify.Remove},
+	{"rename", fsnotify.Rename},
+	{"chmod", fsnotify.Chmod},
+}
+
+func parseOp(name string) fsnotify.Op {
+	for _, o := range opnames {
+		if o.name == strings.ToLower(name) {
+			return o.op
+		}
+	}
+	return 0
+}
+
+func opNames(op fsnotify.Op) []string {
+	var names []string
+	for _, o := range opnames {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	return names
+}
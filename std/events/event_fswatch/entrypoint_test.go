@@ -0,0 +1,101 @@
+package eventfswatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventFswatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "api", "v1"), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	_, ep, create := New()
+	c := create().(*custom)
+	defer c.Close()
+	bundle := spec.EntrypointBundle{Custom: c}
+	args := spec.EntrypointArgs{
+		"paths":    dir,
+		"include":  "*.proto",
+		"exclude":  ".git, vendor",
+		"debounce": "100ms",
+	}
+	type result struct {
+		rets map[string]string
+		err  error
+	}
+	wait := func() chan result {
+		ch := make(chan result, 1)
+		go func() {
+			rets, err := ep(context.Background(), bundle, args)
+			ch <- result{rets, err}
+		}()
+		return ch
+	}
+	write := func(path string) {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte("syntax = \"proto3\";"), 0644); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+
+	ch := wait()
+	assert.Eventually(t, func() bool {
+		c.Lock()
+		defer c.Unlock()
+		return c.watcher != nil
+	}, time.Second, 10*time.Millisecond)
+
+	write("README.md")
+	write("vendor/a.proto")
+	write("api/v1/a.proto")
+	write("api/v1/b.proto")
+	res := <-ch
+	assert.NoError(t, res.err)
+	assert.Equal(t, "event_fswatch", res.rets["which"])
+	assert.Equal(t, "2", res.rets["count"])
+	assert.Equal(t, filepath.Join(dir, "api/v1/a.proto")+","+filepath.Join(dir, "api/v1/b.proto"), res.rets["paths"])
+	assert.Equal(t, filepath.Join(dir, "api/v1/a.proto"), res.rets["path"])
+	assert.Contains(t, res.rets["ops"], "create")
+
+	// The directory created after watching is watched too
+	if err := os.MkdirAll(filepath.Join(dir, "api", "v2"), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	time.Sleep(50 * time.Millisecond)
+	write("api/v2/c.proto")
+	res = <-wait()
+	assert.NoError(t, res.err)
+	assert.Equal(t, filepath.Join(dir, "api/v2/c.proto"), res.rets["path"])
+
+	// The changes are kept until the entrypoint is invoked again
+	assert.NoError(t, os.Remove(filepath.Join(dir, "api/v1/a.proto")))
+	time.Sleep(200 * time.Millisecond)
+	res = <-wait()
+	assert.NoError(t, res.err)
+	assert.Equal(t, "remove", res.rets["op"])
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+}
+
+func TestEventFswatchInvalidArgs(t *testing.T) {
+	_, ep, create := New()
+	bundle := spec.EntrypointBundle{Custom: create()}
+	_, err := ep(context.Background(), bundle, spec.EntrypointArgs{"paths": t.TempDir(), "ops": "create,touch"})
+	assert.ErrorIs(t, err, manifest.ErrInvalidArg)
+	_, err = ep(context.Background(), bundle, spec.EntrypointArgs{"paths": filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+	_, err = ep(context.Background(), bundle, spec.EntrypointArgs{})
+	assert.ErrorIs(t, err, manifest.ErrMissingArg)
+}
@@ -0,0 +1,261 @@
+package eventgit
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/runcmd"
+)
+
+// The types of the events.
+const (
+	EventCommit        = "commit"
+	EventTag           = "tag"
+	EventBranchCreated = "branch_created"
+	EventBranchDeleted = "branch_deleted"
+)
+
+// maxSubjects is the max number of the commit subjects returned by an event.
+const maxSubjects = 50
+
+// Args is the arguments of the event_git function.
+type Args struct {
+	Dir      string        `arg:"dir,type=path" desc:"The directory of the local repository, default the current directory"`
+	Refs     []string      `arg:"refs" desc:"The glob patterns of the branches to watch, matched against the full or short name, e.g. main, upstream/*; all branches by default"`
+	Tags     bool          `arg:"tags" default:"true" desc:"Fire when a new tag is created"`
+	Branches bool          `arg:"branches" default:"true" desc:"Fire when a branch is created or deleted"`
+	Fetch    string        `arg:"fetch" desc:"The remote to fetch before polling, e.g. upstream, or 'all' to fetch all remotes"`
+	Interval time.Duration `arg:"interval" default:"30s" desc:"The interval of polling the repository"`
+}
+
+var _manifest = manifest.Manifest{
+	Category:       "event",
+	Name:           "event_git",
+	Description:    "Git repository event trigger, it fires on new commits, tags or branches",
+	Driver:         "go",
+	Args:           map[string]string{},
+	RetryOnFailure: 0,
+	Usage: manifest.Usage{
+		Args: spec.ArgsUsage(Args{}),
+		ReturnValues: []manifest.UsageDesc{
+			{Name: "event", Desc: "The type of the event, it's one of commit, tag, branch_created and branch_deleted"},
+			{Name: "ref", Desc: "The full name of the ref, e.g. refs/heads/main"},
+			{Name: "name", Desc: "The short name of the ref, e.g. main, upstream/main, v1.0.0"},
+			{Name: "old_sha", Desc: "The sha before the change, it's empty if the ref is created"},
+			{Name: "new_sha", Desc: "The sha after the change, it's empty if the ref is deleted"},
+			{Name: "subjects", Desc: "The subjects of the new commits, separated by '\\n', at most 50 of them"},
+			{Name: "commits", Desc: "The number of the new commits, it's empty if the old commit doesn't exist any more"},
+		},
+	},
+}
+
+func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+	return &_manifest, Entrypoint, func() spec.Customer { return &custom{} }
+}
+
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return nil, err
+	}
+	custom := bundle.Custom.(*custom)
+	// The first snapshot is the baseline, the refs existed before watching don't fire
+	if custom.refs == nil {
+		refs, err := listRefs(ctx, a.Dir)
+		if err != nil {
+			return nil, err
+		}
+		custom.refs = refs
+	}
+
+	for len(custom.pending) == 0 {
+		if r := bundle.Resources.Trigger; r != nil {
+			r.ExpectNext(time.Now().Add(a.Interval))
+		}
+		timer := time.NewTimer(a.Interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		if err := custom.poll(ctx, a); err != nil {
+			return nil, err
+		}
+	}
+	ev := custom.pending[0]
+	custom.pending = custom.pending[1:]
+	ev["which"] = _manifest.Name
+	return ev, nil
+}
+
+type custom struct {
+	// refs is the last snapshot of the refs, the map is full name -> sha
+	refs map[string]string
+	// pending saves the events that haven't been returned
+	pending []map[string]string
+}
+
+// poll fetches the remote if needed, then compares the refs with the last snapshot.
+func (c *custom) poll(ctx context.Context, a Args) error {
+	if a.Fetch != "" {
+		remote := a.Fetch
+		if remote == "all" {
+			remote = "--all"
+		}
+		if _, err := git(ctx, a.Dir, "fetch", "--quiet", "--prune", remote); err != nil {
+			return err
+		}
+	}
+	refs, err := listRefs(ctx, a.Dir)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for name := range refs {
+		names = append(names, name)
+	}
+	for name := range c.refs {
+		if _, ok := refs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	// The events and the snapshot are saved only if all the refs are compared, so a failed polling is
+	// retried from the same snapshot.
+	var pending []map[string]string
+	for _, name := range names {
+		oldsha, newsha := c.refs[name], refs[name]
+		if oldsha == newsha {
+			continue
+		}
+		ev := map[string]string{
+			"ref":     name,
+			"name":    shortName(name),
+			"old_sha": oldsha,
+			"new_sha": newsha,
+		}
+		if isTag(name) {
+			if !a.Tags || oldsha != "" || newsha == "" {
+				continue
+			}
+			ev["event"] = EventTag
+		} else {
+			if !watched(a.Refs, name) {
+				continue
+			}
+			switch {
+			case oldsha == "":
+				if !a.Branches {
+					continue
+				}
+				ev["event"] = EventBranchCreated
+			case newsha == "":
+				if !a.Branches {
+					continue
+				}
+				ev["event"] = EventBranchDeleted
+			default:
+				ev["event"] = EventCommit
+				// The old commit may be gone after a force push and gc, then only the new sha is reported.
+				if _, err := git(ctx, a.Dir, "cat-file", "-e", oldsha+"^{commit}"); err != nil {
+					break
+				}
+				subjects, err := git(ctx, a.Dir, "log", "--format=%s", "-n", strconv.Itoa(maxSubjects), oldsha+".."+newsha)
+				if err != nil {
+					return err
+				}
+				count, err := git(ctx, a.Dir, "rev-list", "--count", oldsha+".."+newsha)
+				if err != nil {
+					return err
+				}
+				ev["subjects"] = strings.Join(subjects, "\n")
+				if len(count) > 0 {
+					ev["commits"] = count[0]
+				}
+			}
+		}
+		pending = append(pending, ev)
+	}
+	c.pending = append(c.pending, pending...)
+	c.refs = refs
+	return nil
+}
+
+// Close is nothing to release, the repository is only read when polling.
+func (c *custom) Close() error {
+	return nil
+}
+
+// listRefs returns the branches, the remote branches and the tags of the repository.
+func listRefs(ctx context.Context, dir string) (map[string]string, error) {
+	lines, err := git(ctx, dir, "for-each-ref", "--format=%(refname) %(objectname)", "refs/heads", "refs/remotes", "refs/tags")
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]string)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		// e.g. refs/remotes/origin/HEAD is a symbolic ref
+		if len(fields) != 2 || strings.HasSuffix(fields[0], "/HEAD") {
+			continue
+		}
+		refs[fields[0]] = fields[1]
+	}
+	return refs, nil
+}
+
+// git runs the git command in the directory, and returns the lines of the output.
+func git(ctx context.Context, dir string, args ...string) ([]string, error) {
+	wrap := runcmd.Wrap{
+		Name: "git",
+		Args: args,
+		Dir:  dir,
+		// Not split the line, the whole line is the first field
+		Split:        "\x00",
+		Extract:      []int{0},
+		QueryColumns: []string{"c0"},
+		QueryWhere:   "",
+	}
+	rows, err := wrap.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rows.Column2Slice(0), nil
+}
+
+func isTag(name string) bool {
+	return strings.HasPrefix(name, "refs/tags/")
+}
+
+func shortName(name string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/tags/"} {
+		if strings.HasPrefix(name, prefix) {
+			return strings.TrimPrefix(name, prefix)
+		}
+	}
+	return name
+}
+
+// watched returns true if the branch matches one of the patterns, all branches are watched if no pattern.
+func watched(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, shortName(name)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package eventgit
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	upstream, local := t.TempDir(), t.TempDir()
+	git := func(dir string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=testing", "-c", "user.email=testing@cofx",
+			"-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false", "-c", "init.defaultBranch=main"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			assert.FailNow(t, string(out))
+		}
+	}
+	commit := func(dir, msg string) {
+		if err := os.WriteFile(filepath.Join(dir, "file"), []byte(msg), 0644); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		git(dir, "add", "-A")
+		git(dir, "commit", "--quiet", "-m", msg)
+	}
+	git(upstream, "init", "--quiet")
+	commit(upstream, "init")
+	git(local, "init", "--quiet")
+	commit(local, "init")
+	git(local, "remote", "add", "upstream", upstream)
+	git(local, "fetch", "--quiet", "upstream")
+
+	_, ep, create := New()
+	bundle := spec.EntrypointBundle{Custom: create()}
+	args := spec.EntrypointArgs{
+		"dir":      local,
+		"refs":     "main, upstream/*",
+		"fetch":    "upstream",
+		"interval": "10ms",
+	}
+	next := func() map[string]string {
+		rets, err := ep(context.Background(), bundle, args)
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		return rets
+	}
+	// The baseline
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := ep(ctx, bundle, args)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// New commits upstream
+	commit(upstream, "feature: first")
+	commit(upstream, "fix: second")
+	rets := next()
+	assert.Equal(t, "event_git", rets["which"])
+	assert.Equal(t, EventCommit, rets["event"])
+	assert.Equal(t, "refs/remotes/upstream/main", rets["ref"])
+	assert.Equal(t, "upstream/main", rets["name"])
+	assert.Equal(t, "fix: second\nfeature: first", rets["subjects"])
+	assert.Equal(t, "2", rets["commits"])
+	assert.NotEqual(t, rets["old_sha"], rets["new_sha"])
+
+	// The unwatched branch doesn't fire, the tag and the watched branch fire in order of the ref name
+	git(local, "branch", "dev")
+	git(local, "tag", "v1.0.0")
+	commit(local, "local change")
+	rets = next()
+	assert.Equal(t, EventCommit, rets["event"])
+	assert.Equal(t, "main", rets["name"])
+	assert.Equal(t, "local change", rets["subjects"])
+	rets = next()
+	assert.Equal(t, EventTag, rets["event"])
+	assert.Equal(t, "v1.0.0", rets["name"])
+	assert.Equal(t, "", rets["old_sha"])
+
+	git(upstream, "branch", "release")
+	rets = next()
+	assert.Equal(t, EventBranchCreated, rets["event"])
+	assert.Equal(t, "upstream/release", rets["name"])
+
+	git(upstream, "branch", "-D", "release")
+	rets = next()
+	assert.Equal(t, EventBranchDeleted, rets["event"])
+	assert.Equal(t, "upstream/release", rets["name"])
+	assert.Equal(t, "", rets["new_sha"])
+
+	// The old commit is gone, e.g. after a force push and gc
+	bundle.Custom.(*custom).refs["refs/heads/main"] = strings.Repeat("1", 40)
+	commit(local, "after force push")
+	rets = next()
+	assert.Equal(t, EventCommit, rets["event"])
+	assert.Equal(t, "main", rets["name"])
+	assert.NotEmpty(t, rets["new_sha"])
+	assert.Equal(t, "", rets["subjects"])
+	assert.Equal(t, "", rets["commits"])
+}
+
+func TestEventGitNotRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	_, ep, create := New()
+	bundle := spec.EntrypointBundle{Custom: create()}
+	_, err := ep(context.Background(), bundle, spec.EntrypointArgs{"dir": t.TempDir()})
+	assert.Error(t, err)
+}
@@ -0,0 +1,203 @@
+package eventhttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/service/resource"
+)
+
+// TokenHeader carries the shared secret as is, it's an alternative to the signature.
+const TokenHeader = "X-Cofx-Token"
+
+// maxBodySize is the max size of the request body.
+const maxBodySize = 10 << 20
+
+// Args is the arguments of the event_http function.
+type Args struct {
+	Path            string `arg:"path,required" pattern:"^/" desc:"The path of the webhook, e.g. /hooks/deploy"`
+	Method          string `arg:"method" default:"POST" desc:"The method of the webhook"`
+	Secret          string `arg:"secret" desc:"A shared secret, the request must carry it by the header X-Cofx-Token, or sign the body by HMAC-SHA256"`
+	SignatureHeader string `arg:"signature_header" default:"X-Hub-Signature-256" desc:"The header of the signature, the value is 'sha256=' and the hex of HMAC-SHA256 of the body"`
+}
+
+var _manifest = manifest.Manifest{
+	Category:       "event",
+	Name:           "event_http",
+	Description:    "Webhook event trigger, it fires when receiving a http request of the path",
+	Driver:         "go",
+	Args:           map[string]string{},
+	RetryOnFailure: 0,
+	Usage: manifest.Usage{
+		Args: spec.ArgsUsage(Args{}),
+		ReturnValues: []manifest.UsageDesc{
+			{Name: "method", Desc: "The method of the request"},
+			{Name: "path", Desc: "The path of the request"},
+			{Name: "body", Desc: "The body of the request"},
+			{Name: "query", Desc: "The raw query of the request, every parameter is returned as 'query.<name>' too"},
+			{Name: "header.<name>", Desc: "The headers of the request except the token, the signature and Authorization, the name is lower case and '-' is replaced by '_', e.g. header.x_github_event"},
+			{Name: "remote_addr", Desc: "The address of the client"},
+		},
+	},
+}
+
+func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+	return &_manifest, Entrypoint, func() spec.Customer { return &custom{events: make(chan map[string]string, 16)} }
+}
+
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+	custom := bundle.Custom.(*custom)
+	if err := custom.route(bundle, args); err != nil {
+		return nil, err
+	}
+
+	select {
+	case ev := <-custom.events:
+		ev["which"] = _manifest.Name
+		return ev, nil
+	case <-ctx.Done():
+		custom.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type custom struct {
+	sync.Mutex
+	trigger resource.HttpTrigger
+	path    string
+	events  chan map[string]string
+}
+
+// route adds the route of the webhook into the http trigger service if it isn't added yet.
+func (c *custom) route(bundle spec.EntrypointBundle, args spec.EntrypointArgs) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.trigger != nil {
+		return nil
+	}
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return err
+	}
+	trigger := bundle.Resources.HttpTrigger
+	if trigger == nil {
+		return fmt.Errorf("no http trigger service for %s", _manifest.Name)
+	}
+	if err := trigger.AddRoute(a.Path, c.handler(a)); err != nil {
+		return err
+	}
+	c.trigger = trigger
+	c.path = a.Path
+	return nil
+}
+
+// handler returns the handler of the webhook, the request is accepted if the event is queued, it's
+// rejected with 503 if there are too many events waiting for the flow.
+func (c *custom) handler(a Args) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Method, a.Method) {
+			w.Header().Set("Allow", strings.ToUpper(a.Method))
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			// The reader fails after reading the max size of the body if it's too large
+			if len(body) >= maxBodySize {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if a.Secret != "" && !authorized(r, body, a) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ev := map[string]string{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"body":        string(body),
+			"query":       r.URL.RawQuery,
+			"remote_addr": r.RemoteAddr,
+		}
+		for k, v := range r.URL.Query() {
+			ev["query."+fieldName(k)] = strings.Join(v, ",")
+		}
+		for k, v := range r.Header {
+			// The credentials aren't returned, or they would be seen in the variables and the logs of the flow
+			if secretHeader(k, a) {
+				continue
+			}
+			ev["header."+fieldName(k)] = strings.Join(v, ",")
+		}
+		select {
+		case c.events <- ev:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "too many events", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+// authorized checks the shared secret carried by the token header, or the HMAC-SHA256 signature of the body.
+func authorized(r *http.Request, body []byte, a Args) bool {
+	if token := r.Header.Get(TokenHeader); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(a.Secret)) == 1
+	}
+	sig := strings.TrimPrefix(r.Header.Get(a.SignatureHeader), "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(a.Secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// secretHeader returns true if the header carries the secret or the signature of the request.
+func secretHeader(name string, a Args) bool {
+	for _, h := range []string{TokenHeader, a.SignatureHeader, "Authorization"} {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName converts the name to a valid field name of the variable, e.g. X-GitHub-Event -> x_github_event
+func fieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '_'
+	}, name)
+}
+
+// Close removes the route from the http trigger service, the later requests to the path get 404. It does
+// nothing if the route has been removed.
+func (c *custom) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.trigger == nil {
+		return nil
+	}
+	err := c.trigger.RemoveRoute(c.path)
+	c.trigger = nil
+	return err
+}
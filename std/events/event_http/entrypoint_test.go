@@ -0,0 +1,116 @@
+package eventhttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/service/resource"
+	"github.com/skoowoo/cofx/service/resource/httptrigger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventHttp(t *testing.T) {
+	ht := httptrigger.New("127.0.0.1:0")
+	defer ht.Stop(context.Background())
+
+	_, ep, create := New()
+	custom := create()
+	bundle := spec.EntrypointBundle{
+		Version:   "latest",
+		Custom:    custom,
+		Resources: resource.Resources{HttpTrigger: ht},
+	}
+	args := spec.EntrypointArgs{
+		"path":   "/hooks/deploy",
+		"secret": "s3cret",
+	}
+	type result struct {
+		rets map[string]string
+		err  error
+	}
+	wait := func() chan result {
+		ch := make(chan result, 1)
+		go func() {
+			rets, err := ep(context.Background(), bundle, args)
+			ch <- result{rets, err}
+		}()
+		return ch
+	}
+	send := func(method, body string, headers map[string]string) int {
+		r := httptest.NewRequest(method, "/hooks/deploy?env=prod&dry-run=1", strings.NewReader(body))
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		ht.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	ch := wait()
+	// Waiting for the route is added
+	assert.Eventually(t, func() bool {
+		return send(http.MethodGet, "", nil) != http.StatusNotFound
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, send(http.MethodGet, "", nil))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "{}", nil))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "{}", map[string]string{TokenHeader: "x"}))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "{}", map[string]string{"X-Hub-Signature-256": "sha256=00"}))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(http.MethodPost, strings.Repeat("x", maxBodySize+1), map[string]string{
+		TokenHeader: "s3cret",
+	}))
+
+	assert.Equal(t, http.StatusAccepted, send(http.MethodPost, `{"ref": "main"}`, map[string]string{
+		TokenHeader:      "s3cret",
+		"X-GitHub-Event": "push",
+	}))
+	res := <-ch
+	assert.NoError(t, res.err)
+	assert.Equal(t, "event_http", res.rets["which"])
+	assert.Equal(t, "POST", res.rets["method"])
+	assert.Equal(t, "/hooks/deploy", res.rets["path"])
+	assert.Equal(t, `{"ref": "main"}`, res.rets["body"])
+	assert.Equal(t, "env=prod&dry-run=1", res.rets["query"])
+	assert.Equal(t, "prod", res.rets["query.env"])
+	assert.Equal(t, "1", res.rets["query.dry_run"])
+	assert.Equal(t, "push", res.rets["header.x_github_event"])
+	// The secret isn't returned
+	assert.NotContains(t, res.rets, "header.x_cofx_token")
+
+	body := `{"ref": "dev"}`
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(body))
+	ch = wait()
+	assert.Equal(t, http.StatusAccepted, send(http.MethodPost, body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+	}))
+	res = <-ch
+	assert.NoError(t, res.err)
+	assert.Equal(t, body, res.rets["body"])
+	assert.NotContains(t, res.rets, "header.x_hub_signature_256")
+
+	// The route is removed after closing
+	assert.NoError(t, custom.Close())
+	assert.NoError(t, custom.Close())
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, body, nil))
+}
+
+func TestEventHttpInvalidArgs(t *testing.T) {
+	_, ep, create := New()
+	bundle := spec.EntrypointBundle{
+		Custom:    create(),
+		Resources: resource.Resources{HttpTrigger: httptrigger.New("127.0.0.1:0")},
+	}
+	_, err := ep(context.Background(), bundle, spec.EntrypointArgs{"path": "hooks"})
+	assert.Error(t, err)
+	_, err = ep(context.Background(), bundle, spec.EntrypointArgs{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package eventsignal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+)
+
+// signals are the signals that can be watched, SIGINT and SIGTERM are used to shutdown the cofx process.
+var signals = map[string]syscall.Signal{
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGHUP":  syscall.SIGHUP,
+}
+
+// Args is the arguments of the event_signal function.
+type Args struct {
+	Signals []string `arg:"signals" default:"SIGUSR1,SIGUSR2,SIGHUP" desc:"The signals to watch, they're SIGUSR1, SIGUSR2 and SIGHUP"`
+}
+
+var _manifest = manifest.Manifest{
+	Category:       "event",
+	Name:           "event_signal",
+	Description:    "Signal event trigger, it fires when the cofx process receives the signals",
+	Driver:         "go",
+	Args:           map[string]string{},
+	RetryOnFailure: 0,
+	Usage: manifest.Usage{
+		Args: spec.ArgsUsage(Args{}),
+		ReturnValues: []manifest.UsageDesc{
+			{Name: "signal", Desc: "The name of the received signal, e.g. SIGUSR1"},
+			{Name: "pid", Desc: "The pid of the cofx process"},
+		},
+	},
+}
+
+func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+	return &_manifest, Entrypoint, func() spec.Customer { return &custom{} }
+}
+
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+	custom := bundle.Custom.(*custom)
+	sigC, err := custom.notify(args)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case sig := <-sigC:
+		name := sig.String()
+		for k, v := range signals {
+			if v == sig {
+				name = k
+			}
+		}
+		return map[string]string{
+			"signal": name,
+			"pid":    fmt.Sprint(os.Getpid()),
+			"which":  _manifest.Name,
+		}, nil
+	case <-ctx.Done():
+		custom.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type custom struct {
+	sync.Mutex
+	sigC chan os.Signal
+}
+
+// notify starts relaying the signals to the channel if they aren't relayed yet, the channel is returned.
+func (c *custom) notify(args spec.EntrypointArgs) (chan os.Signal, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.sigC != nil {
+		return c.sigC, nil
+	}
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return nil, err
+	}
+	var sigs []os.Signal
+	for _, s := range a.Signals {
+		sig, ok := signals[strings.ToUpper(s)]
+		if !ok {
+			return nil, fmt.Errorf("%w: signal '%s'", manifest.ErrInvalidArg, s)
+		}
+		sigs = append(sigs, sig)
+	}
+	c.sigC = make(chan os.Signal, 1)
+	signal.Notify(c.sigC, sigs...)
+	return c.sigC, nil
+}
+
+// Close stops relaying the signals, a signal that nobody else watches gets its default behavior again,
+// e.g. SIGHUP terminates the process. It does nothing if the signals aren't relayed.
+func (c *custom) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.sigC == nil {
+		return nil
+	}
+	signal.Stop(c.sigC)
+	c.sigC = nil
+	return nil
+}
@@ -0,0 +1,73 @@
+package eventsignal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSignal(t *testing.T) {
+	// Relay the signal in the test too, so the test process isn't killed before the entrypoint watches it.
+	guard := make(chan os.Signal, 16)
+	signal.Notify(guard, syscall.SIGUSR2)
+	defer signal.Stop(guard)
+
+	_, ep, create := New()
+	c := create().(*custom)
+	bundle := spec.EntrypointBundle{
+		Version: "latest",
+		Custom:  c,
+	}
+	type result struct {
+		rets map[string]string
+		err  error
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wait := func(args spec.EntrypointArgs) chan result {
+		ch := make(chan result, 1)
+		go func() {
+			rets, err := ep(ctx, bundle, args)
+			ch <- result{rets, err}
+		}()
+		return ch
+	}
+
+	ch := wait(spec.EntrypointArgs{"signals": "sigusr2"})
+	var r result
+	assert.Eventually(t, func() bool {
+		syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+		select {
+		case r = <-ch:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, r.err)
+	assert.Equal(t, "SIGUSR2", r.rets["signal"])
+	assert.Equal(t, "event_signal", r.rets["which"])
+
+	assert.NoError(t, c.Close())
+	assert.Nil(t, c.sigC)
+
+	// The signals aren't relayed after the context is canceled
+	c = create().(*custom)
+	bundle.Custom = c
+	ch = wait(spec.EntrypointArgs{"signals": "SIGHUP"})
+	cancel()
+	r = <-ch
+	assert.ErrorIs(t, r.err, context.Canceled)
+	assert.Nil(t, c.sigC)
+	assert.NoError(t, c.Close())
+
+	_, err := ep(context.Background(), spec.EntrypointBundle{Custom: create()}, spec.EntrypointArgs{"signals": "SIGKILL"})
+	assert.ErrorIs(t, err, manifest.ErrInvalidArg)
+}
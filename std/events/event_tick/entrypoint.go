@@ -8,9 +8,9 @@ import (
 	"github.com/skoowoo/cofx/manifest"
 )
 
-var durationArg = manifest.UsageDesc{
-	Name: "duration",
-	Desc: "A time duration, e.g. 1s, 1m, 1h, 1m10s",
+// Args is the arguments of the event_tick function.
+type Args struct {
+	Duration time.Duration `arg:"duration,required" desc:"A time duration, e.g. 1s, 1m, 1h, 1m10s"`
 }
 
 var _manifest = manifest.Manifest{
@@ -23,7 +23,7 @@ var _manifest = manifest.Manifest{
 	},
 	RetryOnFailure: 0,
 	Usage: manifest.Usage{
-		Args:         []manifest.UsageDesc{durationArg},
+		Args:         spec.ArgsUsage(Args{}),
 		ReturnValues: []manifest.UsageDesc{},
 	},
 }
@@ -33,12 +33,15 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 }
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
-	s := args.GetString(durationArg.Name)
-	v, err := time.ParseDuration(s)
-	if err != nil {
+	var a Args
+	if err := args.Decode(&a); err != nil {
 		return nil, err
 	}
+	v := a.Duration
 	ticker := time.NewTicker(v)
+	if r := bundle.Resources.Trigger; r != nil {
+		r.ExpectNext(time.Now().Add(v))
+	}
 	select {
 	case <-ticker.C:
 		return map[string]string{"which": _manifest.Name}, nil
@@ -14,28 +14,14 @@ import (
 	httppost "github.com/skoowoo/cofx/std/http/http_post"
 )
 
-var (
-	urlArg = manifest.UsageDesc{
-		Name: "create_pr_url",
-		Desc: "Specify the url to create a pull request as a api",
-	}
-	fromBranchArg = manifest.UsageDesc{
-		Name: "from_branch",
-		Desc: "Specify the source branch name that will be merged",
-	}
-	toBranchArg = manifest.UsageDesc{
-		Name: "to_branch",
-		Desc: "Specify the target branch name that will merge into",
-	}
-	fromOrgArg = manifest.UsageDesc{
-		Name: "from_org",
-		Desc: "Specify the source org name, maybe it's a personal org",
-	}
-	tokenArg = manifest.UsageDesc{
-		Name: "github_token",
-		Desc: "Specify your github token",
-	}
-)
+// Args is the arguments of the gh_create_pr function.
+type Args struct {
+	URL        string `arg:"create_pr_url,required,type=url" desc:"Specify the url to create a pull request as a api"`
+	ToBranch   string `arg:"to_branch,required" desc:"Specify the target branch name that will merge into"`
+	FromBranch string `arg:"from_branch,required" desc:"Specify the source branch name that will be merged"`
+	FromOrg    string `arg:"from_org,required" desc:"Specify the source org name, maybe it's a personal org"`
+	Token      string `arg:"github_token,required" desc:"Specify your github token"`
+}
 
 var (
 	statusRet = manifest.UsageDesc{
@@ -56,7 +42,7 @@ var _manifest = manifest.Manifest{
 	Args:           map[string]string{},
 	RetryOnFailure: 0,
 	Usage: manifest.Usage{
-		Args:         []manifest.UsageDesc{urlArg, toBranchArg, fromBranchArg, fromOrgArg, tokenArg},
+		Args:         spec.ArgsUsage(Args{}),
 		ReturnValues: []manifest.UsageDesc{statusRet, prHtmlRet},
 	},
 }
@@ -66,10 +52,11 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 }
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
-	toBranch, fromBranch := args.GetString(toBranchArg.Name), args.GetString(fromBranchArg.Name)
-	if toBranch == "" || fromBranch == "" {
-		return nil, fmt.Errorf("not specified %s or %s", toBranchArg.Name, fromBranchArg.Name)
+	var a Args
+	if err := args.Decode(&a); err != nil {
+		return nil, fmt.Errorf("%w: in %s function", err, _manifest.Name)
 	}
+	toBranch, fromBranch := a.ToBranch, a.FromBranch
 	// Figure out the commits information about the branch that will be merged
 	_args := spec.EntrypointArgs{
 		"cmd":            "git rev-list --left-right --pretty=oneline  " + fromBranch + "...upstream/" + toBranch,
@@ -100,29 +87,16 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 
 	// Create pull request by calling github api
 	{
-		fromOrg := args.GetString(fromOrgArg.Name)
-		if fromOrg == "" {
-			return nil, fmt.Errorf("not specified %s ", fromOrgArg.Name)
-		}
 		cpr := CreatePullRequest{
 			Title: firstCommit,
 			Body:  "",
-			Head:  fromOrg + ":" + fromBranch,
+			Head:  a.FromOrg + ":" + fromBranch,
 			Base:  toBranch,
 		}
 		var buff bytes.Buffer
 		json.NewEncoder(&buff).Encode(&cpr)
 		fmt.Fprintf(bundle.Resources.Logwriter, "create pull request: %s\n", buff.String())
 
-		url := args.GetString(urlArg.Name)
-		if url == "" {
-			return nil, fmt.Errorf("not specified " + urlArg.Name)
-		}
-		token := args.GetString(tokenArg.Name)
-		if token == "" {
-			return nil, fmt.Errorf("not specified " + tokenArg.Name)
-		}
-
 		_, post, custom := httppost.New()
 
 		_bundle := spec.EntrypointBundle{
@@ -135,8 +109,8 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		}
 		_bundle.Custom.(*httppost.Custom).BodyReader = bytes.NewReader(buff.Bytes())
 		args := spec.EntrypointArgs{
-			"url":             url,
-			"set_headers":     "Accept: application/vnd.github+json, Authorization: Bearer " + token,
+			"url":             a.URL,
+			"set_headers":     "Accept: application/vnd.github+json, Authorization: Bearer " + a.Token,
 			"query_json_path": "_links.html.href",
 		}
 		rets, err := post(ctx, _bundle, args)
@@ -16,13 +16,17 @@ import (
 )
 
 var urlArg = manifest.UsageDesc{
-	Name: "url",
-	Desc: "Specify the url address to access",
+	Name:     "url",
+	Type:     manifest.TypeURL,
+	Required: true,
+	Desc:     "Specify the url address to access",
 }
 
 var pathArg = manifest.UsageDesc{
-	Name: "query_json_path",
-	Desc: "Specify the path to get values from json document, the path is provided with GJSON Syntax:\nhttps://github.com/tidwall/gjson/blob/master/SYNTAX.md",
+	Name:     "query_json_path",
+	Type:     manifest.TypeList,
+	Required: true,
+	Desc:     "Specify the path to get values from json document, the path is provided with GJSON Syntax:\nhttps://github.com/tidwall/gjson/blob/master/SYNTAX.md",
 }
 
 var headersArg = manifest.UsageDesc{
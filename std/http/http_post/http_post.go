@@ -17,8 +17,10 @@ import (
 
 var (
 	urlArg = manifest.UsageDesc{
-		Name: "url",
-		Desc: "Specify the url address to access",
+		Name:     "url",
+		Type:     manifest.TypeURL,
+		Required: true,
+		Desc:     "Specify the url address to access",
 	}
 
 	pathArg = manifest.UsageDesc{
@@ -38,6 +40,7 @@ var (
 
 	jsonBodyArg = manifest.UsageDesc{
 		Name: "json_file_path",
+		Type: manifest.TypePath,
 		Desc: "Specify the json file path to read as the http POST request body",
 	}
 )
@@ -3,11 +3,18 @@ package std
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/skoowoo/cofx/functiondriver/go/spec"
 	"github.com/skoowoo/cofx/manifest"
+	"github.com/skoowoo/cofx/pkg/semver"
 	"github.com/skoowoo/cofx/std/command"
 	eventcron "github.com/skoowoo/cofx/std/events/event_cron"
+	eventflow "github.com/skoowoo/cofx/std/events/event_flow"
+	eventfswatch "github.com/skoowoo/cofx/std/events/event_fswatch"
+	eventgit "github.com/skoowoo/cofx/std/events/event_git"
+	eventhttp "github.com/skoowoo/cofx/std/events/event_http"
+	eventsignal "github.com/skoowoo/cofx/std/events/event_signal"
 	eventtick "github.com/skoowoo/cofx/std/events/event_tick"
 	gitaddupstream "github.com/skoowoo/cofx/std/git/git_add_upstream"
 	gitbasic "github.com/skoowoo/cofx/std/git/git_basic"
@@ -28,16 +35,49 @@ import (
 	stdtime "github.com/skoowoo/cofx/std/time"
 )
 
-// Lookup returns the manifest object and entrypoint method of the given function name.
+// DefaultVersion is the version of the function whose manifest doesn't declare the version.
+const DefaultVersion = "1.0.0"
+
+// Lookup returns the manifest object and entrypoint method of the latest version of the given function name.
 func Lookup(name string) (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 	fc, ok := builtin[name]
 	if ok {
-		return fc, entrypoints[fc.Entrypoint], factories[name]
+		return fc, entrypoints[fc.Entrypoint], factories[name+"@"+fc.Version]
 	}
 	return nil, nil, nil
 }
 
-// ListAll returns all the function manifest of the standard library.
+// LookupVersion returns the manifest object and entrypoint method of the highest version of the given
+// function name that satisfies the constraint, e.g. '^1.2' or 'latest'.
+func LookupVersion(name, constraint string) (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	vs := Versions(name)
+	if len(vs) == 0 {
+		return nil, nil, nil, fmt.Errorf("in std, not found function: %s", name)
+	}
+	found, ok := semver.Highest(c, vs)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w: '%s' of %s, the installed versions are %s", manifest.ErrVersionNotFound,
+			constraint, name, strings.Join(vs, ", "))
+	}
+	fc := versions[name][found]
+	return fc, entrypoints[fc.Entrypoint], factories[name+"@"+found], nil
+}
+
+// Versions returns all the versions of the given function name, from the highest to the lowest.
+func Versions(name string) []string {
+	var vs []string
+	for v := range versions[name] {
+		vs = append(vs, v)
+	}
+	semver.Sort(vs)
+	return vs
+}
+
+// ListAll returns the manifest of the latest version of all the functions of the standard library.
 func ListAll() []manifest.Manifest {
 	var mfs []manifest.Manifest
 	for _, m := range builtin {
@@ -47,27 +87,47 @@ func ListAll() []manifest.Manifest {
 }
 
 func register(name string, mf *manifest.Manifest, ep spec.EntrypointFunc, cr spec.CreateCustomFunc) error {
-	_, ok := builtin[name]
-	if ok {
-		return errors.New("repeat register the function name: " + name)
+	if mf.Version == "" {
+		mf.Version = DefaultVersion
+	}
+	v, err := semver.Parse(mf.Version)
+	if err != nil {
+		return fmt.Errorf("%w: function %s", err, name)
+	}
+	if _, ok := versions[name][mf.Version]; ok {
+		return errors.New("repeat register the function name: " + name + "@" + mf.Version)
+	}
+	if versions[name] == nil {
+		versions[name] = make(map[string]*manifest.Manifest)
+	}
+	versions[name][mf.Version] = mf
+	if latest, ok := builtin[name]; !ok || v.Compare(mustParse(latest.Version)) > 0 {
+		builtin[name] = mf
 	}
-	builtin[name] = mf
 	entrypoints[mf.Entrypoint] = ep
-	factories[name] = cr
+	factories[name+"@"+mf.Version] = cr
 	return nil
 }
 
+func mustParse(s string) semver.Version {
+	v, _ := semver.Parse(s)
+	return v
+}
+
 var (
-	// builtin store kvs of function name -> manifest.
+	// builtin store kvs of function name -> manifest of the latest version.
 	builtin map[string]*manifest.Manifest
+	// versions store kvs of function name -> version -> manifest.
+	versions map[string]map[string]*manifest.Manifest
 	// entrypoints store kvs of entrypoint name -> entrypoint func.
 	entrypoints map[string]spec.EntrypointFunc
-	// factories store kvs of function name -> a func that be used to create a custom object.
+	// factories store kvs of function name@version -> a func that be used to create a custom object.
 	factories map[string]spec.CreateCustomFunc
 )
 
 func init() {
 	builtin = make(map[string]*manifest.Manifest)
+	versions = make(map[string]map[string]*manifest.Manifest)
 	entrypoints = make(map[string]spec.EntrypointFunc)
 	factories = make(map[string]spec.CreateCustomFunc)
 
@@ -97,6 +157,11 @@ func init() {
 		// event trigger function
 		eventtick.New,
 		eventcron.New,
+		eventhttp.New,
+		eventflow.New,
+		eventsignal.New,
+		eventfswatch.New,
+		eventgit.New,
 	}
 
 	for i, New := range stds {
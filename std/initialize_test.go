@@ -1,8 +1,11 @@
 package std
 
 import (
+	"context"
 	"testing"
 
+	"github.com/skoowoo/cofx/functiondriver/go/spec"
+	"github.com/skoowoo/cofx/manifest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,3 +19,54 @@ func TestStdInit(t *testing.T) {
 		assert.Equal(t, true, ok)
 	}
 }
+
+func TestStdVersions(t *testing.T) {
+	saved := []interface{}{builtin, versions, entrypoints, factories}
+	defer func() {
+		builtin = saved[0].(map[string]*manifest.Manifest)
+		versions = saved[1].(map[string]map[string]*manifest.Manifest)
+		entrypoints = saved[2].(map[string]spec.EntrypointFunc)
+		factories = saved[3].(map[string]spec.CreateCustomFunc)
+	}()
+	builtin = make(map[string]*manifest.Manifest)
+	versions = make(map[string]map[string]*manifest.Manifest)
+	entrypoints = make(map[string]spec.EntrypointFunc)
+	factories = make(map[string]spec.CreateCustomFunc)
+
+	ep := func(context.Context, spec.EntrypointBundle, spec.EntrypointArgs) (map[string]string, error) {
+		return nil, nil
+	}
+	for _, v := range []string{"1.2.0", "", "2.0.0", "1.3.1"} {
+		mf := &manifest.Manifest{Name: "foo", Version: v, Entrypoint: "foo" + v}
+		assert.NoError(t, register("foo", mf, ep, nil))
+	}
+	assert.Error(t, register("foo", &manifest.Manifest{Name: "foo", Version: "1.2.0"}, ep, nil))
+	assert.Error(t, register("bar", &manifest.Manifest{Name: "bar", Version: "x"}, ep, nil))
+
+	assert.Equal(t, []string{"2.0.0", "1.3.1", "1.2.0", "1.0.0"}, Versions("foo"))
+	mf, _, _ := Lookup("foo")
+	assert.Equal(t, "2.0.0", mf.Version)
+
+	testcases := []struct {
+		constraint string
+		expected   string
+	}{
+		{"latest", "2.0.0"},
+		{"^1.2", "1.3.1"},
+		{"~1.2", "1.2.0"},
+		{"1", "1.3.1"},
+		{"1.0.0", "1.0.0"},
+	}
+	for _, c := range testcases {
+		mf, ep, _, err := LookupVersion("foo", c.constraint)
+		assert.NoError(t, err)
+		assert.NotNil(t, ep)
+		assert.Equal(t, c.expected, mf.Version)
+	}
+
+	_, _, _, err := LookupVersion("foo", "^3")
+	assert.ErrorIs(t, err, manifest.ErrVersionNotFound)
+	assert.Contains(t, err.Error(), "2.0.0, 1.3.1, 1.2.0, 1.0.0")
+	_, _, _, err = LookupVersion("bar", "latest")
+	assert.Error(t, err)
+}
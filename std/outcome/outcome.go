@@ -20,11 +20,13 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
 	fid := bundle.Resources.Labels.GetFlowID()
+	runid := bundle.Resources.Labels.GetRunID()
 	seq := bundle.Resources.Labels.GetNodeSeq()
 	name := bundle.Resources.Labels.GetNodeName()
 
-	columns := []string{"flow_id", "node_seq", "node_name", "key", "value"}
-	values := []string{fid, seq, name}
+	// The replicas of the flow share the flow id, so the outcomes are saved by the run id too.
+	columns := []string{"flow_id", "run_id", "node_seq", "node_name", "key", "value"}
+	values := []string{fid, runid, seq, name}
 
 	for k, v := range args {
 		vs := textparse.String2Slice(v)